@@ -0,0 +1,1400 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"javierMorales9/licitaciones/internal"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Códigos de salida.
+const (
+	exitOK    = 0
+	exitError = 1 // el comando falló
+	exitUsage = 2 // argumentos incorrectos, como flag.ExitOnError
+)
+
+type command struct {
+	run     func(args []string) error
+	summary string
+}
+
+var commands = map[string]command{
+	"census":    {census, "censo de rutas y valores del XML del archivo"},
+	"config":    {config, "valida el fichero de -config y muestra el perfil"},
+	"cpv":       {cpv, "nomenclatura CPV 2008: descripción, árbol y validación"},
+	"crawl":     {crawl, "descarga el feed de sindicación al directorio de datos"},
+	"discounts": {discounts, "bajas de adjudicación por CPV, órgano, procedimiento…"},
+	"drift":     {drift, "rutas del XML sin mapear y versiones de listas de códigos"},
+	"export":    {export, "vuelca las entries (modelo canónico) en JSON Lines, JSON o CSV"},
+	"geo":       {geo, "provincia y comunidad autónoma de un NUTS o un código postal"},
+	"history":   {history, "versiones de un expediente y lo que cambia entre ellas"},
+	"index":     {index, "construye el índice de búsqueda"},
+	"ingest":    {ingest, "carga el archivo en PostgreSQL ($PG_DSN)"},
+	"lifecycle": {lifecycle, "comprueba las transiciones de estado de expedientes"},
+	"network":   {network, "red órgano–adjudicatario (GraphML, DOT o CSV)"},
+	"orgs":      {orgs, "directorio de órganos de contratación"},
+	"redflags":  {redflags, "indicadores de riesgo por licitación"},
+	"renewals":  {renewals, "contratos que vencen y cuándo se volverán a licitar"},
+	"replay":    {replay, "reproduce versiones archivadas y las compara con los golden"},
+	"search":    {search, "búsqueda de texto completo en el índice"},
+	"searches":  {searches, "búsquedas guardadas por usuario y sus avisos"},
+	"serve":     {serve, "API HTTP de sólo lectura"},
+	"series":    {series, "series temporales de publicaciones"},
+	"winners":   {winners, "directorio de adjudicatarios"},
+}
+
+// Flags globales (van antes del subcomando): son el valor por defecto de -data
+// y -workers en todos los subcomandos. Con -config, el perfil elegido pone
+// además el directorio, el feed, el filtro de -where y la base de datos.
+var (
+	defaultDataDir = "data/"
+	defaultWorkers = 8
+	configPath     = os.Getenv("LICITACIONES_CONFIG")
+	profileName    = ""
+	cfg            *internal.Config
+	profile        *internal.Profile // nil si no hay -config, o hay varios perfiles y ningún -profile
+)
+
+func main() {
+	fs := flag.NewFlagSet("licitaciones", flag.ContinueOnError)
+	fs.StringVar(&defaultDataDir, "data", defaultDataDir, "directorio con los atom descargados")
+	fs.IntVar(&defaultWorkers, "workers", defaultWorkers, "ficheros procesados en paralelo")
+	fs.StringVar(&configPath, "config", configPath, "fichero de configuración YAML o TOML (por defecto $LICITACIONES_CONFIG)")
+	fs.StringVar(&profileName, "profile", profileName, "perfil del fichero de configuración (obligatorio si hay varios)")
+	fs.Usage = func() { printUsage(fs) }
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitUsage)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	if configPath != "" {
+		if err := loadProfile(fs); err != nil {
+			log.Print(err)
+			os.Exit(exitUsage)
+		}
+	}
+
+	name, args := fs.Arg(0), fs.Args()[1:]
+	if name == "help" {
+		if len(args) == 0 {
+			fs.SetOutput(os.Stdout)
+			fs.Usage()
+			return
+		}
+		name, args = args[0], []string{"-h"}
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "licitaciones: comando desconocido %q\n\n", name)
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	if err := cmd.run(args); err != nil {
+		log.Print(err)
+		var ue *usageError
+		if errors.As(err, &ue) {
+			os.Exit(exitUsage)
+		}
+		os.Exit(exitError)
+	}
+}
+
+// loadProfile lee -config y aplica el perfil a los valores por defecto que no se
+// hayan dado explícitamente.
+func loadProfile(fs *flag.FlagSet) error {
+	var err error
+	if cfg, err = internal.LoadConfig(configPath); err != nil {
+		return err
+	}
+	if profileName == "" && len(cfg.Profiles) > 1 {
+		return nil
+	}
+	if profile, err = cfg.Profile(profileName); err != nil {
+		return err
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !set["data"] {
+		defaultDataDir = profile.Sources[0].Dir
+	}
+	return nil
+}
+
+func printUsage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintln(w, "usage: licitaciones [-data dir] [-workers n] [-config file] [-profile name] <command> [flags] [args]")
+	fmt.Fprintln(w, "\ncomandos:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w, "\nflags globales:")
+	fs.PrintDefaults()
+	fmt.Fprintln(w, "\n\"licitaciones help <command>\" o \"licitaciones <command> -h\" para los flags de cada comando.")
+	fmt.Fprintf(w, "Salida: %d bien, %d error, %d uso incorrecto.\n", exitOK, exitError, exitUsage)
+}
+
+// usageError es un error de argumentos; sale con exitUsage.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return "usage: licitaciones " + e.msg
+}
+
+func usagef(format string, a ...any) error {
+	return &usageError{fmt.Sprintf(format, a...)}
+}
+
+// dataFlag y workersFlag registran -data y -workers con el valor global por defecto.
+func dataFlag(fs *flag.FlagSet) *string {
+	return fs.String("data", defaultDataDir, "directorio con los atom descargados")
+}
+
+func workersFlag(fs *flag.FlagSet) *int {
+	return fs.Int("workers", defaultWorkers, "ficheros procesados en paralelo")
+}
+
+// history <id|fichero.atom> [-json] [-data dir] [-since AAAA-MM-DD] [-where expr] [-save fichero.atom]
+// Con -where sólo se muestran las versiones que cumplen el filtro, y los cambios
+// son entre ellas; -save vuelca siempre todas.
+func history(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "salida en JSON")
+	dataDir := dataFlag(fs)
+	since := fs.String("since", "", "no buscar en ficheros anteriores a esta fecha (AAAA-MM-DD)")
+	save := fs.String("save", "", "volcar las versiones en bruto en este fichero (p. ej. un fixture de tests/ para replay)")
+	where := whereFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return usagef("history [-json] [-data dir] [-since date] [-where expr] [-save file.atom] <id|file.atom>")
+	}
+
+	filter, err := where()
+	if err != nil {
+		return err
+	}
+	h, err := loadHistory(fs.Arg(0), *dataDir, *since, *save)
+	if err != nil {
+		return err
+	}
+	h = h.Filter(filter)
+
+	if *asJSON {
+		return printJSON(h)
+	}
+
+	fmt.Printf("%s: %d versiones\n", h.ID, len(h.Versions))
+	for i, v := range h.Versions {
+		fmt.Printf("%s  %s\n", v.Updated.Format(time.RFC3339), v.Status)
+		if i == 0 {
+			continue
+		}
+		d := h.Diffs[i-1]
+		for _, c := range d.Changes {
+			fmt.Printf("    %s: %q -> %q\n", c.Field, c.From, c.To)
+		}
+		for _, doc := range d.NewDocs {
+			fmt.Printf("    + doc %s (%s)\n", doc.Name, doc.Kind)
+		}
+		for _, w := range d.NewWinners {
+			fmt.Printf("    + adjudicatario lote %q: %s %s\n", w.LotID, w.NIF, w.Name)
+		}
+		for _, w := range d.RemovedWinners {
+			fmt.Printf("    - adjudicatario lote %q: %s %s\n", w.LotID, w.NIF, w.Name)
+		}
+	}
+	return nil
+}
+
+// lifecycle [-json] [-data dir] [-where expr] <id|fichero.atom>...
+// Con -where se saltan los expedientes cuya última versión no cumple el filtro;
+// el ciclo de vida se comprueba siempre con todas las versiones.
+func lifecycle(args []string) error {
+	fs := flag.NewFlagSet("lifecycle", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "salida en JSON")
+	dataDir := dataFlag(fs)
+	where := whereFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return usagef("lifecycle [-json] [-data dir] [-where expr] <id|file.atom>...")
+	}
+
+	filter, err := where()
+	if err != nil {
+		return err
+	}
+	reports := make([]internal.LifecycleReport, 0, fs.NArg())
+	for _, ref := range fs.Args() {
+		h, err := loadHistory(ref, *dataDir, "", "")
+		if err != nil {
+			return err
+		}
+		if e := h.Latest(); filter != nil && (e == nil || !filter.Match(e)) {
+			log.Printf("[skip] %s no cumple -where", h.ID)
+			continue
+		}
+		reports = append(reports, internal.CheckLifecycle(h))
+	}
+
+	if *asJSON {
+		return printJSON(reports)
+	}
+	for _, r := range reports {
+		fmt.Println(r)
+	}
+	return nil
+}
+
+// replay [-update] [-golden fichero] <dir|fichero.atom>...
+// Por defecto el golden de data/x es tests/golden/x.json.
+func replay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	update := fs.Bool("update", false, "reescribir los golden con el resultado actual")
+	golden := fs.String("golden", "", "fichero golden (sólo con un argumento)")
+	fs.Parse(args)
+	if fs.NArg() == 0 || (*golden != "" && fs.NArg() > 1) {
+		return usagef("replay [-update] [-golden file] <dir|file.atom>...")
+	}
+
+	failed := 0
+	for _, path := range fs.Args() {
+		g := *golden
+		if g == "" {
+			base := strings.TrimSuffix(filepath.Base(filepath.Clean(path)), ".atom")
+			g = filepath.Join("tests", "golden", base+".json")
+		}
+
+		res, err := internal.Replay(context.Background(), path)
+		if err != nil {
+			return err
+		}
+		if err := internal.CheckGolden(g, res, *update); err != nil {
+			log.Print(err)
+			failed++
+			continue
+		}
+		log.Printf("[ok] %s: %d versiones, %d eventos", path, res.Versions, len(res.Events))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d replay(s) differ from golden", failed)
+	}
+	return nil
+}
+
+// orgs [-data dir] [-cpv prefijos] [-exclude prefijos] [-from fecha] [-to fecha] [-out fichero] [-workers n]
+// Sin -cpv se mantiene el directorio de hidrocarburos de siempre (09132, 09134).
+func orgs(args []string) error {
+	fs := flag.NewFlagSet("orgs", flag.ExitOnError)
+	dataDir := dataFlag(fs)
+	include := fs.String("cpv", "09132,09134", "prefijos CPV a incluir, separados por comas (vacío = todos)")
+	exclude := fs.String("exclude", "", "prefijos CPV a excluir, separados por comas")
+	from := fs.String("from", "", "sólo licitaciones publicadas desde esta fecha (AAAA-MM-DD)")
+	to := fs.String("to", "", "sólo licitaciones publicadas antes de esta fecha (AAAA-MM-DD)")
+	out := fs.String("out", "dondesea.csv", "fichero CSV de salida")
+	where := whereFlag(fs)
+	workers := workersFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return usagef("orgs [-data dir] [-cpv prefixes] [-exclude prefixes] [-from date] [-to date] [-where expr] [-out file] [-workers n]")
+	}
+
+	opts := internal.ParseAtomOptions{
+		Output:  *out,
+		Workers: *workers,
+	}
+	var err error
+	if opts.CPV, err = cpvFilter(*include, *exclude); err != nil {
+		return err
+	}
+	if opts.From, err = parseDateFlag(*from); err != nil {
+		return err
+	}
+	if opts.To, err = parseDateFlag(*to); err != nil {
+		return err
+	}
+	if opts.Where, err = where(); err != nil {
+		return err
+	}
+	return internal.ParseAtom(*dataDir, opts)
+}
+
+// awardFlags registra los filtros comunes a los informes de adjudicaciones.
+func awardFlags(fs *flag.FlagSet) func() (internal.AwardFilter, error) {
+	include := fs.String("cpv", "", "prefijos CPV a incluir, separados por comas")
+	exclude := fs.String("exclude", "", "prefijos CPV a excluir, separados por comas")
+	from := fs.String("from", "", "adjudicaciones desde esta fecha (AAAA-MM-DD)")
+	to := fs.String("to", "", "adjudicaciones antes de esta fecha (AAAA-MM-DD)")
+	where := whereFlag(fs)
+	return func() (internal.AwardFilter, error) {
+		var f internal.AwardFilter
+		var err error
+		if f.CPV, err = cpvFilter(*include, *exclude); err != nil {
+			return f, err
+		}
+		if f.From, err = parseDateFlag(*from); err != nil {
+			return f, err
+		}
+		if f.To, err = parseDateFlag(*to); err != nil {
+			return f, err
+		}
+		f.Where, err = where()
+		return f, err
+	}
+}
+
+// whereFlag registra -where, una expresión del lenguaje de filtros.
+func whereFlag(fs *flag.FlagSet) func() (*internal.Filter, error) {
+	def := ""
+	if profile != nil {
+		def = profile.FilterExpr()
+	}
+	where := fs.String("where", def, `filtro, p.ej. 'cpv ^= "0913" and budget >= 100000' (campos: `+strings.Join(internal.FilterFieldNames(), ", ")+")")
+	return func() (*internal.Filter, error) {
+		return internal.ParseFilterFlag(*where)
+	}
+}
+
+// cpvFilter valida los prefijos de -cpv y -exclude.
+func cpvFilter(include, exclude string) (internal.CPVFilter, error) {
+	var f internal.CPVFilter
+	var err error
+	if f.Include, err = internal.ParseCPVPrefixes(include); err != nil {
+		return f, err
+	}
+	f.Exclude, err = internal.ParseCPVPrefixes(exclude)
+	return f, err
+}
+
+// winners [-data dir] [-cpv prefijos] [-exclude prefijos] [-from fecha] [-to fecha] [-format csv|json] [-out fichero]
+func winners(args []string) error {
+	fs := flag.NewFlagSet("winners", flag.ExitOnError)
+	dataDir := dataFlag(fs)
+	filter := awardFlags(fs)
+	format := fs.String("format", "csv", "csv o json")
+	out := fs.String("out", "winners.csv", "fichero de salida (- = stdout)")
+	workers := workersFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 0 || (*format != "csv" && *format != "json") {
+		return usagef("winners [-data dir] [-cpv prefixes] [-exclude prefixes] [-from date] [-to date] [-where expr] [-format csv|json] [-out file]")
+	}
+
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+	records, err := internal.ScanAwards(context.Background(), *dataDir, *workers, f)
+	if err != nil {
+		return err
+	}
+	suppliers := internal.BuildSuppliers(records)
+	log.Printf("[done] %d adjudicaciones, %d adjudicatarios", len(records), len(suppliers))
+
+	if *format == "json" {
+		return internal.WriteJSONFile(*out, suppliers)
+	}
+	return internal.WriteSuppliersCSV(*out, suppliers)
+}
+
+// redflags [-data dir] [filtros] [-window días] [-urgent-window días] [-repeat n] [-min-score n] [-format csv|json] [-out fichero]
+func redflags(args []string) error {
+	fs := flag.NewFlagSet("redflags", flag.ExitOnError)
+	dataDir := dataFlag(fs)
+	filter := awardFlags(fs)
+	window := fs.Int("window", 15, "plazo mínimo de presentación en días")
+	urgentWindow := fs.Int("urgent-window", 8, "plazo mínimo con tramitación urgente")
+	repeat := fs.Int("repeat", 3, "expedientes de un órgano al mismo NIF a partir de los que se marca")
+	tolerance := fs.Float64("tolerance", 0, "exceso sobre presupuesto tolerado (0.05 = 5%)")
+	minScore := fs.Int("min-score", 0, "omitir expedientes con menos puntuación")
+	format := fs.String("format", "csv", "csv o json")
+	out := fs.String("out", "redflags.csv", "fichero de salida (- = stdout)")
+	workers := workersFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 0 || (*format != "csv" && *format != "json") {
+		return usagef("redflags [-data dir] [-cpv prefixes] [-from date] [-to date] [-where expr] [-window days] [-urgent-window days] [-repeat n] [-tolerance f] [-min-score n] [-format csv|json] [-out file]")
+	}
+
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+	flags, err := internal.ScanRedFlags(context.Background(), *dataDir, *workers, f, internal.RedFlagOptions{
+		MinWindowDays:    *window,
+		UrgentWindowDays: *urgentWindow,
+		RepeatAwards:     *repeat,
+		BudgetTolerance:  *tolerance,
+		MinScore:         *minScore,
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("[done] %d expedientes marcados", len(flags))
+
+	if *format == "json" {
+		return internal.WriteJSONFile(*out, flags)
+	}
+	return internal.WriteRedFlagsCSV(*out, flags)
+}
+
+// renewals [-data dir] [filtros] [-as-of fecha] [-months n] [-by expiry|max|retender] [-lead meses] [-cpv-digits n] [-format csv|json] [-out fichero] [-summary fichero]
+func renewals(args []string) error {
+	fs := flag.NewFlagSet("renewals", flag.ExitOnError)
+	dataDir := dataFlag(fs)
+	filter := awardFlags(fs)
+	asOf := fs.String("as-of", "", "inicio de la ventana (AAAA-MM-DD, hoy por defecto)")
+	months := fs.Int("months", 12, "meses de la ventana")
+	by := fs.String("by", internal.RenewalByRetender, "fecha que debe caer en la ventana: expiry, max o retender")
+	lead := fs.Int("lead", 6, "meses antes del fin en que se suele volver a licitar")
+	cpvDigits := fs.Int("cpv-digits", 4, "dígitos de CPV con que se agrupa el resumen")
+	format := fs.String("format", "csv", "csv o json")
+	out := fs.String("out", "renewals.csv", "fichero de salida (- = stdout)")
+	summary := fs.String("summary", "", "fichero CSV con el resumen por CPV y órgano")
+	workers := workersFlag(fs)
+	fs.Parse(args)
+	byOK := *by == internal.RenewalByExpiry || *by == internal.RenewalByMaxExpiry || *by == internal.RenewalByRetender
+	if fs.NArg() != 0 || !byOK || (*format != "csv" && *format != "json") {
+		return usagef("renewals [-data dir] [-cpv prefixes] [-from date] [-to date] [-where expr] [-as-of date] [-months n] [-by expiry|max|retender] [-lead months] [-cpv-digits n] [-format csv|json] [-out file] [-summary file]")
+	}
+
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+	start, err := parseDateFlag(*asOf)
+	if err != nil {
+		return err
+	}
+	if start.IsZero() {
+		now := time.Now().UTC()
+		start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	opts := internal.RenewalOptions{Awards: f, From: start, By: *by, LeadMonths: *lead}
+	if *months > 0 {
+		opts.To = start.AddDate(0, *months, 0)
+	}
+	rs, err := internal.ScanRenewals(context.Background(), *dataDir, *workers, opts)
+	if err != nil {
+		return err
+	}
+	groups := internal.SummarizeRenewals(rs, *by, *cpvDigits)
+	log.Printf("[done] %d contratos con %s entre %s y %s", len(rs), *by, start.Format("2006-01-02"), opts.To.Format("2006-01-02"))
+
+	if *summary != "" {
+		if err := internal.WriteRenewalGroupsCSV(*summary, groups); err != nil {
+			return err
+		}
+	}
+	if *format == "json" {
+		return internal.WriteJSONFile(*out, map[string]any{"contracts": rs, "groups": groups})
+	}
+	return internal.WriteRenewalsCSV(*out, rs)
+}
+
+// discounts [-data dir] [filtros] [-by dims] [-cpv-digits n] [-min n] [-format csv|json] [-out fichero] [-awards fichero]
+func discounts(args []string) error {
+	fs := flag.NewFlagSet("discounts", flag.ExitOnError)
+	dataDir := dataFlag(fs)
+	filter := awardFlags(fs)
+	by := fs.String("by", strings.Join(internal.DiscountDimensions, ","), "dimensiones: "+strings.Join(internal.DiscountDimensions, ","))
+	cpvDigits := fs.Int("cpv-digits", 5, "dígitos de CPV con que se agrupa (8 = código completo)")
+	minN := fs.Int("min", 1, "omitir grupos con menos adjudicaciones")
+	format := fs.String("format", "csv", "csv o json")
+	out := fs.String("out", "discounts.csv", "fichero de salida (- = stdout)")
+	awardsOut := fs.String("awards", "", "fichero CSV con la baja de cada adjudicación")
+	workers := workersFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 0 || (*format != "csv" && *format != "json") {
+		return usagef("discounts [-data dir] [-cpv prefixes] [-from date] [-to date] [-where expr] [-by dims] [-cpv-digits n] [-min n] [-format csv|json] [-out file] [-awards file]")
+	}
+	dims := strings.Split(*by, ",")
+	for _, d := range dims {
+		if !slices.Contains(internal.DiscountDimensions, d) {
+			return fmt.Errorf("dimensión desconocida %q (válidas: %s)", d, strings.Join(internal.DiscountDimensions, ", "))
+		}
+	}
+
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+	records, err := internal.ScanAwards(context.Background(), *dataDir, *workers, f)
+	if err != nil {
+		return err
+	}
+	ds := internal.Discounts(records)
+	stats := internal.AggregateDiscounts(ds, dims, *cpvDigits, *minN)
+	log.Printf("[done] %d adjudicaciones, %d con baja calculable", len(records), len(ds))
+
+	if *awardsOut != "" {
+		if err := internal.WriteDiscountsCSV(*awardsOut, ds); err != nil {
+			return err
+		}
+	}
+	if *format == "json" {
+		return internal.WriteJSONFile(*out, stats)
+	}
+	return internal.WriteDiscountStatsCSV(*out, stats)
+}
+
+// network [-data dir] [filtros] [-format graphml|dot|csv] [-weight count|amount] [-out fichero]
+func network(args []string) error {
+	fs := flag.NewFlagSet("network", flag.ExitOnError)
+	dataDir := dataFlag(fs)
+	filter := awardFlags(fs)
+	format := fs.String("format", "graphml", "graphml, dot o csv")
+	weight := fs.String("weight", "count", "peso de las aristas: count o amount")
+	out := fs.String("out", "", "fichero de salida (- = stdout; por defecto network.<formato>)")
+	workers := workersFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 0 || (*weight != "count" && *weight != "amount") {
+		return usagef("network [-data dir] [-cpv prefixes] [-from date] [-to date] [-where expr] [-format graphml|dot|csv] [-weight count|amount] [-out file]")
+	}
+	if *out == "" {
+		*out = "network." + *format
+	}
+
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+	records, err := internal.ScanAwards(context.Background(), *dataDir, *workers, f)
+	if err != nil {
+		return err
+	}
+	net := internal.BuildNetwork(records)
+	log.Printf("[done] %d adjudicaciones, %d nodos, %d aristas", len(records), len(net.Nodes), len(net.Edges))
+	return internal.WriteNetwork(*out, *format, net, *weight)
+}
+
+// series [-data dir] [-period month|week] [-by all|cpv|nuts|province|region|procedure] [-cpv prefijos] [-from fecha] [-to fecha] [-out fichero] [-chart count|budget]
+func series(args []string) error {
+	fs := flag.NewFlagSet("series", flag.ExitOnError)
+	dataDir := dataFlag(fs)
+	period := fs.String("period", internal.PeriodMonth, "month o week")
+	by := fs.String("by", "all", "desglose: "+strings.Join(internal.SeriesDimensions, ", "))
+	cpvDigits := fs.Int("cpv-digits", 3, "dígitos de CPV con que se agrupa")
+	nutsLen := fs.Int("nuts-len", 0, "caracteres de NUTS con que se agrupa (0 = completo)")
+	include := fs.String("cpv", "", "prefijos CPV a incluir, separados por comas")
+	exclude := fs.String("exclude", "", "prefijos CPV a excluir, separados por comas")
+	from := fs.String("from", "", "publicadas desde esta fecha (AAAA-MM-DD)")
+	to := fs.String("to", "", "publicadas antes de esta fecha (AAAA-MM-DD)")
+	out := fs.String("out", "series.csv", "fichero CSV de salida (- = stdout, vacío = no escribir)")
+	chart := fs.String("chart", "count", "gráfico ASCII por stdout: count, budget o none")
+	where := whereFlag(fs)
+	workers := workersFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 0 || (*period != internal.PeriodMonth && *period != internal.PeriodWeek) ||
+		!slices.Contains(internal.SeriesDimensions, *by) || !slices.Contains([]string{"count", "budget", "none"}, *chart) {
+		return usagef("series [-data dir] [-period month|week] [-by all|cpv|nuts|province|region|procedure] [-cpv prefixes] [-from date] [-to date] [-where expr] [-out file] [-chart count|budget|none]")
+	}
+
+	opts := internal.SeriesOptions{
+		Period:     *period,
+		Dimension:  *by,
+		CPVDigits:  *cpvDigits,
+		NUTSLength: *nutsLen,
+	}
+	var err error
+	if opts.CPV, err = cpvFilter(*include, *exclude); err != nil {
+		return err
+	}
+	if opts.From, err = parseDateFlag(*from); err != nil {
+		return err
+	}
+	if opts.To, err = parseDateFlag(*to); err != nil {
+		return err
+	}
+	if opts.Where, err = where(); err != nil {
+		return err
+	}
+
+	s, err := internal.ScanSeries(context.Background(), *dataDir, *workers, opts)
+	if err != nil {
+		return err
+	}
+	if *out != "" {
+		if err := internal.WriteSeriesCSV(*out, s); err != nil {
+			return err
+		}
+	}
+	if *chart != "none" && *out != "-" {
+		return internal.WriteSeriesChart(os.Stdout, s, *chart, 50)
+	}
+	return nil
+}
+
+// census [-data dir] [-by ruta] [-top n] [-examples n] [-where expr] [-out fichero] <ruta|patrón|all>...
+// Sustituye al antiguo CheckDifferentNoticesTypes con
+//
+//	census -by ContractFolderStatus/ContractFolderStatusCode NoticeTypeCode
+//
+// pero las cifras no coinciden: aquél contaba sólo la primera versión que
+// encontraba de cada ID, y el censo cuenta todas las versiones.
+func census(args []string) error {
+	fs := flag.NewFlagSet("census", flag.ExitOnError)
+	dataDir := dataFlag(fs)
+	by := fs.String("by", "", "desglosar los valores por el de esta ruta de la misma entry")
+	top := fs.Int("top", 20, "valores más frecuentes por ruta en la salida (-1 = todos)")
+	examples := fs.Int("examples", 5, "IDs de ejemplo por ruta")
+	maxDistinct := fs.Int("max-distinct", 1000, "valores distintos guardados por ruta")
+	out := fs.String("out", "census.json", "fichero JSON de salida (- = stdout)")
+	where := whereFlag(fs)
+	workers := workersFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return usagef("census [-data dir] [-by path] [-top n] [-examples n] [-where expr] [-out file] <path|pattern|all>...")
+	}
+
+	opts := internal.CensusOptions{
+		Paths:       fs.Args(),
+		By:          *by,
+		MaxDistinct: *maxDistinct,
+		MaxExamples: *examples,
+	}
+	var err error
+	if opts.Where, err = where(); err != nil {
+		return err
+	}
+	c, err := internal.RunCensus(context.Background(), *dataDir, *workers, opts)
+	if err != nil {
+		return err
+	}
+	r := c.Report(*top)
+	log.Printf("[done] %d ficheros, %d entries, %d rutas", r.Files, r.Entries, len(r.Fields))
+	return internal.WriteJSONFile(*out, r)
+}
+
+// index [-data dir] [-index dir]
+func index(args []string) error {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	dataDir := dataFlag(fs)
+	indexDir := fs.String("index", "index/", "directorio del índice")
+	workers := workersFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return usagef("index [-data dir] [-index dir]")
+	}
+	docs, terms, err := internal.BuildIndex(context.Background(), *dataDir, *indexDir, *workers)
+	if err != nil {
+		return err
+	}
+	log.Printf("[done] %d entries, %d términos en %s", docs, terms, *indexDir)
+	return nil
+}
+
+// search [-index dir] [-cpv prefijos] [-nuts prefijos] [-status códigos] [-min importe] [-max importe] [-limit n] [-offset n] [-json] consulta...
+func search(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	indexDir := fs.String("index", "index/", "directorio del índice (ver index)")
+	include := fs.String("cpv", "", "prefijos CPV a incluir, separados por comas")
+	exclude := fs.String("exclude", "", "prefijos CPV a excluir, separados por comas")
+	nuts := fs.String("nuts", "", "prefijos NUTS, separados por comas")
+	status := fs.String("status", "", "estados (PUB, EV, ADJ, RES…), separados por comas")
+	minAmount := fs.Float64("min", 0, "presupuesto mínimo sin impuestos")
+	maxAmount := fs.Float64("max", 0, "presupuesto máximo sin impuestos")
+	limit := fs.Int("limit", 20, "resultados por página")
+	offset := fs.Int("offset", 0, "resultados a saltar")
+	asJSON := fs.Bool("json", false, "salida JSON")
+	where := whereFlag(fs)
+	fs.Parse(args)
+
+	w, err := where()
+	if err != nil {
+		return err
+	}
+	cpv, err := cpvFilter(*include, *exclude)
+	if err != nil {
+		return err
+	}
+	q := internal.SearchQuery{
+		Text: strings.Join(fs.Args(), " "),
+		Filter: internal.SearchFilter{
+			CPV:       cpv,
+			NUTS:      splitList(*nuts),
+			Status:    splitList(*status),
+			MinAmount: *minAmount,
+			MaxAmount: *maxAmount,
+		},
+		Where:  w,
+		Limit:  *limit,
+		Offset: *offset,
+	}
+	if q.Text == "" && *include == "" && *nuts == "" && *status == "" && *minAmount == 0 && *maxAmount == 0 && w == nil {
+		return usagef("search [-index dir] [-cpv prefixes] [-nuts prefixes] [-status codes] [-min amount] [-max amount] [-where expr] [-limit n] [-offset n] [-json] query...")
+	}
+
+	ix, err := internal.OpenIndex(*indexDir)
+	if err != nil {
+		return err
+	}
+	defer ix.Close()
+	res, err := ix.Search(q)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return internal.WriteJSONFile("-", res)
+	}
+	fmt.Printf("%d resultados\n", res.Total)
+	for _, h := range res.Hits {
+		fmt.Printf("%6.2f  %-4s %12s  %s\n        %s\n        %s\n", h.Score, h.Status, strconv.FormatFloat(h.Budget, 'f', 2, 64), h.Title, h.Org, h.ID)
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// serve [-data dir] [-index dir] [-addr :8080]
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	dataDir := dataFlag(fs)
+	indexDir := fs.String("index", "index/", "directorio del índice (ver index); sin él no hay /search")
+	addr := fs.String("addr", ":8080", "dirección de escucha")
+	workers := workersFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return usagef("serve [-data dir] [-index dir] [-addr host:port]")
+	}
+
+	cat, err := internal.LoadCatalog(context.Background(), *dataDir, *workers)
+	if err != nil {
+		return err
+	}
+	api := &internal.API{Catalog: cat}
+	if ix, err := internal.OpenIndex(*indexDir); err != nil {
+		log.Printf("[HTTP] sin índice de búsqueda: %v", err)
+	} else {
+		defer ix.Close()
+		api.Index = ix
+	}
+	log.Printf("[HTTP] %d entries, escuchando en %s", cat.Len(), *addr)
+	return http.ListenAndServe(*addr, api.Handler())
+}
+
+// crawl [-data dir] [-url feed] [-since fecha] [-max-pages n] [-delay duración] [-full]
+// Sin -url usa $BASE_FEED_URL, y si no está, el feed completo de la PCSP.
+func crawl(args []string) error {
+	fs := flag.NewFlagSet("crawl", flag.ExitOnError)
+	dataDir := dataFlag(fs)
+	defURL := os.Getenv("BASE_FEED_URL")
+	if profile != nil {
+		defURL = profile.Sources[0].URL
+	}
+	feedURL := fs.String("url", defURL, "URL de la cabecera del feed (por defecto la del perfil o $BASE_FEED_URL)")
+	since := fs.String("since", "", "parar en la primera página actualizada antes de esta fecha (AAAA-MM-DD)")
+	maxPages := fs.Int("max-pages", 0, "máximo de páginas (0 = sin límite)")
+	delay := fs.Duration("delay", time.Second, "espera entre páginas")
+	full := fs.Bool("full", false, "seguir aunque aparezcan páginas ya descargadas")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return usagef("crawl [-data dir] [-url feed] [-since date] [-max-pages n] [-delay duration] [-full]")
+	}
+
+	opts := internal.CrawlOptions{URL: *feedURL, Dir: *dataDir, MaxPages: *maxPages, Full: *full, Delay: *delay}
+	var err error
+	if opts.Since, err = parseDateFlag(*since); err != nil {
+		return err
+	}
+	res, err := internal.Crawl(context.Background(), opts)
+	if res != nil {
+		log.Printf("[done] %d páginas, %d ficheros nuevos en %s", res.Pages, len(res.Files), *dataDir)
+	}
+	return err
+}
+
+// ingest [-data dir] [-dsn url] [-workers n]
+func ingest(args []string) error {
+	fs := flag.NewFlagSet("ingest", flag.ExitOnError)
+	dataDir := dataFlag(fs)
+	defDSN := os.Getenv("PG_DSN")
+	if o := profileOutput("postgres"); o != nil {
+		defDSN = o.DSN
+	}
+	dsn := fs.String("dsn", defDSN, "conexión a PostgreSQL (por defecto la del perfil o $PG_DSN)")
+	searchesPath := fs.String("searches", "", "fichero de búsquedas guardadas a evaluar con cada entry nueva o cambiada")
+	workers := workersFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 0 || *dsn == "" {
+		return usagef("ingest [-data dir] [-dsn url] [-searches file] [-workers n] (or PG_DSN in the environment)")
+	}
+
+	ctx := context.Background()
+	st, err := internal.NewPostgresStore(ctx, *dsn)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	if *searchesPath == "" {
+		return internal.IngestAtoms(ctx, *dataDir, *workers, st, nil)
+	}
+
+	book, err := internal.LoadAlertBook(*searchesPath)
+	if err != nil {
+		return err
+	}
+	batch := book.NewBatch()
+	if err := internal.IngestAtoms(ctx, *dataDir, *workers, st, batch.Add); err != nil {
+		return err
+	}
+	matches := batch.Evaluate(time.Now().UTC())
+	printMatches(matches)
+	if err := book.Save(); err != nil {
+		return err
+	}
+	return notifyMatches(ctx, matches)
+}
+
+// export [-data dir] [-cpv prefijos] [-exclude prefijos] [-nuts prefijos] [-status códigos] [-min importe] [-max importe] [-where expr] [-format jsonl|json|csv] [-out fichero]
+func export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dataDir := dataFlag(fs)
+	include := fs.String("cpv", "", "prefijos CPV a incluir, separados por comas")
+	exclude := fs.String("exclude", "", "prefijos CPV a excluir, separados por comas")
+	nuts := fs.String("nuts", "", "prefijos NUTS, separados por comas")
+	status := fs.String("status", "", "estados (PUB, EV, ADJ, RES…), separados por comas")
+	minAmount := fs.Float64("min", 0, "presupuesto mínimo sin impuestos")
+	maxAmount := fs.Float64("max", 0, "presupuesto máximo sin impuestos")
+	defFormat, defOut := "jsonl", "-"
+	for _, typ := range []string{"jsonl", "json", "csv"} {
+		if o := profileOutput(typ); o != nil {
+			defFormat, defOut = o.Type, o.Path
+			break
+		}
+	}
+	format := fs.String("format", defFormat, "jsonl, json o csv")
+	out := fs.String("out", defOut, "fichero de salida (- = stdout)")
+	where := whereFlag(fs)
+	workers := workersFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return usagef("export [-data dir] [-cpv prefixes] [-exclude prefixes] [-nuts prefixes] [-status codes] [-min amount] [-max amount] [-where expr] [-format jsonl|json|csv] [-out file]")
+	}
+
+	w, err := where()
+	if err != nil {
+		return err
+	}
+	cpv, err := cpvFilter(*include, *exclude)
+	if err != nil {
+		return err
+	}
+	cat, err := internal.LoadCatalog(context.Background(), *dataDir, *workers)
+	if err != nil {
+		return err
+	}
+	page := cat.Entries(internal.EntryQuery{
+		Filter: internal.SearchFilter{
+			CPV:       cpv,
+			NUTS:      splitList(*nuts),
+			Status:    splitList(*status),
+			MinAmount: *minAmount,
+			MaxAmount: *maxAmount,
+		},
+		Where: w,
+	})
+	if err := internal.WriteEntries(*out, *format, page.Entries); err != nil {
+		return err
+	}
+	log.Printf("[done] %d entries", page.Total)
+	return nil
+}
+
+// cpv [-json] código... | cpv -q texto | cpv -import cpv_2008.xml [-out fichero]
+func cpv(args []string) error {
+	fs := flag.NewFlagSet("cpv", flag.ExitOnError)
+	query := fs.String("q", "", "busca códigos por su descripción")
+	importXML := fs.String("import", "", "XML oficial de la nomenclatura (cpv_2008.xml de SIMAP) a convertir")
+	out := fs.String("out", "internal/data/cpv2008.tsv", "fichero generado por -import")
+	asJSON := fs.Bool("json", false, "salida en JSON")
+	fs.Parse(args)
+
+	switch {
+	case *importXML != "":
+		if fs.NArg() != 0 {
+			return usagef("cpv -import cpv_2008.xml [-out file]")
+		}
+		n, err := internal.ImportCPVXML(*importXML, *out)
+		if err != nil {
+			return err
+		}
+		log.Printf("[done] %d códigos en %s; recompila para embeberlos", n, *out)
+		return nil
+
+	case *query != "":
+		list := internal.SearchCPV(*query)
+		if *asJSON {
+			return printJSON(list)
+		}
+		for _, c := range list {
+			fmt.Printf("%s  %s\n", c, c.ES)
+		}
+		return nil
+
+	case fs.NArg() == 0:
+		list := internal.CPVDivisions()
+		if *asJSON {
+			return printJSON(list)
+		}
+		for _, c := range list {
+			fmt.Printf("%s  %s\n", c, c.ES)
+		}
+		if !internal.CPVFullList() {
+			fmt.Println("\n(la lista embebida sólo tiene las divisiones; ver cpv -import)")
+		}
+		return nil
+	}
+
+	type cpvInfo struct {
+		*internal.CPV
+		Ancestors []*internal.CPV `json:"ancestors"`
+		Children  []*internal.CPV `json:"children"`
+	}
+	var infos []cpvInfo
+	for _, arg := range fs.Args() {
+		code, err := internal.ParseCPV(arg)
+		if err != nil {
+			return err
+		}
+		c := internal.LookupCPV(code)
+		if c == nil {
+			// Fuera de la lista embebida (que no es la completa): se muestra
+			// con su antecesor más cercano.
+			fmt.Printf("%s  (no está en la lista embebida; dentro de: %s)\n", code, internal.CPVName(code))
+			continue
+		}
+		infos = append(infos, cpvInfo{CPV: c, Ancestors: c.Ancestors(), Children: c.Children()})
+	}
+	if *asJSON {
+		return printJSON(infos)
+	}
+	for _, in := range infos {
+		for i, a := range in.Ancestors {
+			fmt.Printf("%s%s  %s\n", strings.Repeat("  ", i), a, a.ES)
+		}
+		ind := strings.Repeat("  ", len(in.Ancestors))
+		fmt.Printf("%s%s  %s\n%s            %s\n", ind, in.CPV, in.ES, ind, in.EN)
+		for _, ch := range in.Children {
+			fmt.Printf("%s  %s  %s\n", ind, ch, ch.ES)
+		}
+	}
+	return nil
+}
+
+// geo [-json] código...: NUTS ("ES523") o código postal ("46701")
+func geo(args []string) error {
+	fs := flag.NewFlagSet("geo", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "salida en JSON")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return usagef("geo [-json] nuts-or-postal-code...")
+	}
+
+	var list []*internal.Geo
+	for _, arg := range fs.Args() {
+		g := internal.GeolocateNUTS(arg)
+		if g == nil {
+			g = internal.GeolocatePostal(arg, "ES")
+		}
+		if g == nil {
+			return fmt.Errorf("%q no es un código NUTS 2021 conocido ni un código postal español", arg)
+		}
+		list = append(list, g)
+	}
+	if *asJSON {
+		return printJSON(list)
+	}
+	for _, g := range list {
+		fmt.Printf("%s  %s", g.NUTS, g.NUTSName)
+		if g.Province != "" {
+			fmt.Printf("  provincia: %s", g.Province)
+		}
+		if g.Region != "" {
+			fmt.Printf("  comunidad: %s", g.Region)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// config [-json] [-crontab]
+// Valida el fichero de -config y muestra el perfil elegido con los secretos
+// tapados. Sin -profile y con varios perfiles, los valida todos. -crontab
+// escribe las tareas programadas en formato crontab.
+func config(args []string) error {
+	fs := flag.NewFlagSet("config", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "salida en JSON")
+	crontab := fs.Bool("crontab", false, "escribir las tareas de schedules como líneas de crontab")
+	fs.Parse(args)
+	if fs.NArg() != 0 || cfg == nil || (*asJSON && *crontab) {
+		return usagef("-config file [-profile name] config [-json | -crontab]")
+	}
+	if profile == nil {
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+		if *crontab {
+			for _, name := range cfg.ProfileNames() {
+				p, _ := cfg.Profile(name)
+				printCrontab(p)
+			}
+			return nil
+		}
+		fmt.Printf("%s: %d perfiles correctos: %s\n", configPath, len(cfg.Profiles), strings.Join(cfg.ProfileNames(), ", "))
+		return nil
+	}
+	if *crontab {
+		printCrontab(profile)
+		return nil
+	}
+
+	p := profile.Redacted()
+	if *asJSON {
+		return printJSON(p)
+	}
+	fmt.Printf("%s: perfil %q correcto\n", configPath, p.Name)
+	for _, s := range p.Sources {
+		fmt.Printf("  fuente   %s -> %s\n", s.URL, s.Dir)
+	}
+	if expr := p.FilterExpr(); expr != "" {
+		fmt.Printf("  filtro   %s\n", expr)
+	}
+	for _, o := range p.Outputs {
+		fmt.Printf("  salida   %s %s\n", o.Type, o.Path)
+	}
+	for _, n := range p.Notifiers {
+		fmt.Printf("  aviso    %s %s%s\n", n.Type, n.URL, strings.Join(n.To, ", "))
+	}
+	for _, sc := range p.Schedules {
+		fmt.Printf("  programa %s %s%s\n", sc.Task, sc.Cron, sc.Every)
+	}
+	return nil
+}
+
+// printCrontab escribe una línea de crontab por tarea de p. Un every que no
+// cabe en cron sale comentado, para que lo programe otra herramienta.
+func printCrontab(p *internal.Profile) {
+	bin, err := os.Executable()
+	if err != nil {
+		bin = "licitaciones"
+	}
+	cfgFile, err := filepath.Abs(configPath)
+	if err != nil {
+		cfgFile = configPath
+	}
+	for _, sc := range p.Schedules {
+		cmd := fmt.Sprintf("%s -config %s -profile %s %s", shellQuote(bin), shellQuote(cfgFile), shellQuote(p.Name), sc.Task)
+		if spec, ok := sc.CronSpec(); ok {
+			fmt.Printf("%s %s\n", spec, cmd)
+		} else {
+			fmt.Printf("# cada %s, no cabe en cron: %s\n", sc.Every, cmd)
+		}
+	}
+}
+
+// shellQuote entrecomilla s para sh si hace falta.
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t'\"\\$`;&|<>()*?[]#~%") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// searches add|list|rm|run|history [-file searches.json] [flags]
+func searches(args []string) error {
+	const usage = "searches add|list|rm|run|history [-file file] [flags] (see licitaciones searches <verb> -h)"
+	if len(args) == 0 {
+		return usagef(usage)
+	}
+	verb, args := args[0], args[1:]
+	fs := flag.NewFlagSet("searches "+verb, flag.ExitOnError)
+	file := fs.String("file", "searches.json", "fichero de búsquedas guardadas e historial")
+	user := fs.String("user", "", "usuario")
+	asJSON := fs.Bool("json", false, "salida en JSON")
+
+	switch verb {
+	case "add":
+		name := fs.String("name", "", "nombre de la búsqueda (único por usuario)")
+		cpv := fs.String("cpv", "", "prefijos CPV, separados por comas")
+		nuts := fs.String("nuts", "", "prefijos NUTS, separados por comas")
+		keywords := fs.String("q", "", `palabras (todas obligatorias), "frases" y -exclusiones`)
+		minAmount := fs.Float64("min", 0, "presupuesto mínimo sin impuestos")
+		maxAmount := fs.Float64("max", 0, "presupuesto máximo sin impuestos")
+		status := fs.String("status", "", "estados (PUB, EV, ADJ, RES…), separados por comas")
+		where := fs.String("where", "", "expresión de filtro (ver README)")
+		fs.Parse(args)
+		if fs.NArg() != 0 || *user == "" || *name == "" {
+			return usagef("searches add -user name -name name [-cpv prefixes] [-nuts prefixes] [-q keywords] [-min amount] [-max amount] [-status codes] [-where expr] [-file file]")
+		}
+		book, err := internal.LoadAlertBook(*file)
+		if err != nil {
+			return err
+		}
+		s, err := book.Add(internal.SavedSearch{
+			User:      *user,
+			Name:      *name,
+			CPV:       splitList(*cpv),
+			NUTS:      splitList(*nuts),
+			Keywords:  *keywords,
+			MinAmount: *minAmount,
+			MaxAmount: *maxAmount,
+			Status:    splitList(*status),
+			Where:     *where,
+			Created:   time.Now().UTC(),
+		})
+		if err != nil {
+			return err
+		}
+		if err := book.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("búsqueda %s guardada en %s\n", s.ID, *file)
+		return nil
+
+	case "list":
+		fs.Parse(args)
+		if fs.NArg() != 0 {
+			return usagef("searches list [-user name] [-json] [-file file]")
+		}
+		book, err := internal.LoadAlertBook(*file)
+		if err != nil {
+			return err
+		}
+		list := book.ForUser(*user)
+		if *asJSON {
+			return printJSON(list)
+		}
+		for _, s := range list {
+			var parts []string
+			if len(s.CPV) > 0 {
+				parts = append(parts, "cpv="+strings.Join(s.CPV, ","))
+			}
+			if len(s.NUTS) > 0 {
+				parts = append(parts, "nuts="+strings.Join(s.NUTS, ","))
+			}
+			if s.Keywords != "" {
+				parts = append(parts, fmt.Sprintf("q=%q", s.Keywords))
+			}
+			if s.MinAmount > 0 || s.MaxAmount > 0 {
+				parts = append(parts, fmt.Sprintf("importe=%.2f..%.2f", s.MinAmount, s.MaxAmount))
+			}
+			if len(s.Status) > 0 {
+				parts = append(parts, "status="+strings.Join(s.Status, ","))
+			}
+			if s.Where != "" {
+				parts = append(parts, fmt.Sprintf("where=%q", s.Where))
+			}
+			fmt.Printf("%-30s %s\n", s.ID, strings.Join(parts, " "))
+		}
+		return nil
+
+	case "rm":
+		fs.Parse(args)
+		if fs.NArg() != 1 {
+			return usagef("searches rm [-file file] user/name")
+		}
+		book, err := internal.LoadAlertBook(*file)
+		if err != nil {
+			return err
+		}
+		if !book.Remove(fs.Arg(0)) {
+			return fmt.Errorf("no hay ninguna búsqueda %s en %s", fs.Arg(0), *file)
+		}
+		return book.Save()
+
+	case "run":
+		dataDir := dataFlag(fs)
+		workers := workersFlag(fs)
+		fs.Parse(args)
+		if fs.NArg() != 0 {
+			return usagef("searches run [-data dir] [-user name] [-json] [-file file]")
+		}
+		book, err := internal.LoadAlertBook(*file)
+		if err != nil {
+			return err
+		}
+		ctx := context.Background()
+		matches, err := book.Run(ctx, *dataDir, *workers, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		if err := book.Save(); err != nil {
+			return err
+		}
+		if *user != "" {
+			matches = slices.DeleteFunc(matches, func(m internal.SearchMatch) bool { return m.User != *user })
+		}
+		if *asJSON {
+			if err := printJSON(matches); err != nil {
+				return err
+			}
+		} else {
+			printMatches(matches)
+		}
+		return notifyMatches(ctx, matches)
+
+	case "history":
+		search := fs.String("search", "", "sólo los avisos de esta búsqueda (usuario/nombre)")
+		fs.Parse(args)
+		if fs.NArg() != 0 {
+			return usagef("searches history [-user name] [-search user/name] [-json] [-file file]")
+		}
+		book, err := internal.LoadAlertBook(*file)
+		if err != nil {
+			return err
+		}
+		ms := book.History(*user, *search)
+		if *asJSON {
+			return printJSON(ms)
+		}
+		for _, m := range ms {
+			fmt.Printf("%s  %-30s %-4s %s\n        %s\n", m.MatchedAt.Format("2006-01-02 15:04"), m.SearchID, statusChange(m), m.Title, m.EntryID)
+		}
+		return nil
+	}
+	return usagef(usage)
+}
+
+func statusChange(m internal.SearchMatch) string {
+	if m.PrevStatus == "" {
+		return m.Status
+	}
+	return m.PrevStatus + "->" + m.Status
+}
+
+func printMatches(ms []internal.SearchMatch) {
+	for _, m := range ms {
+		log.Printf("[ALERT] %s %s %s %s", m.SearchID, statusChange(m), m.EntryID, m.Title)
+	}
+	log.Printf("[done] %d avisos", len(ms))
+}
+
+// notifyMatches manda los avisos a los notificadores del perfil, si hay.
+func notifyMatches(ctx context.Context, ms []internal.SearchMatch) error {
+	if profile == nil || len(ms) == 0 {
+		return nil
+	}
+	n, err := profile.Notifier()
+	if err != nil || n == nil {
+		return err
+	}
+	events := make([]internal.Event, len(ms))
+	for i := range ms {
+		events[i] = ms[i].Event()
+	}
+	return n.Notify(ctx, events)
+}
+
+func profileOutput(typ string) *internal.Output {
+	if profile == nil {
+		return nil
+	}
+	return profile.Output(typ)
+}
+
+// drift [-data dir] [-baseline informe.json] [-examples n] [-where expr] [-out fichero]
+func drift(args []string) error {
+	fs := flag.NewFlagSet("drift", flag.ExitOnError)
+	dataDir := dataFlag(fs)
+	baselinePath := fs.String("baseline", "", "informe anterior; lo que no estaba se marca como nuevo")
+	examples := fs.Int("examples", 5, "IDs de ejemplo por ruta o versión")
+	out := fs.String("out", "drift.json", "fichero JSON de salida (- = stdout)")
+	where := whereFlag(fs)
+	workers := workersFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return usagef("drift [-data dir] [-baseline report.json] [-examples n] [-where expr] [-out file]")
+	}
+
+	filter, err := where()
+	if err != nil {
+		return err
+	}
+	var baseline *internal.DriftReport
+	if *baselinePath != "" {
+		if baseline, err = internal.LoadDriftReport(*baselinePath); err != nil {
+			return err
+		}
+	}
+	r, err := internal.DetectDrift(context.Background(), *dataDir, *workers, *examples, filter, baseline)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range r.Unmapped {
+		if p.New {
+			log.Printf("[DRIFT] nuevo elemento sin mapear %s (%d entries, p.ej. %s)", p.Path, p.Entries, strings.Join(p.Examples, " "))
+		}
+	}
+	for _, l := range r.CodeLists {
+		if l.New {
+			log.Printf("[DRIFT] nueva versión %s %s (%d usos, desde %s)", l.List, l.Version, l.Count, l.FirstFile)
+		}
+	}
+	log.Printf("[done] %d entries, %d rutas sin mapear, %d listURI distintas", r.Entries, len(r.Unmapped), len(r.CodeLists))
+	return internal.WriteJSONFile(*out, r)
+}
+
+func parseDateFlag(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fecha %q: use AAAA-MM-DD", s)
+	}
+	return t, nil
+}
+
+// loadHistory acepta un fichero atom o un ID, que se busca en el archivo. Sólo
+// se lee un fichero si ref es su ruta: un volcado antiguo en tests/ no debe
+// tapar las versiones nuevas del archivo.
+func loadHistory(ref, dataDir, since, save string) (*internal.History, error) {
+	if st, err := os.Stat(ref); err == nil && !st.IsDir() {
+		return internal.LoadHistoryFile(ref)
+	}
+
+	var createdAt time.Time
+	if since != "" {
+		var err error
+		if createdAt, err = time.Parse("2006-01-02", since); err != nil {
+			return nil, err
+		}
+	}
+	return internal.ExtractContractHistory(dataDir, ref, createdAt, save)
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -1,3 +1,9 @@
 module javierMorales9/licitaciones
 
 go 1.24.2
+
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/lib/pq v1.10.9
+	gopkg.in/yaml.v3 v3.0.1
+)
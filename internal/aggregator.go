@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Partial es el resultado parcial de un informe sobre el archivo. Cada fichero
+// se agrega en su propio parcial (ningún estado compartido entre goroutines) y
+// ScanAtoms los fusiona en el orden de la lista de ficheros, así que el
+// resultado no depende de qué worker procesó qué fichero.
+//
+// Merge debe dar lo mismo que haber llamado a Add con las entries de other
+// después de las propias.
+type Partial[P any] interface {
+	Mergeable[P]
+	Add(e *Entry, path string)
+}
+
+type Mergeable[P any] interface {
+	Merge(other P)
+}
+
+// ScanAtoms decodifica files con workers goroutines y devuelve la fusión de
+// todos los parciales. newPartial debe devolver un parcial vacío cada vez.
+func ScanAtoms[P Partial[P]](ctx context.Context, files []string, workers int, newPartial func() P) (P, error) {
+	return ScanFiles(ctx, files, workers, newPartial, func(path string, p P) error {
+		return decodeEntries(path, func(e Entry) {
+			p.Add(&e, path)
+		})
+	})
+}
+
+// ScanFiles es ScanAtoms con la lectura de cada fichero a cargo de process,
+// para informes que no trabajan sobre Entry.
+func ScanFiles[P Mergeable[P]](ctx context.Context, files []string, workers int, newPartial func() P, process func(path string, p P) error) (P, error) {
+	if workers <= 0 {
+		workers = 8
+	}
+
+	type job struct {
+		idx  int
+		path string
+	}
+	type result struct {
+		idx int
+		p   P
+	}
+	jobs := make(chan job, workers*2)
+	results := make(chan result, workers)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				pathStart := time.Now()
+				p := newPartial()
+				if err := process(j.path, p); err != nil {
+					log.Printf("[XML] %s %v", j.path, err)
+				}
+				log.Printf("Took %s to process %s", time.Since(pathStart), j.path)
+				results <- result{j.idx, p}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i, f := range files {
+			select {
+			case jobs <- job{i, f}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Fusión en orden de fichero: los parciales que llegan adelantados esperan.
+	acc := newPartial()
+	pending := make(map[int]P)
+	next := 0
+	for r := range results {
+		pending[r.idx] = r.p
+		for {
+			p, ok := pending[next]
+			if !ok {
+				break
+			}
+			acc.Merge(p)
+			delete(pending, next)
+			next++
+		}
+	}
+	return acc, ctx.Err()
+}
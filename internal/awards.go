@@ -0,0 +1,285 @@
+package internal
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AwardRecord es una adjudicación (un TenderResult con adjudicatario) con el
+// contexto de su expediente. Es la materia prima de los informes sobre
+// adjudicatarios, bajas y la red comprador–adjudicatario.
+type AwardRecord struct {
+	EntryID   string    `json:"entryId"`
+	LotID     string    `json:"lotId,omitempty"`
+	Updated   time.Time `json:"updated"`
+	Title     string    `json:"title"`
+	URL       string    `json:"url,omitempty"`
+	BuyerID   string    `json:"buyerId"` // DIR3, o NIF, o nombre normalizado
+	BuyerNIF  string    `json:"buyerNif,omitempty"`
+	BuyerName string    `json:"buyerName"`
+	NUTS      string    `json:"nuts,omitempty"` // lugar de ejecución
+	CPVs      []string  `json:"cpvs,omitempty"`
+	Procedure string    `json:"procedure,omitempty"`
+	Budget    float64   `json:"budget,omitempty"` // TaxExclusiveAmount del presupuesto del lote, o del expediente sin lotes
+
+	ResultCode   string       `json:"resultCode"`
+	Date         time.Time    `json:"date,omitzero"`
+	Bidders      int          `json:"bidders,omitempty"`
+	WinnerNIF    string       `json:"winnerNif"` // la primera de Winners
+	WinnerName   string       `json:"winnerName"`
+	Winners      []AwardParty `json:"winners"` // más de una en las UTE
+	TaxExclusive float64      `json:"taxExclusive,omitempty"`
+	Payable      float64      `json:"payable,omitempty"`
+}
+
+// AwardParty es una de las empresas adjudicatarias.
+type AwardParty struct {
+	NIF  string `json:"nif"`
+	Name string `json:"name,omitempty"`
+	NUTS string `json:"nuts,omitempty"` // domicilio
+}
+
+// awardParties devuelve las adjudicatarias con identificador de res, en el
+// orden publicado.
+func awardParties(res *TenderResult) []AwardParty {
+	var out []AwardParty
+	for i := range res.Winning {
+		w := &res.Winning[i]
+		nif := normalizeNIF(w.NIF())
+		if nif == "" || slices.ContainsFunc(out, func(p AwardParty) bool { return p.NIF == nif }) {
+			continue
+		}
+		out = append(out, AwardParty{
+			NIF:  nif,
+			Name: strings.TrimSpace(w.PartyName.Name),
+			NUTS: strings.TrimSpace(w.PhysicalLoc.NUTS.Value),
+		})
+	}
+	return out
+}
+
+// WinnerNIFs y WinnerNames unen los de todas las adjudicatarias con sep, para
+// las salidas en CSV.
+func (r *AwardRecord) WinnerNIFs(sep string) string {
+	nifs := make([]string, len(r.Winners))
+	for i, p := range r.Winners {
+		nifs[i] = p.NIF
+	}
+	return strings.Join(nifs, sep)
+}
+
+func (r *AwardRecord) WinnerNames(sep string) string {
+	names := make([]string, len(r.Winners))
+	for i, p := range r.Winners {
+		names[i] = p.Name
+	}
+	return strings.Join(names, sep)
+}
+
+// Amount es el importe adjudicado sin impuestos, o el total si no viene.
+func (r *AwardRecord) Amount() float64 {
+	if r.TaxExclusive != 0 {
+		return r.TaxExclusive
+	}
+	return r.Payable
+}
+
+func buyerID(e *Entry) string {
+	if dir3 := e.PartyID("DIR3"); dir3 != "" {
+		return "DIR3:" + strings.ToUpper(dir3)
+	}
+	if nif := e.PartyID("NIF"); nif != "" {
+		return "NIF:" + strings.ToUpper(nif)
+	}
+	return "NAME:" + strings.ToUpper(strings.Join(strings.Fields(e.OrgName()), " "))
+}
+
+// normalizeNIF quita espacios, guiones y el prefijo de país "ES".
+func normalizeNIF(nif string) string {
+	n := strings.ToUpper(strings.Join(strings.Fields(nif), ""))
+	n = strings.ReplaceAll(n, "-", "")
+	if len(n) == 11 && strings.HasPrefix(n, "ES") {
+		n = n[2:]
+	}
+	return n
+}
+
+// AwardRecords devuelve las adjudicaciones con adjudicatario de e: una por
+// resultado, con todas las empresas de la UTE si la hay.
+func AwardRecords(e *Entry) []AwardRecord {
+	var out []AwardRecord
+	for i := range e.CFS.Results {
+		res := &e.CFS.Results[i]
+		parties := awardParties(res)
+		if len(parties) == 0 {
+			continue
+		}
+		r := AwardRecord{
+			EntryID:    e.ID,
+			Updated:    e.Updated.Time,
+			Title:      strings.TrimSpace(e.Title),
+			URL:        e.URL(),
+			BuyerID:    buyerID(e),
+			BuyerNIF:   e.PartyID("NIF"),
+			BuyerName:  strings.TrimSpace(e.OrgName()),
+			NUTS:       e.NUTS(),
+			CPVs:       e.CPVs(),
+			Procedure:  strings.TrimSpace(e.CFS.Process.ProcedureCode.Value),
+			ResultCode: strings.TrimSpace(res.ResultCode.Value),
+			Bidders:    res.ReceivedTender,
+			WinnerNIF:  parties[0].NIF,
+			WinnerName: parties[0].Name,
+			Winners:    parties,
+		}
+		if res.AwardDate.Valid {
+			r.Date = res.AwardDate.Time
+		}
+		if res.Awarded != nil {
+			r.LotID = strings.TrimSpace(res.Awarded.LotID)
+			r.TaxExclusive = res.Awarded.LegalMonetaryTotal.TaxExclusive.Value
+			r.Payable = res.Awarded.LegalMonetaryTotal.Payable.Value
+		}
+		// El presupuesto es el del lote adjudicado: con lotes, el del expediente
+		// es la suma y no sirve para comparar. Sin el TaxExclusiveAmount del lote
+		// se queda a 0 y los informes que lo necesitan descartan la adjudicación.
+		if len(e.CFS.Lots) == 0 {
+			r.Budget = taxExclusiveBudget(e.CFS.Project)
+		} else if lot := e.lot(r.LotID); lot != nil {
+			if cpvs := commodityCPVs(lot.Project.Commodity); len(cpvs) > 0 {
+				r.CPVs = cpvs
+			}
+			r.Budget = taxExclusiveBudget(lot.Project)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// taxExclusiveBudget es el presupuesto base sin impuestos, sin recurrir al valor
+// estimado como hace projectBudget.
+func taxExclusiveBudget(p ProcurementProj) float64 {
+	if p.Budget == nil {
+		return 0
+	}
+	return p.Budget.TaxExclusive.Value
+}
+
+func (e *Entry) lot(id string) *ProjectLot {
+	if id == "" {
+		return nil
+	}
+	for i := range e.CFS.Lots {
+		if e.CFS.Lots[i].LotID() == id {
+			return &e.CFS.Lots[i]
+		}
+	}
+	return nil
+}
+
+// AwardSet es el Partial que reúne adjudicaciones del archivo. Cada expediente
+// aparece en muchos ficheros; de cada entry cuenta sólo su versión más
+// reciente, tenga adjudicaciones o no: si una versión posterior ya no las trae
+// (anulada, lote desierto), las anteriores se descartan. Con Where, sólo las de
+// entries cuya versión más reciente pasa el filtro.
+type AwardSet struct {
+	Where *Filter
+
+	byEntry map[string]awardVersion
+}
+
+// awardVersion son las adjudicaciones de la versión más reciente de una entry.
+type awardVersion struct {
+	updated time.Time
+	match   bool
+	records []AwardRecord
+}
+
+func NewAwardSet() *AwardSet {
+	return &AwardSet{byEntry: make(map[string]awardVersion)}
+}
+
+func (s *AwardSet) Add(e *Entry, _ string) {
+	s.put(e.ID, awardVersion{updated: e.Updated.Time, match: s.Where.Match(e), records: AwardRecords(e)})
+}
+
+func (s *AwardSet) Merge(other *AwardSet) {
+	for id, v := range other.byEntry {
+		s.put(id, v)
+	}
+}
+
+func (s *AwardSet) put(id string, v awardVersion) {
+	if old, ok := s.byEntry[id]; ok && !v.updated.After(old.updated) {
+		return
+	}
+	s.byEntry[id] = v
+}
+
+// Records devuelve las adjudicaciones ordenadas por expediente y lote.
+func (s *AwardSet) Records() []AwardRecord {
+	var out []AwardRecord
+	for _, v := range s.byEntry {
+		if v.match {
+			out = append(out, v.records...)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].EntryID != out[j].EntryID {
+			return out[i].EntryID < out[j].EntryID
+		}
+		return out[i].LotID < out[j].LotID
+	})
+	return out
+}
+
+// AwardFilter acota las adjudicaciones que entran en un informe.
+type AwardFilter struct {
+	CPV   CPVFilter
+	From  time.Time // fecha de adjudicación (o updated si no hay)
+	To    time.Time // exclusiva
+	Where *Filter   // sobre la versión más reciente de la entry
+}
+
+func (f *AwardFilter) Match(r *AwardRecord) bool {
+	if !f.CPV.MatchAny(r.CPVs) {
+		return false
+	}
+	t := r.Date
+	if t.IsZero() {
+		t = r.Updated
+	}
+	if !f.From.IsZero() && t.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !t.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+// ScanAwards lee los atom de dir y devuelve las adjudicaciones que pasan f.
+func ScanAwards(ctx context.Context, dir string, workers int, f AwardFilter) ([]AwardRecord, error) {
+	files, err := listLocalAtoms(dir)
+	if err != nil {
+		return nil, err
+	}
+	set, err := ScanAtoms(ctx, files, workers, func() *AwardSet {
+		s := NewAwardSet()
+		s.Where = f.Where
+		return s
+	})
+	if err != nil {
+		return nil, err
+	}
+	all := set.Records()
+	out := all[:0]
+	for i := range all {
+		if f.Match(&all[i]) {
+			out = append(out, all[i])
+		}
+	}
+	return out, nil
+}
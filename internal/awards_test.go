@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func parseTestEntry(t *testing.T, cfs string) *Entry {
+	t.Helper()
+	var e Entry
+	if err := xml.Unmarshal([]byte(`<entry><id>e</id><ContractFolderStatus>`+cfs+`</ContractFolderStatus></entry>`), &e); err != nil {
+		t.Fatal(err)
+	}
+	return &e
+}
+
+const testAward = `<TenderResult><ResultCode>8</ResultCode>
+  <WinningParty><PartyIdentification><ID schemeName="NIF">B12345678</ID></PartyIdentification></WinningParty>
+  <AwardedTenderedProject>%s<LegalMonetaryTotal><TaxExclusiveAmount>800</TaxExclusiveAmount></LegalMonetaryTotal></AwardedTenderedProject>
+</TenderResult>`
+
+// El presupuesto de una adjudicación es el TaxExclusiveAmount de su lote, o del
+// expediente si no hay lotes; nunca el valor estimado ni el del expediente con lotes.
+func TestAwardRecordBudget(t *testing.T) {
+	award := func(lot string) string {
+		if lot != "" {
+			lot = `<ProcurementProjectLotID>` + lot + `</ProcurementProjectLotID>`
+		}
+		return fmt.Sprintf(testAward, lot)
+	}
+	cases := []struct {
+		name string
+		cfs  string
+		want float64
+	}{
+		{"sin lotes", `<ProcurementProject><BudgetAmount><TaxExclusiveAmount>1000</TaxExclusiveAmount></BudgetAmount></ProcurementProject>` + award(""), 1000},
+		{"sin lotes, sólo estimado", `<ProcurementProject><BudgetAmount><EstimatedOverallContractAmount>1000</EstimatedOverallContractAmount></BudgetAmount></ProcurementProject>` + award(""), 0},
+		{"lote con presupuesto", `<ProcurementProject><BudgetAmount><TaxExclusiveAmount>5000</TaxExclusiveAmount></BudgetAmount></ProcurementProject>
+			<ProcurementProjectLot><ID>1</ID><ProcurementProject><BudgetAmount><TaxExclusiveAmount>900</TaxExclusiveAmount></BudgetAmount></ProcurementProject></ProcurementProjectLot>` + award("1"), 900},
+		{"lote sin presupuesto", `<ProcurementProject><BudgetAmount><TaxExclusiveAmount>5000</TaxExclusiveAmount></BudgetAmount></ProcurementProject>
+			<ProcurementProjectLot><ID>1</ID><ProcurementProject/></ProcurementProjectLot>` + award("1"), 0},
+		{"con lotes, adjudicación sin lote", `<ProcurementProject><BudgetAmount><TaxExclusiveAmount>5000</TaxExclusiveAmount></BudgetAmount></ProcurementProject>
+			<ProcurementProjectLot><ID>1</ID><ProcurementProject/></ProcurementProjectLot>` + award(""), 0},
+	}
+	for _, c := range cases {
+		rs := AwardRecords(parseTestEntry(t, c.cfs))
+		if len(rs) != 1 {
+			t.Fatalf("%s: %d adjudicaciones", c.name, len(rs))
+		}
+		if rs[0].Budget != c.want {
+			t.Errorf("%s: Budget = %v, want %v", c.name, rs[0].Budget, c.want)
+		}
+		if d := Discounts(rs); (len(d) == 1) != (c.want > 0) {
+			t.Errorf("%s: %d bajas", c.name, len(d))
+		}
+	}
+}
+
+// Una UTE es una adjudicación con todas sus empresas: cada una figura como
+// adjudicataria, pero el importe no se duplica en las bajas ni en el órgano.
+func TestAwardRecordsJointVenture(t *testing.T) {
+	e := parseTestEntry(t, `<TenderResult><ResultCode>8</ResultCode>
+  <WinningParty><PartyIdentification><ID schemeName="NIF">B11111111</ID></PartyIdentification><PartyName><Name>Uno SL</Name></PartyName></WinningParty>
+  <WinningParty><PartyIdentification><ID>ES-B22222222</ID></PartyIdentification><PartyName><Name>Dos SA</Name></PartyName><PhysicalLocation><CountrySubentityCode>ES523</CountrySubentityCode></PhysicalLocation></WinningParty>
+  <AwardedTenderedProject><LegalMonetaryTotal><TaxExclusiveAmount>800</TaxExclusiveAmount></LegalMonetaryTotal></AwardedTenderedProject>
+</TenderResult>`)
+	rs := AwardRecords(e)
+	if len(rs) != 1 {
+		t.Fatalf("%d adjudicaciones, want 1", len(rs))
+	}
+	want := []AwardParty{{NIF: "B11111111", Name: "Uno SL"}, {NIF: "B22222222", Name: "Dos SA", NUTS: "ES523"}}
+	if !slices.Equal(rs[0].Winners, want) || rs[0].WinnerNIF != "B11111111" {
+		t.Fatalf("Winners = %+v, WinnerNIF = %q", rs[0].Winners, rs[0].WinnerNIF)
+	}
+
+	sup := BuildSuppliers(rs)
+	if len(sup) != 2 || sup[0].TaxExclusive != 800 || sup[1].TaxExclusive != 800 {
+		t.Errorf("BuildSuppliers = %+v", sup)
+	}
+	net := BuildNetwork(rs)
+	if len(net.Edges) != 2 {
+		t.Errorf("%d aristas, want 2", len(net.Edges))
+	}
+	for _, n := range net.Nodes {
+		if n.Kind == NodeBuyer && n.Amount != 800 {
+			t.Errorf("órgano con %v, want 800", n.Amount)
+		}
+	}
+
+	f, err := CompileFilter(`winner_nif = "B22222222"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Match(e) {
+		t.Error("winner_nif no encuentra a la segunda empresa de la UTE")
+	}
+}
+
+// Una versión posterior sin adjudicación (anulada) deja fuera la adjudicación de
+// la anterior, llegue en el orden que llegue.
+func TestAwardSetStaleVersion(t *testing.T) {
+	v1 := parseTestEntry(t, fmt.Sprintf(testAward, ""))
+	v1.Updated.Time = time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	v2 := parseTestEntry(t, `<TenderResult><ResultCode>4</ResultCode></TenderResult>`)
+	v2.Updated.Time = v1.Updated.Time.AddDate(0, 1, 0)
+	if len(AwardRecords(v1)) != 1 || len(AwardRecords(v2)) != 0 {
+		t.Fatalf("AwardRecords: %d y %d, want 1 y 0", len(AwardRecords(v1)), len(AwardRecords(v2)))
+	}
+
+	s := NewAwardSet()
+	s.Add(v1, "")
+	s.Add(v2, "")
+	if rs := s.Records(); len(rs) != 0 {
+		t.Errorf("v1 y luego v2: %d adjudicaciones, want 0", len(rs))
+	}
+
+	a, b := NewAwardSet(), NewAwardSet()
+	a.Add(v2, "")
+	b.Add(v1, "")
+	a.Merge(b)
+	if rs := a.Records(); len(rs) != 0 {
+		t.Errorf("v2 y luego v1: %d adjudicaciones, want 0", len(rs))
+	}
+}
@@ -0,0 +1,235 @@
+package internal
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Modelo JSON canónico de una entry: lo que devuelve la API, plano y con nombres
+// estables, sin la estructura del CODICE. Los campos vacíos se omiten.
+
+type CanonicalParty struct {
+	Name       string `json:"name"`
+	NIF        string `json:"nif,omitempty"`
+	DIR3       string `json:"dir3,omitempty"`
+	ProfileURL string `json:"profileUrl,omitempty"`
+	Website    string `json:"website,omitempty"`
+	City       string `json:"city,omitempty"`
+	PostalCode string `json:"postalCode,omitempty"`
+	Address    string `json:"address,omitempty"`
+	Country    string `json:"country,omitempty"`
+	Email      string `json:"email,omitempty"`
+	Phone      string `json:"phone,omitempty"`
+	Geo        *Geo   `json:"geo,omitempty"` // por el código postal
+}
+
+type CanonicalPeriod struct {
+	Duration  int       `json:"duration,omitempty"`
+	Unit      string    `json:"unit,omitempty"` // DAY, MON o ANN
+	StartDate time.Time `json:"startDate,omitzero"`
+	EndDate   time.Time `json:"endDate,omitzero"`
+}
+
+type CanonicalLot struct {
+	ID     string           `json:"id"`
+	Name   string           `json:"name,omitempty"`
+	CPVs   []string         `json:"cpvs,omitempty"`
+	NUTS   string           `json:"nuts,omitempty"`
+	Budget float64          `json:"budget,omitempty"`
+	Period *CanonicalPeriod `json:"period,omitempty"`
+}
+
+type CanonicalResult struct {
+	LotID        string    `json:"lotId,omitempty"`
+	ResultCode   string    `json:"resultCode"`
+	AwardDate    time.Time `json:"awardDate,omitzero"`
+	Bidders      int       `json:"bidders,omitempty"`
+	LowerTender  float64   `json:"lowerTender,omitempty"`
+	HigherTender float64   `json:"higherTender,omitempty"`
+	WinnerNIF    string    `json:"winnerNif,omitempty"`
+	WinnerName   string    `json:"winnerName,omitempty"`
+	WinnerGeo    *Geo      `json:"winnerGeo,omitempty"`
+	TaxExclusive float64   `json:"taxExclusive,omitempty"`
+	Payable      float64   `json:"payable,omitempty"`
+}
+
+type CanonicalEntry struct {
+	ID        string            `json:"id"`
+	FolderID  string            `json:"folderId,omitempty"`
+	Title     string            `json:"title"`
+	Summary   string            `json:"summary,omitempty"`
+	URL       string            `json:"url,omitempty"`
+	Status    string            `json:"status"`
+	Updated   time.Time         `json:"updated"`
+	Published time.Time         `json:"published,omitzero"`
+	Deadline  time.Time         `json:"deadline,omitzero"`
+	Buyer     CanonicalParty    `json:"buyer"`
+	Type      string            `json:"type,omitempty"`
+	Procedure string            `json:"procedure,omitempty"`
+	Urgency   string            `json:"urgency,omitempty"`
+	CPVs      []string          `json:"cpvs,omitempty"`
+	NUTS      string            `json:"nuts,omitempty"`
+	Geo       *Geo              `json:"geo,omitempty"` // ver Entry.Geo
+	Budget    float64           `json:"budget,omitempty"`
+	Period    *CanonicalPeriod  `json:"period,omitempty"`
+	Lots      []CanonicalLot    `json:"lots,omitempty"`
+	Results   []CanonicalResult `json:"results,omitempty"`
+	Documents []Document        `json:"documents,omitempty"`
+}
+
+func canonicalPeriod(p *PlannedPeriod) *CanonicalPeriod {
+	if p == nil {
+		return nil
+	}
+	out := &CanonicalPeriod{Duration: p.Duration.Value, Unit: strings.TrimSpace(p.Duration.UnitCode)}
+	if p.StartDate.Valid {
+		out.StartDate = p.StartDate.Time
+	}
+	if p.EndDate.Valid {
+		out.EndDate = p.EndDate.Time
+	}
+	if *out == (CanonicalPeriod{}) {
+		return nil
+	}
+	return out
+}
+
+// CanonicalBuyer devuelve el órgano de contratación de e.
+func CanonicalBuyer(e *Entry) CanonicalParty {
+	lp := &e.CFS.LocatedParty
+	p := CanonicalParty{
+		Name:       strings.TrimSpace(lp.Party.PartyName.Name),
+		NIF:        e.PartyID("NIF"),
+		DIR3:       e.PartyID("DIR3"),
+		ProfileURL: strings.TrimSpace(lp.BuyerProfileURIID),
+		Website:    strings.TrimSpace(lp.Party.WebsiteURI),
+	}
+	if a := lp.Party.PostalAddress; a != nil {
+		p.City = strings.TrimSpace(a.City)
+		p.PostalCode = strings.TrimSpace(a.PostalZone)
+		p.Address = strings.TrimSpace(a.Line)
+		p.Country = strings.TrimSpace(a.Country.Code.Value)
+		p.Geo = addressGeo(a)
+	}
+	if c := lp.Party.Contact; c != nil {
+		p.Email = strings.TrimSpace(c.Mail)
+		p.Phone = strings.TrimSpace(c.Phone)
+	}
+	return p
+}
+
+// NewCanonicalEntry pasa e al modelo canónico.
+func NewCanonicalEntry(e *Entry) CanonicalEntry {
+	c := CanonicalEntry{
+		ID:        e.ID,
+		FolderID:  strings.TrimSpace(e.CFS.ContractFolderID),
+		Title:     strings.TrimSpace(e.Title),
+		Summary:   strings.TrimSpace(e.Summary),
+		URL:       e.URL(),
+		Status:    e.Status(),
+		Updated:   e.Updated.Time,
+		Published: e.PublishedDate(),
+		Deadline:  e.Deadline(),
+		Buyer:     CanonicalBuyer(e),
+		Type:      strings.TrimSpace(e.CFS.Project.TypeCode.Value),
+		Procedure: strings.TrimSpace(e.CFS.Process.ProcedureCode.Value),
+		Urgency:   strings.TrimSpace(e.CFS.Process.UrgencyCode.Value),
+		CPVs:      e.CPVs(),
+		NUTS:      e.NUTS(),
+		Geo:       e.Geo(),
+		Budget:    e.Budget(),
+		Period:    canonicalPeriod(e.CFS.Project.Planned),
+		Documents: e.Documents(),
+	}
+	for i := range e.CFS.Lots {
+		l := &e.CFS.Lots[i]
+		lot := CanonicalLot{
+			ID:     l.LotID(),
+			Name:   strings.TrimSpace(l.Project.Name),
+			CPVs:   commodityCPVs(l.Project.Commodity),
+			Budget: projectBudget(l.Project),
+			Period: canonicalPeriod(l.Project.Planned),
+		}
+		if l.Project.Location != nil {
+			lot.NUTS = strings.TrimSpace(l.Project.Location.NUTS.Value)
+		}
+		c.Lots = append(c.Lots, lot)
+	}
+	for i := range e.CFS.Results {
+		r := &e.CFS.Results[i]
+		res := CanonicalResult{
+			ResultCode:   strings.TrimSpace(r.ResultCode.Value),
+			Bidders:      r.ReceivedTender,
+			LowerTender:  r.LowerTender.Value,
+			HigherTender: r.HigherTender.Value,
+			WinnerNIF:    normalizeNIF(r.WinnerNIF()),
+			WinnerName:   strings.TrimSpace(r.WinnerName()),
+		}
+		if len(r.Winning) > 0 {
+			res.WinnerGeo = r.Winning[0].Geo()
+		}
+		if r.AwardDate.Valid {
+			res.AwardDate = r.AwardDate.Time
+		}
+		if r.Awarded != nil {
+			res.LotID = strings.TrimSpace(r.Awarded.LotID)
+			res.TaxExclusive = r.Awarded.LegalMonetaryTotal.TaxExclusive.Value
+			res.Payable = r.Awarded.LegalMonetaryTotal.Payable.Value
+		}
+		c.Results = append(c.Results, res)
+	}
+	return c
+}
+
+// WriteEntries guarda entries en format: jsonl (una por línea), json (un array)
+// o csv (una fila por entry, sin lotes ni resultados).
+func WriteEntries(path, format string, entries []CanonicalEntry) error {
+	switch format {
+	case "json":
+		return WriteJSONFile(path, entries)
+	case "jsonl":
+		return writeFileAtomic(path, func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			for i := range entries {
+				if err := enc.Encode(&entries[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case "csv":
+		return writeFileAtomic(path, func(f io.Writer) error {
+			w := csv.NewWriter(f)
+			_ = w.Write([]string{
+				"id", "folder_id", "title", "status", "updated", "published", "deadline", "buyer_name", "buyer_nif",
+				"buyer_dir3", "type", "procedure", "cpvs", "nuts", "province", "region", "budget", "lots", "winners", "url",
+			})
+			for _, e := range entries {
+				var winners []string
+				for _, r := range e.Results {
+					if r.WinnerNIF != "" && !slices.Contains(winners, r.WinnerNIF) {
+						winners = append(winners, r.WinnerNIF)
+					}
+				}
+				var province, region string
+				if e.Geo != nil {
+					province, region = e.Geo.Province, e.Geo.Region
+				}
+				_ = w.Write([]string{
+					e.ID, e.FolderID, e.Title, e.Status, e.Updated.Format(time.RFC3339), dateOrEmpty(e.Published), dateOrEmpty(e.Deadline),
+					e.Buyer.Name, e.Buyer.NIF, e.Buyer.DIR3, e.Type, e.Procedure, strings.Join(e.CPVs, "|"), e.NUTS, province, region, fixed2(e.Budget),
+					strconv.Itoa(len(e.Lots)), strings.Join(winners, "|"), e.URL,
+				})
+			}
+			w.Flush()
+			return w.Error()
+		})
+	}
+	return fmt.Errorf("formato desconocido %q (jsonl, json o csv)", format)
+}
@@ -0,0 +1,233 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Catalog es la vista en memoria del archivo que sirve la API: la versión más
+// reciente de cada entry y los ficheros donde aparece, para reconstruir su
+// historial bajo demanda sin guardar todas las versiones.
+
+// catalogPartial es el Partial de LoadCatalog.
+type catalogPartial struct {
+	latest map[string]Entry
+	files  map[string][]string
+}
+
+func newCatalogPartial() *catalogPartial {
+	return &catalogPartial{latest: make(map[string]Entry), files: make(map[string][]string)}
+}
+
+func (p *catalogPartial) Add(e *Entry, file string) {
+	p.put(*e)
+	if fs := p.files[e.ID]; len(fs) == 0 || fs[len(fs)-1] != file {
+		p.files[e.ID] = append(fs, file)
+	}
+}
+
+func (p *catalogPartial) Merge(other *catalogPartial) {
+	for _, e := range other.latest {
+		p.put(e)
+	}
+	for id, fs := range other.files {
+		p.files[id] = append(p.files[id], fs...)
+	}
+}
+
+func (p *catalogPartial) put(e Entry) {
+	if old, ok := p.latest[e.ID]; ok && !e.Updated.After(old.Updated.Time) {
+		return
+	}
+	p.latest[e.ID] = e
+}
+
+type Catalog struct {
+	latest map[string]*Entry
+	files  map[string][]string
+	short  map[string][]string // último segmento del ID -> IDs; más de uno choca
+	order  []*Entry            // por updated descendente
+	awards []AwardRecord
+}
+
+// LoadCatalog lee los atom de dir.
+func LoadCatalog(ctx context.Context, dir string, workers int) (*Catalog, error) {
+	files, err := listLocalAtoms(dir)
+	if err != nil {
+		return nil, err
+	}
+	p, err := ScanAtoms(ctx, files, workers, newCatalogPartial)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Catalog{
+		latest: make(map[string]*Entry, len(p.latest)),
+		files:  p.files,
+		short:  make(map[string][]string, len(p.latest)),
+	}
+	for id, e := range p.latest {
+		c.latest[id] = &e
+		c.short[path.Base(id)] = append(c.short[path.Base(id)], id)
+		c.order = append(c.order, &e)
+		c.awards = append(c.awards, AwardRecords(&e)...)
+	}
+	sort.Slice(c.order, func(i, j int) bool {
+		if !c.order[i].Updated.Equal(c.order[j].Updated.Time) {
+			return c.order[i].Updated.After(c.order[j].Updated.Time)
+		}
+		return c.order[i].ID < c.order[j].ID
+	})
+	return c, nil
+}
+
+func (c *Catalog) Len() int {
+	return len(c.order)
+}
+
+// AmbiguousIDError es un último segmento que comparten varias entries (de
+// plataformas distintas): hace falta el ID completo.
+type AmbiguousIDError struct {
+	ID  string
+	IDs []string
+}
+
+func (e *AmbiguousIDError) Error() string {
+	return fmt.Sprintf("el ID %q es de %d entries, usa el ID completo: %s", e.ID, len(e.IDs), strings.Join(e.IDs, ", "))
+}
+
+// Get acepta el ID completo o su último segmento (el número del expediente en
+// la URL de sindicación). nil si no existe, y un *AmbiguousIDError si el
+// segmento no identifica una sola entry.
+func (c *Catalog) Get(id string) (*Entry, error) {
+	if e, ok := c.latest[id]; ok {
+		return e, nil
+	}
+	switch full := c.short[id]; len(full) {
+	case 0:
+		return nil, nil
+	case 1:
+		return c.latest[full[0]], nil
+	default:
+		return nil, &AmbiguousIDError{ID: id, IDs: slices.Sorted(slices.Values(full))}
+	}
+}
+
+// History relee los ficheros donde aparece la entry y devuelve sus versiones.
+func (c *Catalog) History(e *Entry) (*History, error) {
+	var versions []Entry
+	for _, f := range c.files[e.ID] {
+		err := decodeEntries(f, func(v Entry) {
+			if v.ID == e.ID {
+				versions = append(versions, v)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewHistory(e.ID, versions), nil
+}
+
+type EntryQuery struct {
+	Filter SearchFilter
+	Where  *Filter
+	Limit  int
+	Offset int
+}
+
+type EntryPage struct {
+	Total   int              `json:"total"`
+	Entries []CanonicalEntry `json:"entries"`
+}
+
+// Entries devuelve las entries que pasan q, las más recientes primero.
+func (c *Catalog) Entries(q EntryQuery) EntryPage {
+	return c.page(c.order, q)
+}
+
+func (c *Catalog) page(entries []*Entry, q EntryQuery) EntryPage {
+	out := EntryPage{Entries: []CanonicalEntry{}}
+	for _, e := range entries {
+		d := NewIndexDoc(e)
+		if !q.Filter.Match(&d) || !q.Where.Match(e) {
+			continue
+		}
+		if out.Total >= q.Offset && (q.Limit <= 0 || len(out.Entries) < q.Limit) {
+			out.Entries = append(out.Entries, NewCanonicalEntry(e))
+		}
+		out.Total++
+	}
+	return out
+}
+
+// PartyProfile es un órgano de contratación con sus entries.
+type PartyProfile struct {
+	Party     CanonicalParty `json:"party"`
+	Tenders   int            `json:"tenders"`
+	Budget    float64        `json:"budget"`  // suma de presupuestos sin impuestos
+	Awarded   float64        `json:"awarded"` // suma de importes adjudicados
+	Suppliers []KeyCount     `json:"suppliers"`
+	EntryPage
+}
+
+func sameParty(e *Entry, id string) bool {
+	id = normalizeNIF(id)
+	return normalizeNIF(e.PartyID("NIF")) == id || strings.EqualFold(strings.TrimSpace(e.PartyID("DIR3")), id)
+}
+
+// Party busca el órgano por NIF (o DIR3). nil si no tiene entries.
+func (c *Catalog) Party(nif string, q EntryQuery) *PartyProfile {
+	var entries []*Entry
+	for _, e := range c.order {
+		if sameParty(e, nif) {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	p := &PartyProfile{Party: CanonicalBuyer(entries[0]), Tenders: len(entries)}
+	suppliers := make(map[string]int)
+	for _, e := range entries {
+		p.Budget += e.Budget()
+		for _, r := range AwardRecords(e) {
+			p.Awarded += r.Amount()
+			for _, w := range r.Winners {
+				suppliers[w.NIF]++
+			}
+		}
+	}
+	p.Suppliers = keyCounts(suppliers)
+	p.EntryPage = c.page(entries, q)
+	return p
+}
+
+// WinnerProfile es un adjudicatario con sus adjudicaciones.
+type WinnerProfile struct {
+	Supplier
+	Records []AwardRecord `json:"records"`
+}
+
+// Winner busca el adjudicatario por NIF. nil si no tiene adjudicaciones.
+func (c *Catalog) Winner(nif string) *WinnerProfile {
+	nif = normalizeNIF(nif)
+	var records []AwardRecord
+	for _, r := range c.awards {
+		if slices.ContainsFunc(r.Winners, func(p AwardParty) bool { return p.NIF == nif }) {
+			records = append(records, r)
+		}
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Updated.After(records[j].Updated)
+	})
+	return &WinnerProfile{Supplier: BuildSuppliers(records)[0], Records: records}
+}
@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Dos plataformas pueden publicar el mismo número final: el ID corto sólo vale
+// cuando es de una sola entry.
+func TestCatalogShortIDCollision(t *testing.T) {
+	dir := t.TempDir()
+	feed := `<feed>
+<entry><id>https://contrataciondelestado.es/sindicacion/licitacionesPerfilContratante/123</id><updated>2025-01-02T10:00:00+01:00</updated></entry>
+<entry><id>https://contrataciondelestado.es/sindicacion/PlataformasAgregadasSinMenores/123</id><updated>2025-01-02T10:00:00+01:00</updated></entry>
+<entry><id>https://contrataciondelestado.es/sindicacion/licitacionesPerfilContratante/456</id><updated>2025-01-02T10:00:00+01:00</updated></entry>
+</feed>`
+	if err := os.WriteFile(filepath.Join(dir, "licitacionesPerfilesContratanteCompleto3_20250102_100000.atom"), []byte(feed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadCatalog(context.Background(), dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, err := c.Get("456"); err != nil || e == nil {
+		t.Errorf("Get(456) = %v, %v", e, err)
+	}
+	full := "https://contrataciondelestado.es/sindicacion/PlataformasAgregadasSinMenores/123"
+	if e, err := c.Get(full); err != nil || e == nil || e.ID != full {
+		t.Errorf("Get(ID completo) = %v, %v", e, err)
+	}
+	var amb *AmbiguousIDError
+	if e, err := c.Get("123"); e != nil || !errors.As(err, &amb) || len(amb.IDs) != 2 {
+		t.Errorf("Get(123) = %v, %v; want AmbiguousIDError", e, err)
+	}
+
+	rec := httptest.NewRecorder()
+	(&API{Catalog: c}).Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/entries/123", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /entries/123: %d %s", rec.Code, rec.Body)
+	}
+}
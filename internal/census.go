@@ -0,0 +1,268 @@
+package internal
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Censo de elementos: cuántas veces aparece cada ruta, en cuántas entries, con
+// qué valores y en qué ficheros. Sirve para saber la cobertura real de un campo
+// a lo largo de los años antes de escribir un parser. Cada versión de una entry
+// cuenta como una entry.
+
+type CensusOptions struct {
+	// Rutas a censar: "all", una ruta completa, un patrón con * por segmento
+	// ("ContractFolderStatus/*/Name") o el último segmento ("NoticeTypeCode",
+	// "@listURI").
+	Paths []string
+	// By desglosa los valores de cada ruta por el valor de otra ruta de la misma
+	// entry (p.ej. ContractFolderStatus/ContractFolderStatusCode).
+	By          string
+	MaxDistinct int     // valores distintos guardados por ruta (1000 por defecto)
+	MaxExamples int     // IDs de ejemplo por ruta (5 por defecto)
+	Where       *Filter // sólo las entries que lo cumplen
+}
+
+func (o *CensusOptions) selected(p string) bool {
+	for _, sel := range o.Paths {
+		switch {
+		case sel == "all" || sel == p:
+			return true
+		case strings.Contains(sel, "*"):
+			if ok, _ := path.Match(sel, p); ok {
+				return true
+			}
+		case !strings.Contains(sel, "/"):
+			if strings.HasSuffix(p, "/"+sel) || p == sel {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type CensusField struct {
+	Path              string                    `json:"path"`
+	Count             int                       `json:"count"`   // apariciones
+	Entries           int                       `json:"entries"` // entries en que aparece
+	EntriesByYear     map[string]int            `json:"entriesByYear"`
+	Distinct          int                       `json:"distinct"`
+	DistinctTruncated bool                      `json:"distinctTruncated,omitempty"`
+	Values            []KeyCount                `json:"values,omitempty"` // más frecuentes
+	By                map[string]map[string]int `json:"by,omitempty"`     // valor -> valor de By -> n
+	Examples          []string                  `json:"examples"`
+	FirstFile         string                    `json:"firstFile"`
+	LastFile          string                    `json:"lastFile"`
+
+	values map[string]int
+}
+
+type Census struct {
+	Files         int                     `json:"files"`
+	Entries       int                     `json:"entries"`
+	EntriesByYear map[string]int          `json:"entriesByYear"`
+	Fields        map[string]*CensusField `json:"-"`
+
+	opts *CensusOptions
+}
+
+func newCensus(opts *CensusOptions) *Census {
+	return &Census{EntriesByYear: make(map[string]int), Fields: make(map[string]*CensusField), opts: opts}
+}
+
+func (c *Census) field(p string) *CensusField {
+	f, ok := c.Fields[p]
+	if !ok {
+		f = &CensusField{Path: p, EntriesByYear: make(map[string]int), values: make(map[string]int)}
+		c.Fields[p] = f
+	}
+	return f
+}
+
+func (f *CensusField) addFile(file string) {
+	addFileRange(&f.FirstFile, &f.LastFile, file)
+}
+
+// addFileRange amplía [first, last] con file, por la fecha de su nombre.
+func addFileRange(first, last *string, file string) {
+	if file == "" {
+		return
+	}
+	base := filepath.Base(file)
+	if *first == "" || fileBefore(base, *first) {
+		*first = base
+	}
+	if *last == "" || fileBefore(*last, base) {
+		*last = base
+	}
+}
+
+// fileBefore ordena ficheros del archivo por el timestamp del nombre, que no
+// siempre coincide con el orden alfabético (prefijos distintos, sufijo _N).
+// Sin timestamp en alguno de los dos, por nombre.
+func fileBefore(a, b string) bool {
+	ta, okA := parseTimestampFromPath(a, time.UTC)
+	tb, okB := parseTimestampFromPath(b, time.UTC)
+	if okA && okB && !ta.Equal(tb) {
+		return ta.Before(tb)
+	}
+	return a < b
+}
+
+func (f *CensusField) addValue(v string, n int, max int) {
+	if _, ok := f.values[v]; !ok && len(f.values) >= max {
+		f.DistinctTruncated = true
+		return
+	}
+	f.values[v] += n
+}
+
+func (c *Census) addEntry(e *RawEntry, file string) {
+	c.Entries++
+	year := "?"
+	if len(e.Updated) >= 4 {
+		year = e.Updated[:4]
+	}
+	c.EntriesByYear[year]++
+
+	byValue := ""
+	if c.opts.By != "" {
+		for _, n := range e.Nodes {
+			if n.Path == c.opts.By {
+				byValue = n.Value
+				break
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, n := range e.Nodes {
+		if !c.opts.selected(n.Path) {
+			continue
+		}
+		f := c.field(n.Path)
+		f.Count++
+		if n.Leaf {
+			f.addValue(n.Value, 1, c.opts.MaxDistinct)
+			if c.opts.By != "" {
+				if f.By == nil {
+					f.By = make(map[string]map[string]int)
+				}
+				if f.By[n.Value] == nil {
+					f.By[n.Value] = make(map[string]int)
+				}
+				f.By[n.Value][byValue]++
+			}
+		}
+		if seen[n.Path] {
+			continue
+		}
+		seen[n.Path] = true
+		f.Entries++
+		f.EntriesByYear[year]++
+		f.addFile(file)
+		if len(f.Examples) < c.opts.MaxExamples {
+			f.Examples = append(f.Examples, e.ID)
+		}
+	}
+}
+
+func (c *Census) Merge(other *Census) {
+	c.Files += other.Files
+	c.Entries += other.Entries
+	for y, n := range other.EntriesByYear {
+		c.EntriesByYear[y] += n
+	}
+	for _, p := range sortedKeys(other.Fields) {
+		src := other.Fields[p]
+		f := c.field(p)
+		f.Count += src.Count
+		f.Entries += src.Entries
+		for y, n := range src.EntriesByYear {
+			f.EntriesByYear[y] += n
+		}
+		for _, v := range sortedKeys(src.values) {
+			f.addValue(v, src.values[v], c.opts.MaxDistinct)
+		}
+		f.DistinctTruncated = f.DistinctTruncated || src.DistinctTruncated
+		for v, m := range src.By {
+			if f.By == nil {
+				f.By = make(map[string]map[string]int)
+			}
+			if f.By[v] == nil {
+				f.By[v] = make(map[string]int)
+			}
+			for b, n := range m {
+				f.By[v][b] += n
+			}
+		}
+		for _, id := range src.Examples {
+			if len(f.Examples) < c.opts.MaxExamples {
+				f.Examples = append(f.Examples, id)
+			}
+		}
+		if src.FirstFile != "" {
+			f.addFile(src.FirstFile)
+			f.addFile(src.LastFile)
+		}
+	}
+}
+
+// CensusReport es lo que se escribe en JSON.
+type CensusReport struct {
+	Files         int            `json:"files"`
+	Entries       int            `json:"entries"`
+	EntriesByYear map[string]int `json:"entriesByYear"`
+	Fields        []CensusField  `json:"fields"`
+}
+
+// Report ordena las rutas y deja sólo los top valores más frecuentes.
+func (c *Census) Report(top int) CensusReport {
+	r := CensusReport{Files: c.Files, Entries: c.Entries, EntriesByYear: c.EntriesByYear}
+	for _, p := range sortedKeys(c.Fields) {
+		f := *c.Fields[p]
+		f.Distinct = len(f.values)
+		f.Values = keyCounts(f.values)
+		if top >= 0 && len(f.Values) > top {
+			f.Values = f.Values[:top]
+		}
+		r.Fields = append(r.Fields, f)
+	}
+	return r
+}
+
+// RunCensus hace el censo de los atom de dir.
+func RunCensus(ctx context.Context, dir string, workers int, opts CensusOptions) (*Census, error) {
+	if opts.MaxDistinct <= 0 {
+		opts.MaxDistinct = 1000
+	}
+	if opts.MaxExamples <= 0 {
+		opts.MaxExamples = 5
+	}
+	if len(opts.Paths) == 0 {
+		opts.Paths = []string{"all"}
+	}
+
+	files, err := listLocalAtoms(dir)
+	if err != nil {
+		return nil, err
+	}
+	return ScanFiles(ctx, files, workers, func() *Census { return newCensus(&opts) },
+		func(file string, c *Census) error {
+			c.Files++
+			return decodeRawEntries(file, opts.Where, func(e *RawEntry) { c.addEntry(e, file) })
+		})
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
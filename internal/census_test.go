@@ -0,0 +1,24 @@
+package internal
+
+import "testing"
+
+// El rango de ficheros va por el timestamp del nombre: con otro prefijo o con
+// sufijo _N el orden alfabético se equivoca.
+func TestAddFileRange(t *testing.T) {
+	var first, last string
+	for _, f := range []string{
+		"data/licitacionesPerfilesContratanteCompleto3_20250814_175901.atom",
+		"data/licitacionesPerfilesContratanteCompleto3_20250814_175901_2.atom",
+		"data/PlataformasAgregadasSinMenores_20240102_080000.atom",
+		"data/licitacionesPerfilesContratanteCompleto3_20231231_235959_10.atom",
+		"data/PlataformasAgregadasSinMenores_20260101_000000.atom",
+	} {
+		addFileRange(&first, &last, f)
+	}
+	if first != "licitacionesPerfilesContratanteCompleto3_20231231_235959_10.atom" {
+		t.Errorf("first = %s", first)
+	}
+	if last != "PlataformasAgregadasSinMenores_20260101_000000.atom" {
+		t.Errorf("last = %s", last)
+	}
+}
@@ -0,0 +1,862 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Fichero de configuración con perfiles con nombre ("fuels", "vehicles leasing"…):
+// de dónde se descarga, qué se filtra, a dónde se escribe, a quién se avisa y
+// cada cuánto. Puede ser YAML o TOML (por la extensión). Los valores admiten
+// ${VAR} y ${VAR:-por defecto} para no guardar secretos en el fichero.
+//
+// Los dos formatos se leen a un árbol genérico y se decodifican aquí, no con las
+// etiquetas de cada librería, para que los errores sean los mismos y digan la
+// clave: "profiles.fuels.outputs[1].api_key: variable AIRTABLE_API_KEY no definida".
+
+type Config struct {
+	Profiles map[string]*Profile `json:"profiles"`
+
+	file string
+}
+
+type Profile struct {
+	Name      string           `json:"-"`
+	Sources   []Source         `json:"sources,omitempty"`
+	Filters   ProfileFilters   `json:"filters"`
+	Outputs   []Output         `json:"outputs,omitempty"`
+	Notifiers []NotifierConfig `json:"notifiers,omitempty"`
+	Schedules []Schedule       `json:"schedules,omitempty"`
+
+	where *Filter
+	errs  []error
+}
+
+// Source es un feed y el directorio donde se archiva.
+type Source struct {
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url,omitempty"` // cabecera del feed; vacío = DefaultFeedURL
+	Dir  string `json:"dir,omitempty"` // vacío = data/
+}
+
+type ProfileFilters struct {
+	CPV        []string `json:"cpv,omitempty"` // prefijos
+	ExcludeCPV []string `json:"exclude_cpv,omitempty"`
+	NUTS       []string `json:"nuts,omitempty"`  // prefijos
+	Where      string   `json:"where,omitempty"` // expresión de -where
+}
+
+// Tipos de salida.
+var outputTypes = []string{"csv", "json", "jsonl", "postgres"}
+
+type Output struct {
+	Type string `json:"type"`
+	Path string `json:"path,omitempty"` // csv, json y jsonl
+	DSN  string `json:"dsn,omitempty"`  // postgres
+}
+
+// Tipos de notificador.
+var notifierTypes = []string{"webhook", "email"}
+
+type NotifierConfig struct {
+	Type      string            `json:"type"`
+	URL       string            `json:"url,omitempty"` // webhook
+	Secret    string            `json:"secret,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	SMTP      string            `json:"smtp,omitempty"` // email: host:puerto
+	Username  string            `json:"username,omitempty"`
+	Password  string            `json:"password,omitempty"`
+	From      string            `json:"from,omitempty"`
+	To        []string          `json:"to,omitempty"`
+	Digest    bool              `json:"digest,omitempty"` // un resumen por destinatario
+	Templates DigestTemplates   `json:"templates"`        // sólo con digest
+	Events    []string          `json:"events,omitempty"` // tipos de evento; vacío = todos
+}
+
+// DigestTemplates son plantillas propias para el resumen; una ruta vacía deja
+// la de internal/templates.
+type DigestTemplates struct {
+	HTML string `json:"html,omitempty"`
+	Text string `json:"text,omitempty"`
+}
+
+// Tareas programables: comandos del binario.
+var scheduleTasks = []string{"crawl", "ingest", "export", "notify"}
+
+// Schedule programa una tarea con cron (cinco campos) o cada cierto tiempo. El
+// binario no las lanza: las lee un cron externo (ver CronSpec y config -crontab).
+type Schedule struct {
+	Task  string `json:"task"`
+	Cron  string `json:"cron,omitempty"`
+	Every string `json:"every,omitempty"` // duración de Go: 15m, 1h
+}
+
+// Interval es Every ya validado; 0 si la tarea va por cron.
+func (s *Schedule) Interval() time.Duration {
+	d, _ := time.ParseDuration(s.Every)
+	return d
+}
+
+// CronSpec devuelve la expresión de cron de la tarea. Un every se traduce si
+// cabe en cron (minutos que dividen la hora, horas que dividen el día); si no,
+// ok es false.
+func (s *Schedule) CronSpec() (spec string, ok bool) {
+	if s.Cron != "" {
+		return s.Cron, true
+	}
+	d := s.Interval()
+	switch {
+	case d <= 0 || d%time.Minute != 0:
+		return "", false
+	case d < time.Hour && int(time.Hour/time.Minute)%int(d/time.Minute) == 0:
+		return fmt.Sprintf("*/%d * * * *", int(d/time.Minute)), true
+	case d == time.Hour:
+		return "0 * * * *", true
+	case d%time.Hour == 0 && d < 24*time.Hour && 24%int(d/time.Hour) == 0:
+		return fmt.Sprintf("0 */%d * * *", int(d/time.Hour)), true
+	case d == 24*time.Hour:
+		return "0 0 * * *", true
+	}
+	return "", false
+}
+
+// ConfigError es un error en una clave concreta del fichero.
+type ConfigError struct {
+	File string
+	Key  string // profiles.fuels.outputs[1].api_key
+	Msg  string
+}
+
+func (e *ConfigError) Error() string {
+	if e.Key == "" {
+		return e.File + ": " + e.Msg
+	}
+	return e.File + ": " + e.Key + ": " + e.Msg
+}
+
+// LoadConfig lee path y valida cada perfil. Los errores de un perfil (una
+// variable de entorno que falta, una salida incompleta…) no impiden usar los
+// demás: los devuelve Profile al pedirlo, y Validate los de todos.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := parseConfigTree(path, data)
+	if err != nil {
+		return nil, &ConfigError{File: path, Msg: err.Error()}
+	}
+
+	d := &configDecoder{file: path}
+	cfg := Config{file: path}
+	d.decode("", tree, reflect.ValueOf(&cfg).Elem())
+	if len(cfg.Profiles) == 0 && len(d.errs) == 0 {
+		d.errorf("profiles", "no hay ningún perfil")
+	}
+
+	byProfile := make(map[string][]error)
+	var global []error
+	for _, err := range d.errs {
+		name, ok := cfg.profileOf(err.(*ConfigError).Key)
+		if !ok {
+			global = append(global, err)
+			continue
+		}
+		byProfile[name] = append(byProfile[name], err)
+	}
+	if len(global) > 0 {
+		return nil, errors.Join(global...)
+	}
+
+	for _, name := range cfg.ProfileNames() {
+		p := cfg.Profiles[name]
+		if p == nil {
+			p = &Profile{}
+			cfg.Profiles[name] = p
+		}
+		p.Name = name
+		p.errs = byProfile[name]
+		if len(p.errs) == 0 {
+			pd := &configDecoder{file: path}
+			p.validate(pd, joinKey("profiles", name))
+			p.errs = pd.errs
+		}
+	}
+	return &cfg, nil
+}
+
+// profileOf dice a qué perfil pertenece una clave con error.
+func (c *Config) profileOf(key string) (string, bool) {
+	for name := range c.Profiles {
+		k := joinKey("profiles", name)
+		if key == k || strings.HasPrefix(key, k+".") {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Profile devuelve el perfil name, o el único que haya si name está vacío. Si
+// el perfil tiene errores los devuelve todos.
+func (c *Config) Profile(name string) (*Profile, error) {
+	if name == "" && len(c.Profiles) == 1 {
+		name = c.ProfileNames()[0]
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		return nil, &ConfigError{File: c.file, Key: "profiles", Msg: fmt.Sprintf("perfil %q no definido (hay: %s)", name, strings.Join(c.ProfileNames(), ", "))}
+	}
+	if len(p.errs) > 0 {
+		return nil, errors.Join(p.errs...)
+	}
+	return p, nil
+}
+
+// Validate devuelve los errores de todos los perfiles.
+func (c *Config) Validate() error {
+	var errs []error
+	for _, name := range c.ProfileNames() {
+		errs = append(errs, c.Profiles[name].errs...)
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) ProfileNames() []string {
+	return sortedKeys(c.Profiles)
+}
+
+func parseConfigTree(path string, data []byte) (any, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		if len(doc.Content) == 0 {
+			return map[string]any{}, nil
+		}
+		return yamlTree(doc.Content[0])
+	case ".toml":
+		var m map[string]any
+		if _, err := toml.Decode(string(data), &m); err != nil {
+			return nil, err
+		}
+		return tomlTree(m), nil
+	}
+	return nil, fmt.Errorf("extensión desconocida %q (yaml, yml o toml)", filepath.Ext(path))
+}
+
+// yamlTree pasa un nodo YAML al árbol genérico. Los escalares se quedan como
+// texto: así "09132" sin comillas no se convierte en el número 9132.
+func yamlTree(n *yaml.Node) (any, error) {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return yamlTree(n.Alias)
+	case yaml.ScalarNode:
+		if n.Tag == "!!null" {
+			return nil, nil
+		}
+		return n.Value, nil
+	case yaml.SequenceNode:
+		out := make([]any, 0, len(n.Content))
+		for _, c := range n.Content {
+			v, err := yamlTree(c)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case yaml.MappingNode:
+		out := make(map[string]any, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i]
+			if k.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("línea %d: las claves deben ser texto", k.Line)
+			}
+			if _, dup := out[k.Value]; dup {
+				return nil, fmt.Errorf("línea %d: clave %q repetida", k.Line, k.Value)
+			}
+			v, err := yamlTree(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			out[k.Value] = v
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("línea %d: nodo YAML no soportado", n.Line)
+}
+
+// tomlTree deja los arrays de tablas ([[x]]) como []any, igual que el resto.
+func tomlTree(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, c := range v {
+			v[k] = tomlTree(c)
+		}
+	case []map[string]any:
+		out := make([]any, len(v))
+		for i, c := range v {
+			out[i] = tomlTree(c)
+		}
+		return out
+	case []any:
+		for i, c := range v {
+			v[i] = tomlTree(c)
+		}
+	}
+	return v
+}
+
+// ---------- Decodificación ----------
+
+type configDecoder struct {
+	file string
+	errs []error
+}
+
+func (d *configDecoder) errorf(key, format string, a ...any) {
+	d.errs = append(d.errs, &ConfigError{File: d.file, Key: key, Msg: fmt.Sprintf(format, a...)})
+}
+
+func joinKey(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	if strings.ContainsAny(key, " .") {
+		key = strconv.Quote(key)
+	}
+	return parent + "." + key
+}
+
+// decode rellena v con src. Las claves de los structs son las de su etiqueta json.
+func (d *configDecoder) decode(key string, src any, v reflect.Value) {
+	if src == nil {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		d.decode(key, src, v.Elem())
+
+	case reflect.Struct:
+		m, ok := src.(map[string]any)
+		if !ok {
+			d.errorf(key, "se esperaba una tabla de claves, no %s", describeConfigValue(src))
+			return
+		}
+		fields := configFields(v.Type())
+		for _, k := range sortedKeys(m) {
+			i, ok := fields[k]
+			if !ok {
+				d.errorf(joinKey(key, k), "clave desconocida%s", suggestKey(k, fields))
+				continue
+			}
+			d.decode(joinKey(key, k), m[k], v.Field(i))
+		}
+
+	case reflect.Map:
+		m, ok := src.(map[string]any)
+		if !ok {
+			d.errorf(key, "se esperaba una tabla de claves, no %s", describeConfigValue(src))
+			return
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for _, k := range sortedKeys(m) {
+			ev := reflect.New(v.Type().Elem()).Elem()
+			d.decode(joinKey(key, k), m[k], ev)
+			v.SetMapIndex(reflect.ValueOf(k), ev)
+		}
+
+	case reflect.Slice:
+		items, ok := src.([]any)
+		if !ok {
+			// Un valor suelto vale por una lista de uno: cpv: "0913".
+			items = []any{src}
+		}
+		out := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, it := range items {
+			d.decode(fmt.Sprintf("%s[%d]", key, i), it, out.Index(i))
+		}
+		v.Set(out)
+
+	case reflect.String:
+		s, ok := src.(string)
+		if !ok {
+			d.errorf(key, "se esperaba texto, no %s; pon el valor entre comillas", describeConfigValue(src))
+			return
+		}
+		s, err := expandConfigEnv(s)
+		if err != nil {
+			d.errorf(key, "%v", err)
+			return
+		}
+		v.SetString(s)
+
+	case reflect.Bool:
+		switch b := src.(type) {
+		case bool:
+			v.SetBool(b)
+		case string:
+			s, err := expandConfigEnv(b)
+			if err != nil {
+				d.errorf(key, "%v", err)
+				return
+			}
+			pb, err := strconv.ParseBool(s)
+			if err != nil {
+				d.errorf(key, "se esperaba true o false, no %q", s)
+				return
+			}
+			v.SetBool(pb)
+		default:
+			d.errorf(key, "se esperaba true o false, no %s", describeConfigValue(src))
+		}
+
+	case reflect.Int, reflect.Int64:
+		switch n := src.(type) {
+		case int64:
+			v.SetInt(n)
+		case float64:
+			if n != math.Trunc(n) {
+				d.errorf(key, "se esperaba un entero, no %v", n)
+				return
+			}
+			v.SetInt(int64(n))
+		case string:
+			s, err := expandConfigEnv(n)
+			if err != nil {
+				d.errorf(key, "%v", err)
+				return
+			}
+			pn, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				d.errorf(key, "se esperaba un entero, no %q", s)
+				return
+			}
+			v.SetInt(pn)
+		default:
+			d.errorf(key, "se esperaba un entero, no %s", describeConfigValue(src))
+		}
+
+	default:
+		d.errorf(key, "tipo %s no soportado", v.Type())
+	}
+}
+
+// configFields indexa los campos de t por su nombre json.
+func configFields(t reflect.Type) map[string]int {
+	out := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		out[name] = i
+	}
+	return out
+}
+
+func suggestKey(k string, fields map[string]int) string {
+	best, bestD := "", 3
+	names := sortedKeys(fields)
+	for _, name := range names {
+		if d := editDistance(k, name); d < bestD {
+			best, bestD = name, d
+		}
+	}
+	if best != "" {
+		return fmt.Sprintf(" (¿%s?)", best)
+	}
+	return " (válidas: " + strings.Join(names, ", ") + ")"
+}
+
+func describeConfigValue(v any) string {
+	switch v := v.(type) {
+	case map[string]any:
+		return "una tabla"
+	case []any:
+		return "una lista"
+	case string:
+		return strconv.Quote(v)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+var reConfigEnv = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandConfigEnv sustituye ${VAR} y ${VAR:-por defecto}; $$ es un $ literal.
+// Como en la shell, el valor por defecto se usa si VAR no existe o está vacía,
+// y ${VAR} con VAR vacía da "". Sólo es un error una variable sin definir y
+// sin valor por defecto.
+func expandConfigEnv(s string) (string, error) {
+	var missing []string
+	out := reConfigEnv.ReplaceAllStringFunc(s, func(m string) string {
+		if m == "$$" {
+			return "$"
+		}
+		sub := reConfigEnv.FindStringSubmatch(m)
+		if v, ok := os.LookupEnv(sub[1]); ok && v != "" {
+			return v
+		}
+		if sub[2] != "" {
+			return sub[3]
+		}
+		if _, ok := os.LookupEnv(sub[1]); ok {
+			return ""
+		}
+		missing = append(missing, sub[1])
+		return ""
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("variable de entorno %s no definida", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// ---------- Validación ----------
+
+var (
+	reNUTSPrefix = regexp.MustCompile(`^[A-Z]{2}[0-9A-Z]{0,3}$`)
+	reEmail      = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+)
+
+func (p *Profile) validate(d *configDecoder, key string) {
+	if len(p.Sources) == 0 {
+		p.Sources = []Source{{}}
+	}
+	if len(p.Sources) > 1 {
+		// Los comandos leen un solo feed y un solo directorio.
+		d.errorf(key+".sources[1]", "sólo se admite una fuente por perfil; declara otro perfil para la segunda")
+	}
+	names := make(map[string]bool)
+	for i := range p.Sources {
+		s := &p.Sources[i]
+		k := fmt.Sprintf("%s.sources[%d]", key, i)
+		if s.URL == "" {
+			s.URL = DefaultFeedURL
+		} else if !isHTTPURL(s.URL) {
+			d.errorf(k+".url", "URL no válida %q", s.URL)
+		}
+		if s.Dir == "" {
+			s.Dir = "data/"
+		}
+		if s.Name != "" && names[s.Name] {
+			d.errorf(k+".name", "nombre %q repetido", s.Name)
+		}
+		names[s.Name] = true
+	}
+
+	f := &p.Filters
+	fk := key + ".filters"
+	for i, c := range f.CPV {
+		var err error
+		if f.CPV[i], err = ParseCPVPrefix(c); err != nil {
+			d.errorf(fmt.Sprintf("%s.cpv[%d]", fk, i), "%v", err)
+		}
+	}
+	for i, c := range f.ExcludeCPV {
+		var err error
+		if f.ExcludeCPV[i], err = ParseCPVPrefix(c); err != nil {
+			d.errorf(fmt.Sprintf("%s.exclude_cpv[%d]", fk, i), "%v", err)
+		}
+	}
+	for i, n := range f.NUTS {
+		f.NUTS[i] = strings.ToUpper(strings.TrimSpace(n))
+		if !reNUTSPrefix.MatchString(f.NUTS[i]) {
+			d.errorf(fmt.Sprintf("%s.nuts[%d]", fk, i), "prefijo NUTS no válido %q (p.ej. ES, ES52, ES523)", n)
+		}
+	}
+	// where se compila sola primero para que la columna del error sea la suya.
+	if _, err := ParseFilterFlag(f.Where); err != nil {
+		d.errorf(fk+".where", "%v", err)
+	} else if expr := p.FilterExpr(); expr != "" {
+		if p.where, err = CompileFilter(expr); err != nil {
+			d.errorf(fk, "%v", err)
+		}
+	}
+
+	for i := range p.Outputs {
+		validateOutput(d, fmt.Sprintf("%s.outputs[%d]", key, i), &p.Outputs[i])
+	}
+	for i := range p.Notifiers {
+		validateNotifier(d, fmt.Sprintf("%s.notifiers[%d]", key, i), &p.Notifiers[i])
+	}
+	for i := range p.Schedules {
+		validateSchedule(d, fmt.Sprintf("%s.schedules[%d]", key, i), &p.Schedules[i])
+	}
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func validateOutput(d *configDecoder, key string, o *Output) {
+	require := func(field, v string) {
+		if v == "" {
+			d.errorf(key+"."+field, "obligatorio para type %s", o.Type)
+		}
+	}
+	switch o.Type {
+	case "csv", "json", "jsonl":
+		require("path", o.Path)
+	case "postgres":
+		require("dsn", o.DSN)
+	case "airtable":
+		d.errorf(key+".type", "no soportado: a Airtable escribe el worker de cron_job/, no este binario")
+	case "":
+		d.errorf(key+".type", "obligatorio (%s)", strings.Join(outputTypes, ", "))
+	default:
+		d.errorf(key+".type", "tipo desconocido %q (%s)", o.Type, strings.Join(outputTypes, ", "))
+	}
+}
+
+func validateNotifier(d *configDecoder, key string, n *NotifierConfig) {
+	switch n.Type {
+	case "webhook":
+		if !isHTTPURL(n.URL) {
+			d.errorf(key+".url", "URL no válida %q", n.URL)
+		}
+	case "email":
+		if _, _, err := net.SplitHostPort(n.SMTP); err != nil {
+			d.errorf(key+".smtp", "se esperaba host:puerto, no %q", n.SMTP)
+		}
+		if !reEmail.MatchString(n.From) {
+			d.errorf(key+".from", "dirección no válida %q", n.From)
+		}
+		if len(n.To) == 0 {
+			d.errorf(key+".to", "hace falta al menos un destinatario")
+		}
+		for i, to := range n.To {
+			if !reEmail.MatchString(to) {
+				d.errorf(fmt.Sprintf("%s.to[%d]", key, i), "dirección no válida %q", to)
+			}
+		}
+	case "":
+		d.errorf(key+".type", "obligatorio (%s)", strings.Join(notifierTypes, ", "))
+		return
+	default:
+		d.errorf(key+".type", "tipo desconocido %q (%s)", n.Type, strings.Join(notifierTypes, ", "))
+		return
+	}
+	if n.Templates != (DigestTemplates{}) {
+		if n.Type != "email" || !n.Digest {
+			d.errorf(key+".templates", "sólo para type email con digest: true")
+		} else {
+			if err := NewDigestRenderer().LoadDigestTemplates(n.Templates.HTML, ""); err != nil {
+				d.errorf(key+".templates.html", "%v", err)
+			}
+			if err := NewDigestRenderer().LoadDigestTemplates("", n.Templates.Text); err != nil {
+				d.errorf(key+".templates.text", "%v", err)
+			}
+		}
+	}
+	for i, ev := range n.Events {
+		if _, ok := eventLabels[EventType(ev)]; !ok {
+			d.errorf(fmt.Sprintf("%s.events[%d]", key, i), "evento desconocido %q", ev)
+		}
+	}
+}
+
+func validateSchedule(d *configDecoder, key string, s *Schedule) {
+	if !slices.Contains(scheduleTasks, s.Task) {
+		d.errorf(key+".task", "tarea desconocida %q (%s)", s.Task, strings.Join(scheduleTasks, ", "))
+	}
+	switch {
+	case s.Cron == "" && s.Every == "":
+		d.errorf(key, "hace falta cron o every")
+	case s.Cron != "" && s.Every != "":
+		d.errorf(key, "cron y every son excluyentes")
+	case s.Cron != "":
+		if err := checkCron(s.Cron); err != nil {
+			d.errorf(key+".cron", "%v", err)
+		}
+	default:
+		dur, err := time.ParseDuration(s.Every)
+		if err != nil {
+			d.errorf(key+".every", "duración no válida %q (p.ej. 15m, 1h)", s.Every)
+		} else if dur < time.Minute {
+			d.errorf(key+".every", "como mínimo 1m")
+		}
+	}
+}
+
+// Rangos de los cinco campos de cron: minuto, hora, día, mes y día de la semana.
+var cronFields = []struct {
+	name     string
+	min, max int
+}{{"minuto", 0, 59}, {"hora", 0, 23}, {"día", 1, 31}, {"mes", 1, 12}, {"día de la semana", 0, 7}}
+
+// checkCron valida una expresión de cron estándar: *, listas, rangos y pasos.
+func checkCron(expr string) error {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return fmt.Errorf("se esperaban 5 campos y hay %d en %q", len(fields), expr)
+	}
+	for i, f := range fields {
+		cf := cronFields[i]
+		for _, part := range strings.Split(f, ",") {
+			rng, step, hasStep := strings.Cut(part, "/")
+			if hasStep {
+				if n, err := strconv.Atoi(step); err != nil || n <= 0 {
+					return fmt.Errorf("%s: paso no válido %q", cf.name, part)
+				}
+			}
+			if rng == "*" {
+				continue
+			}
+			lo, hi, isRange := strings.Cut(rng, "-")
+			a, err := strconv.Atoi(lo)
+			b := a
+			if err == nil && isRange {
+				b, err = strconv.Atoi(hi)
+			}
+			if err != nil || a < cf.min || b > cf.max || a > b {
+				return fmt.Errorf("%s: %q fuera de %d-%d", cf.name, part, cf.min, cf.max)
+			}
+		}
+	}
+	return nil
+}
+
+// ---------- Uso ----------
+
+// FilterExpr es el filtro del perfil en el lenguaje de -where: los prefijos
+// CPV y NUTS y la expresión where, todo con "and".
+func (p *Profile) FilterExpr() string {
+	var parts []string
+	prefixes := func(field string, values []string) string {
+		terms := make([]string, len(values))
+		for i, v := range values {
+			terms[i] = field + " ^= " + strconv.Quote(v)
+		}
+		return "(" + strings.Join(terms, " or ") + ")"
+	}
+	if len(p.Filters.CPV) > 0 {
+		parts = append(parts, prefixes("cpv", p.Filters.CPV))
+	}
+	if len(p.Filters.ExcludeCPV) > 0 {
+		parts = append(parts, "not "+prefixes("cpv", p.Filters.ExcludeCPV))
+	}
+	if len(p.Filters.NUTS) > 0 {
+		parts = append(parts, prefixes("nuts", p.Filters.NUTS))
+	}
+	if w := strings.TrimSpace(p.Filters.Where); w != "" {
+		parts = append(parts, "("+w+")")
+	}
+	return strings.Join(parts, " and ")
+}
+
+// Match indica si e pasa los filtros del perfil.
+func (p *Profile) Match(e *Entry) bool {
+	return p.where.Match(e)
+}
+
+// Output devuelve la primera salida de tipo typ, o nil.
+func (p *Profile) Output(typ string) *Output {
+	for i := range p.Outputs {
+		if p.Outputs[i].Type == typ {
+			return &p.Outputs[i]
+		}
+	}
+	return nil
+}
+
+// Notifier construye los notificadores del perfil (nil si no hay ninguno).
+// events vale igual para todos: el resumen lo aplica por destinatario y los
+// demás van envueltos en un EventFilter. Falla si no se pueden leer las
+// plantillas del resumen.
+func (p *Profile) Notifier() (Notifier, error) {
+	var out MultiNotifier
+	for _, n := range p.Notifiers {
+		var types []EventType
+		for _, ev := range n.Events {
+			types = append(types, EventType(ev))
+		}
+		var nt Notifier
+		switch n.Type {
+		case "webhook":
+			w := NewWebhookNotifier(n.URL, n.Secret)
+			w.Headers = n.Headers
+			nt = w
+		case "email":
+			s := NewSMTPNotifier(n.SMTP, n.From, n.To...)
+			s.Username, s.Password = n.Username, n.Password
+			nt = s
+			if n.Digest {
+				recipients := make([]Recipient, len(n.To))
+				for i, to := range n.To {
+					recipients[i] = Recipient{Email: to, CPVs: p.Filters.CPV, Types: types}
+				}
+				dn := NewDigestNotifier(s, recipients)
+				if err := dn.Renderer.LoadDigestTemplates(n.Templates.HTML, n.Templates.Text); err != nil {
+					return nil, err
+				}
+				out = append(out, dn)
+				continue
+			}
+		default:
+			continue
+		}
+		if len(types) > 0 {
+			nt = EventFilter{Next: nt, Types: types}
+		}
+		out = append(out, nt)
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// Redacted es una copia de p con los secretos tapados, para mostrarla.
+func (p *Profile) Redacted() *Profile {
+	hide := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return "***"
+	}
+	r := *p
+	r.Outputs = slices.Clone(p.Outputs)
+	for i := range r.Outputs {
+		r.Outputs[i].DSN = hide(r.Outputs[i].DSN)
+	}
+	r.Notifiers = slices.Clone(p.Notifiers)
+	for i := range r.Notifiers {
+		n := &r.Notifiers[i]
+		n.Secret, n.Password = hide(n.Secret), hide(n.Password)
+		if len(n.Headers) > 0 {
+			h := make(map[string]string, len(n.Headers))
+			for k, v := range n.Headers {
+				h[k] = hide(v)
+			}
+			n.Headers = h
+		}
+	}
+	return &r
+}
@@ -0,0 +1,213 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestExpandConfigEnv(t *testing.T) {
+	t.Setenv("LIC_SET", "valor")
+	t.Setenv("LIC_EMPTY", "")
+	os.Unsetenv("LIC_UNSET")
+
+	cases := []struct {
+		in, want string
+		err      bool
+	}{
+		{"${LIC_SET}", "valor", false},
+		{"${LIC_SET:-otro}", "valor", false},
+		{"${LIC_EMPTY}", "", false},
+		{"${LIC_EMPTY:-otro}", "otro", false},
+		{"${LIC_UNSET:-otro}", "otro", false},
+		{"${LIC_UNSET}", "", true},
+		{"$$${LIC_SET}", "$valor", false},
+	}
+	for _, c := range cases {
+		got, err := expandConfigEnv(c.in)
+		if (err != nil) != c.err || got != c.want {
+			t.Errorf("expandConfigEnv(%q) = %q, %v; want %q, error %v", c.in, got, err, c.want, c.err)
+		}
+	}
+}
+
+func TestLoadConfigUnsupported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "licitaciones.yaml")
+	data := `profiles:
+  fuels:
+    sources:
+      - url: https://example.com/a.atom
+      - url: https://example.com/b.atom
+    outputs:
+      - type: airtable
+  leasing:
+    schedules:
+      - task: crawl
+        cron: "*/15 * * * *"
+      - task: crawl
+        cron: "*/15 * * *"
+      - task: backup
+        every: 10s
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.Validate()
+	var keys []string
+	for _, e := range unwrapErrors(err) {
+		var ce *ConfigError
+		if errors.As(e, &ce) {
+			keys = append(keys, ce.Key)
+		}
+	}
+	for _, k := range []string{"profiles.fuels.sources[1]", "profiles.fuels.outputs[0].type",
+		"profiles.leasing.schedules[1].cron", "profiles.leasing.schedules[2].task", "profiles.leasing.schedules[2].every"} {
+		if !slices.Contains(keys, k) {
+			t.Errorf("falta un error en %s; errores: %v", k, err)
+		}
+	}
+}
+
+func TestScheduleCronSpec(t *testing.T) {
+	cases := []struct {
+		s    Schedule
+		want string
+		ok   bool
+	}{
+		{Schedule{Cron: "5 4 * * 1"}, "5 4 * * 1", true},
+		{Schedule{Every: "15m"}, "*/15 * * * *", true},
+		{Schedule{Every: "1h"}, "0 * * * *", true},
+		{Schedule{Every: "6h"}, "0 */6 * * *", true},
+		{Schedule{Every: "24h"}, "0 0 * * *", true},
+		{Schedule{Every: "45m"}, "", false},
+		{Schedule{Every: "90m"}, "", false},
+	}
+	for _, c := range cases {
+		if got, ok := c.s.CronSpec(); got != c.want || ok != c.ok {
+			t.Errorf("CronSpec(%+v) = %q, %v; want %q, %v", c.s, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestProfileNotifierEvents(t *testing.T) {
+	var got WebhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "licitaciones.yaml")
+	data := `profiles:
+  fuels:
+    notifiers:
+      - type: webhook
+        url: ` + srv.URL + `
+        events: [licitation_awarded]
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	n, err := cfg.Profiles["fuels"].Notifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []Event{{Type: EventCreated, EntryID: "e1"}, {Type: EventAwarded, EntryID: "e2"}}
+	if err := n.Notify(context.Background(), events); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Events) != 1 || got.Events[0].EntryID != "e2" {
+		t.Errorf("payload recibido %+v, want sólo e2", got)
+	}
+}
+
+func TestNotifierTemplatesConfig(t *testing.T) {
+	dir := t.TempDir()
+	text := filepath.Join(dir, "resumen.txt.tmpl")
+	if err := os.WriteFile(text, []byte(`{{.Total}} novedades`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "licitaciones.yaml")
+	data := `profiles:
+  fuels:
+    notifiers:
+      - type: email
+        smtp: localhost:1025
+        from: avisos@example.com
+        to: [compras@example.com]
+        digest: true
+        templates:
+          text: ` + text + `
+  leasing:
+    notifiers:
+      - type: webhook
+        url: https://example.com/hook
+        templates:
+          text: ` + text + `
+      - type: email
+        smtp: localhost:1025
+        from: avisos@example.com
+        to: [compras@example.com]
+        digest: true
+        templates:
+          html: ` + filepath.Join(dir, "no-existe.html") + `
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, e := range unwrapErrors(cfg.Validate()) {
+		var ce *ConfigError
+		if errors.As(e, &ce) {
+			keys = append(keys, ce.Key)
+		}
+	}
+	want := []string{"profiles.leasing.notifiers[0].templates", "profiles.leasing.notifiers[1].templates.html"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("errores en %v, want %v", keys, want)
+	}
+
+	n, err := cfg.Profiles["fuels"].Notifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dn, ok := n.(MultiNotifier)[0].(*DigestNotifier)
+	if !ok {
+		t.Fatalf("notificador %T, want *DigestNotifier", n.(MultiNotifier)[0])
+	}
+	got, _, err := dn.Renderer.Render(BuildDigest(testEvents, nil, time.Now()))
+	if err != nil || got != "1 novedades" {
+		t.Errorf("Render = %q, %v", got, err)
+	}
+}
+
+// unwrapErrors aplana un error de errors.Join.
+func unwrapErrors(err error) []error {
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		return j.Unwrap()
+	}
+	return []error{err}
+}
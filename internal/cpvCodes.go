@@ -0,0 +1,347 @@
+package internal
+
+import (
+	_ "embed"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Nomenclatura CPV 2008 embebida (data/cpv2008.tsv): descripción en español e
+// inglés, dígito de control y árbol. Un código tiene 8 dígitos más el de
+// control ("09134000-7"); los ceros finales marcan el nivel: división (2
+// dígitos significativos), grupo (3), clase (4), categoría (5) y subcategorías.
+//
+// El dígito de control no sale de una fórmula publicada, así que sólo se puede
+// comprobar contra la lista. Si la lista embebida no es la completa (sólo las
+// divisiones), un código que no está en ella no es un error: sólo se comprueba
+// que su división exista.
+
+//go:embed data/cpv2008.tsv
+var cpvData string
+
+// CPV es un código de la nomenclatura.
+type CPV struct {
+	Code  string `json:"code"`  // 8 dígitos
+	Check string `json:"check"` // dígito de control
+	ES    string `json:"es"`
+	EN    string `json:"en"`
+
+	parent   *CPV
+	children []*CPV
+}
+
+var (
+	cpvByCode   map[string]*CPV
+	cpvRoots    []*CPV // divisiones
+	cpvFullList bool   // la lista tiene más que las divisiones
+)
+
+func init() {
+	if err := loadCPV(cpvData); err != nil {
+		panic("data/cpv2008.tsv:" + err.Error())
+	}
+}
+
+// loadCPV carga la lista en formato TSV y rehace el árbol.
+func loadCPV(data string) error {
+	byCode := make(map[string]*CPV)
+	for i, line := range strings.Split(data, "\n") {
+		if line = strings.TrimRight(line, "\r"); line == "" || line[0] == '#' {
+			continue
+		}
+		f := strings.Split(line, "\t")
+		code, check, ok := strings.Cut(f[0], "-")
+		if len(f) != 3 || !ok || !isDigits(code) || len(code) != 8 || len(check) != 1 {
+			return fmt.Errorf("%d: línea mal formada", i+1)
+		}
+		byCode[code] = &CPV{Code: code, Check: check, ES: f[1], EN: f[2]}
+	}
+	cpvByCode, cpvRoots, cpvFullList = byCode, nil, false
+	for _, code := range sortedKeys(cpvByCode) {
+		c := cpvByCode[code]
+		if c.Level() > 2 {
+			cpvFullList = true
+		}
+		// El padre es el antecesor más cercano que está en la lista: hay
+		// códigos cuyo nivel inmediatamente superior no existe.
+		for p := cpvParentCode(code); p != ""; p = cpvParentCode(p) {
+			if pc := cpvByCode[p]; pc != nil {
+				c.parent = pc
+				pc.children = append(pc.children, c)
+				break
+			}
+		}
+		if c.parent == nil {
+			cpvRoots = append(cpvRoots, c)
+		}
+	}
+	return nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// cpvSignificant quita los ceros finales de un código de 8 dígitos, dejando al
+// menos la división: "09130000" -> "0913".
+func cpvSignificant(code string) string {
+	s := strings.TrimRight(code, "0")
+	if len(s) < 2 {
+		s = code[:2]
+	}
+	return s
+}
+
+// cpvParentCode es el código del nivel superior según la estructura, sin mirar
+// la lista; "" para una división.
+func cpvParentCode(code string) string {
+	s := cpvSignificant(code)
+	if len(s) <= 2 {
+		return ""
+	}
+	s = s[:len(s)-1]
+	return s + strings.Repeat("0", 8-len(s))
+}
+
+// Level es el número de dígitos significativos: 2 división, 3 grupo, 4 clase,
+// 5 categoría, 6 a 8 subcategorías.
+func (c *CPV) Level() int {
+	return len(cpvSignificant(c.Code))
+}
+
+func (c *CPV) String() string {
+	return c.Code + "-" + c.Check
+}
+
+// Parent devuelve el código padre en la lista, o nil para una división.
+func (c *CPV) Parent() *CPV { return c.parent }
+
+// Children devuelve los hijos directos en la lista, por código.
+func (c *CPV) Children() []*CPV { return c.children }
+
+// Ancestors devuelve la cadena desde la división hasta el padre de c.
+func (c *CPV) Ancestors() []*CPV {
+	var out []*CPV
+	for p := c.parent; p != nil; p = p.parent {
+		out = append([]*CPV{p}, out...)
+	}
+	return out
+}
+
+// Contains indica si code es c o desciende de c ("esta división y todo lo que
+// cuelga de ella").
+func (c *CPV) Contains(code string) bool {
+	return CPVDescends(code, c.Code)
+}
+
+// CPVDescends indica si code es ancestor o uno de sus descendientes. Sólo mira
+// la estructura del código, así que vale también para códigos fuera de la lista.
+func CPVDescends(code, ancestor string) bool {
+	code, ancestor = cpvDigits(code), cpvDigits(ancestor)
+	if len(code) != 8 || len(ancestor) != 8 {
+		return false
+	}
+	return strings.HasPrefix(code, cpvSignificant(ancestor))
+}
+
+// CPVDivisions devuelve las divisiones, por código.
+func CPVDivisions() []*CPV { return cpvRoots }
+
+// CPVFullList indica si la lista embebida es la nomenclatura completa o sólo
+// las divisiones.
+func CPVFullList() bool { return cpvFullList }
+
+// LookupCPV busca un código con o sin dígito de control; nil si no está.
+func LookupCPV(s string) *CPV {
+	return cpvByCode[cpvDigits(s)]
+}
+
+// CPVName devuelve la descripción en español de code, o la de su antecesor más
+// cercano en la lista si code no está; "" si tampoco.
+func CPVName(code string) string {
+	code = cpvDigits(code)
+	if len(code) != 8 {
+		return ""
+	}
+	for ; code != ""; code = cpvParentCode(code) {
+		if c := cpvByCode[code]; c != nil {
+			return c.ES
+		}
+	}
+	return ""
+}
+
+// cpvDigits quita espacios y el dígito de control: "09134000-7" -> "09134000".
+func cpvDigits(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// ParseCPV valida un código completo, con o sin "-N", y lo devuelve con 8
+// dígitos. El dígito de control se comprueba si el código está en la lista.
+func ParseCPV(s string) (string, error) {
+	in := strings.TrimSpace(s)
+	code, check, hasCheck := strings.Cut(in, "-")
+	if len(code) != 8 || !isDigits(code) {
+		return "", fmt.Errorf("CPV %q: deben ser 8 dígitos, opcionalmente con -dígito de control", in)
+	}
+	if hasCheck && (len(check) != 1 || !isDigits(check)) {
+		return "", fmt.Errorf("CPV %q: el dígito de control es un solo dígito tras el guion", in)
+	}
+	if cpvByCode[code[:2]+"000000"] == nil {
+		return "", fmt.Errorf("CPV %q: la división %s no existe", in, code[:2])
+	}
+	c := cpvByCode[code]
+	if c == nil && cpvFullList {
+		return "", fmt.Errorf("CPV %q: no existe en la nomenclatura CPV 2008%s", in, cpvSuggest(code))
+	}
+	if c != nil && hasCheck && check != c.Check {
+		return "", fmt.Errorf("CPV %q: dígito de control incorrecto, es %s (%s)", in, c, c.ES)
+	}
+	return code, nil
+}
+
+// ParseCPVPrefix valida un prefijo ("09", "0913") o un código completo
+// ("09130000", "09130000-9"), que pasa a su prefijo significativo para que
+// incluya todos sus descendientes.
+func ParseCPVPrefix(s string) (string, error) {
+	in := strings.TrimSpace(s)
+	if len(in) >= 8 {
+		code, err := ParseCPV(in)
+		if err != nil {
+			return "", err
+		}
+		return cpvSignificant(code), nil
+	}
+	if !isDigits(in) || len(in) < 2 {
+		return "", fmt.Errorf("prefijo CPV %q: deben ser de 2 a 8 dígitos (la división como mínimo)", in)
+	}
+	if cpvByCode[in[:2]+"000000"] == nil {
+		return "", fmt.Errorf("prefijo CPV %q: la división %s no existe", in, in[:2])
+	}
+	if cpvFullList {
+		for code := range cpvByCode {
+			if strings.HasPrefix(code, in) {
+				return in, nil
+			}
+		}
+		return "", fmt.Errorf("prefijo CPV %q: ningún código CPV 2008 empieza así%s", in, cpvSuggest(in+strings.Repeat("0", 8-len(in))))
+	}
+	return in, nil
+}
+
+// cpvSuggest propone el antecesor existente más cercano de code.
+func cpvSuggest(code string) string {
+	for p := cpvParentCode(code); p != ""; p = cpvParentCode(p) {
+		if c := cpvByCode[p]; c != nil {
+			return fmt.Sprintf("; el nivel superior existente es %s (%s)", c, c.ES)
+		}
+	}
+	return ""
+}
+
+// SearchCPV devuelve los códigos cuya descripción (español o inglés) contiene
+// todas las palabras de text, sin distinguir acentos ni mayúsculas.
+func SearchCPV(text string) []*CPV {
+	words := strings.Fields(strings.ToLower(FoldAccents(text)))
+	var out []*CPV
+	for _, code := range sortedKeys(cpvByCode) {
+		c := cpvByCode[code]
+		name := strings.ToLower(FoldAccents(c.ES + " " + c.EN))
+		ok := len(words) > 0
+		for _, w := range words {
+			ok = ok && strings.Contains(name, w)
+		}
+		if ok {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// ImportCPVXML convierte el XML oficial de la nomenclatura (cpv_2008.xml de
+// SIMAP: <CPV CODE="03000000-1"><TEXT LANG="ES">…</TEXT>…</CPV>) en out, con
+// el formato de data/cpv2008.tsv. Devuelve el número de códigos.
+func ImportCPVXML(in, out string) (int, error) {
+	f, err := os.Open(in)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var n int
+	err = writeFileAtomic(out, func(w io.Writer) error {
+		n, err = importCPVXML(f, w)
+		return err
+	})
+	return n, err
+}
+
+func importCPVXML(r io.Reader, w io.Writer) (int, error) {
+	type text struct {
+		Lang  string `xml:"LANG,attr"`
+		Value string `xml:",chardata"`
+	}
+	type cpv struct {
+		Code  string `xml:"CODE,attr"`
+		Texts []text `xml:"TEXT"`
+	}
+	var rows []string
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "CPV" {
+			continue
+		}
+		var c cpv
+		if err := dec.DecodeElement(&c, &se); err != nil {
+			return 0, err
+		}
+		code, check, _ := strings.Cut(c.Code, "-")
+		if len(code) != 8 || !isDigits(code) || len(check) != 1 {
+			return 0, fmt.Errorf("código CPV mal formado %q", c.Code)
+		}
+		var es, en string
+		for _, t := range c.Texts {
+			v := strings.Join(strings.Fields(t.Value), " ")
+			switch strings.ToUpper(t.Lang) {
+			case "ES":
+				es = v
+			case "EN":
+				en = v
+			}
+		}
+		rows = append(rows, c.Code+"\t"+es+"\t"+en)
+	}
+	if len(rows) == 0 {
+		return 0, fmt.Errorf("no hay ningún elemento <CPV CODE=...>")
+	}
+	sort.Strings(rows)
+	fmt.Fprintln(w, "# Vocabulario Común de Contratos Públicos (CPV 2008, Reglamento (CE) n.º 213/2008).")
+	fmt.Fprintln(w, "# código-dígito de control<TAB>descripción ES<TAB>descripción EN")
+	fmt.Fprintln(w, "# Generado con \"licitaciones cpv -import\" a partir del XML oficial de SIMAP.")
+	for _, r := range rows {
+		if _, err := fmt.Fprintln(w, r); err != nil {
+			return 0, err
+		}
+	}
+	return len(rows), nil
+}
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseCPV(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		err      bool
+	}{
+		{"45000000", "45000000", false},
+		{" 45000000-7 ", "45000000", false},
+		{"45000000-3", "", true}, // dígito de control incorrecto
+		{"4500000", "", true},
+		{"45000000-77", "", true},
+		{"99000000", "", true}, // no hay división 99
+	} {
+		got, err := ParseCPV(tc.in)
+		if (err != nil) != tc.err || got != tc.want {
+			t.Errorf("ParseCPV(%q) = %q, %v", tc.in, got, err)
+		}
+	}
+}
+
+func TestParseCPVPrefix(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		err      bool
+	}{
+		{"09", "09", false},
+		{"0913", "0913", false},
+		{"09100000", "091", false}, // código completo: todos sus descendientes
+		{"34000000-7", "34", false},
+		{"0", "", true},
+		{"99", "", true},
+		{"09x", "", true},
+	} {
+		got, err := ParseCPVPrefix(tc.in)
+		if (err != nil) != tc.err || got != tc.want {
+			t.Errorf("ParseCPVPrefix(%q) = %q, %v", tc.in, got, err)
+		}
+	}
+}
+
+func TestCPVTree(t *testing.T) {
+	if cpvParentCode("09134000") != "09130000" || cpvParentCode("09000000") != "" {
+		t.Errorf("cpvParentCode: %q %q", cpvParentCode("09134000"), cpvParentCode("09000000"))
+	}
+	if !CPVDescends("09134000-7", "09000000") || !CPVDescends("09130000", "09130000") || CPVDescends("09200000", "09130000") {
+		t.Error("CPVDescends")
+	}
+	d := LookupCPV("09000000-3")
+	if d == nil || d.Check != "3" || d.Parent() != nil || !d.Contains("09134000") {
+		t.Fatalf("LookupCPV(09000000-3) = %+v", d)
+	}
+	if CPVName("09134000") != d.ES {
+		t.Errorf("CPVName(09134000) = %q", CPVName("09134000"))
+	}
+	if len(CPVDivisions()) != 45 && !CPVFullList() {
+		t.Errorf("%d divisiones", len(CPVDivisions()))
+	}
+}
+
+// Con la lista completa (la que genera "cpv -import") también se comprueba el
+// dígito de control de los códigos que no son divisiones y se rechazan los que
+// no existen. La lista embebida puede tener sólo las divisiones, así que se
+// carga un extracto y se restaura al acabar.
+func TestParseCPVFullList(t *testing.T) {
+	t.Cleanup(func() { loadCPV(cpvData) })
+	extract := "09000000-3\tDerivados del petróleo\tPetroleum products\n" +
+		"09100000-0\tCombustibles\tFuels\n" +
+		"09130000-9\tPetróleo y destilados\tPetroleum and distillates\n" +
+		"09134000-7\tGasóleos\tGas oils\n"
+	if err := loadCPV(extract); err != nil {
+		t.Fatal(err)
+	}
+	if !CPVFullList() {
+		t.Fatal("el extracto no cuenta como lista completa")
+	}
+	for _, tc := range []struct {
+		in, want string
+		err      bool
+	}{
+		{"09134000-7", "09134000", false},
+		{"09134000", "09134000", false},
+		{"09134000-2", "", true}, // dígito de control incorrecto
+		{"09143000", "", true},   // errata: no existe
+		{"09134001-7", "", true},
+	} {
+		got, err := ParseCPV(tc.in)
+		if (err != nil) != tc.err || got != tc.want {
+			t.Errorf("ParseCPV(%q) = %q, %v", tc.in, got, err)
+		}
+	}
+	if _, err := ParseCPVPrefix("0914"); err == nil {
+		t.Error("ParseCPVPrefix(0914): ningún código empieza así, want error")
+	}
+	if c := LookupCPV("09134000"); c == nil || c.Parent() == nil || c.Parent().Code != "09130000" {
+		t.Errorf("LookupCPV(09134000) = %+v", c)
+	}
+}
+
+func TestCPVFilterHierarchy(t *testing.T) {
+	include, err := ParseCPVPrefixes("09100000-0, 34")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := CPVFilter{Include: include}
+	for cpv, want := range map[string]bool{"09134000": true, "09134000-7": true, "34110000": true, "09200000": false} {
+		if f.Match(cpv) != want {
+			t.Errorf("Match(%q) = %v", cpv, !want)
+		}
+	}
+}
+
+func TestImportCPVXML(t *testing.T) {
+	src := `<?xml version="1.0" encoding="UTF-8"?>
+<CPV_CODE>
+ <CPV CODE="09100000-0"><TEXT LANG="EN">Fuels</TEXT><TEXT LANG="ES">Combustibles</TEXT></CPV>
+ <CPV CODE="03000000-1"><TEXT LANG="ES">Productos de la agricultura</TEXT><TEXT LANG="EN">Agricultural
+   products</TEXT></CPV>
+</CPV_CODE>`
+	var b strings.Builder
+	n, err := importCPVXML(strings.NewReader(src), &b)
+	if err != nil || n != 2 {
+		t.Fatalf("importCPVXML = %d, %v", n, err)
+	}
+	want := "03000000-1\tProductos de la agricultura\tAgricultural products\n09100000-0\tCombustibles\tFuels\n"
+	if !strings.HasSuffix(b.String(), want) {
+		t.Errorf("salida:\n%s", b.String())
+	}
+}
+
+// El dígito de control de un CPV publicado se quita, no se pega al código.
+func TestCommodityCPVsCheckDigit(t *testing.T) {
+	cs := make([]Commodity, 3)
+	cs[0].CPV.Value = "09134000-7"
+	cs[1].CPV.Value = " 09134000 "
+	cs[2].CPV.Value = "34100000-8"
+	got := commodityCPVs(cs)
+	if want := []string{"09134000", "34100000"}; !slices.Equal(got, want) {
+		t.Errorf("commodityCPVs = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,65 @@
+package internal
+
+import "strings"
+
+// CPVFilter selecciona entries por prefijos de CPV. Los CPV se comparan sin el
+// dígito de control ("09120000-6" -> "09120000"). Un CPV pasa si empieza por
+// algún prefijo de Include (o Include está vacío) y por ninguno de Exclude.
+// Los prefijos salen de ParseCPVPrefixes, que pasa un código completo a su
+// prefijo significativo: "09100000" incluye todo lo que cuelga de 091.
+type CPVFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// ParseCPVPrefixes convierte "09132, 09134000-7" en []string{"09132", "09134"},
+// validando cada uno contra la nomenclatura (ver ParseCPVPrefix).
+func ParseCPVPrefixes(s string) ([]string, error) {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		prefix, err := ParseCPVPrefix(p)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, prefix)
+	}
+	return out, nil
+}
+
+func (f CPVFilter) Match(cpv string) bool {
+	c := cpvDigits(cpv)
+	if c == "" {
+		return false
+	}
+	for _, p := range f.Exclude {
+		if strings.HasPrefix(c, p) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, p := range f.Include {
+		if strings.HasPrefix(c, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchAny indica si alguno de los CPV pasa el filtro. Sin filtro todo pasa,
+// también las entries sin CPV.
+func (f CPVFilter) MatchAny(cpvs []string) bool {
+	if len(f.Include) == 0 && len(f.Exclude) == 0 {
+		return true
+	}
+	for _, c := range cpvs {
+		if f.Match(c) {
+			return true
+		}
+	}
+	return false
+}
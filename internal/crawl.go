@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Descarga del feed de sindicación al archivo local: se sigue rel="next" desde
+// la cabecera y cada página se guarda tal cual con el nombre que usa la PCSP
+// (licitacionesPerfilesContratanteCompleto3_AAAAMMDD_HHMMSS_N.atom), que es el
+// que entiende listLocalAtoms.
+
+const DefaultFeedURL = "https://contrataciondelsectorpublico.gob.es/sindicacion/sindicacion_643/licitacionesPerfilesContratanteCompleto3.atom"
+
+type CrawlOptions struct {
+	URL      string
+	Dir      string
+	Since    time.Time // se para en la primera página con updated <= Since
+	MaxPages int       // 0 = sin límite
+	Full     bool      // no parar en la primera página que ya está en Dir
+	Delay    time.Duration
+	Client   *http.Client
+}
+
+type CrawlResult struct {
+	Pages int      `json:"pages"`
+	Files []string `json:"files"` // ficheros nuevos
+}
+
+// feedHead es lo que necesita el crawler de cada página.
+type feedHead struct {
+	Updated RFC3339Time `xml:"updated"`
+	Links   []Link      `xml:"link"`
+}
+
+func (h *feedHead) next() string {
+	for _, l := range h.Links {
+		if l.Rel == "next" {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+// pageFileName es el nombre local de la página url. La cabecera del feed no lleva
+// fecha en el nombre; se le pone la de su updated.
+func pageFileName(url string, updated time.Time) string {
+	base := path.Base(url)
+	if reTs.MatchString(base) {
+		return base
+	}
+	loc, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		loc = time.UTC
+	}
+	return strings.TrimSuffix(base, ".atom") + "_" + updated.In(loc).Format("20060102_150405") + ".atom"
+}
+
+// Crawl descarga páginas hasta que no hay next, se llega a Since o MaxPages, o
+// (sin Full) aparece una página ya descargada: las páginas archivadas no cambian.
+func Crawl(ctx context.Context, opts CrawlOptions) (*CrawlResult, error) {
+	if opts.URL == "" {
+		opts.URL = DefaultFeedURL
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 2 * time.Minute}
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	res := &CrawlResult{}
+	for url := opts.URL; url != ""; {
+		if opts.MaxPages > 0 && res.Pages >= opts.MaxPages {
+			log.Printf("[CRAWL] límite de %d páginas", opts.MaxPages)
+			break
+		}
+		if res.Pages > 0 && opts.Delay > 0 {
+			select {
+			case <-time.After(opts.Delay):
+			case <-ctx.Done():
+				return res, ctx.Err()
+			}
+		}
+
+		body, err := fetchPage(ctx, opts.Client, url)
+		if err != nil {
+			return res, err
+		}
+		var head feedHead
+		if err := xml.Unmarshal(body, &head); err != nil {
+			return res, fmt.Errorf("%s: %w", url, err)
+		}
+		res.Pages++
+
+		if !opts.Since.IsZero() && !head.Updated.After(opts.Since) {
+			log.Printf("[CRAWL] %s es de %s, anterior a -since", url, head.Updated.Format(time.RFC3339))
+			break
+		}
+		file := filepath.Join(opts.Dir, pageFileName(url, head.Updated.Time))
+		if _, err := os.Stat(file); err == nil && url != opts.URL && !opts.Full {
+			log.Printf("[CRAWL] %s ya descargado, fin", file)
+			break
+		}
+		err = writeFileAtomic(file, func(w io.Writer) error {
+			_, err := w.Write(body)
+			return err
+		})
+		if err != nil {
+			return res, err
+		}
+		res.Files = append(res.Files, file)
+		log.Printf("[CRAWL] %s -> %s", url, file)
+
+		url = head.next()
+	}
+	return res, nil
+}
+
+func fetchPage(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	return body, nil
+}
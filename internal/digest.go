@@ -0,0 +1,301 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Resumen por ejecución: en vez de un mensaje por evento, un único correo con
+// los eventos de la ejecución agrupados por sección, CPV y organismo.
+
+//go:embed templates/digest.*.tmpl
+var digestTemplates embed.FS
+
+type DigestKind string
+
+const (
+	DigestNew       DigestKind = "new"       // nuevas licitaciones
+	DigestClosures  DigestKind = "closures"  // fin de plazo de presentación
+	DigestAwards    DigestKind = "awards"    // adjudicaciones
+	DigestDocuments DigestKind = "documents" // documentos nuevos
+	DigestOther     DigestKind = "other"     // resto de cambios
+)
+
+var digestOrder = []struct {
+	kind  DigestKind
+	title string
+}{
+	{DigestNew, "Nuevas licitaciones"},
+	{DigestClosures, "Fin de plazo de presentación"},
+	{DigestAwards, "Adjudicaciones"},
+	{DigestDocuments, "Nuevos documentos"},
+	{DigestOther, "Otros cambios"},
+}
+
+func digestKind(t EventType) DigestKind {
+	switch t {
+	case EventCreated:
+		return DigestNew
+	case EventFinishedSubmissionPeriod:
+		return DigestClosures
+	case EventLotAwarded, EventAwarded:
+		return DigestAwards
+	case EventNewDocument:
+		return DigestDocuments
+	}
+	return DigestOther
+}
+
+type DigestGroup struct {
+	CPV    string
+	Org    string
+	Events []Event
+}
+
+type DigestSection struct {
+	Kind   DigestKind
+	Title  string
+	Count  int
+	Groups []DigestGroup
+}
+
+type Digest struct {
+	At        time.Time
+	Recipient *Recipient // nil si es el resumen completo
+	Total     int
+	Sections  []DigestSection
+}
+
+// Recipient es un destinatario con sus filtros. Un filtro vacío no filtra.
+type Recipient struct {
+	Name  string      `json:"name,omitempty"`
+	Email string      `json:"email"`
+	CPVs  []string    `json:"cpvs,omitempty"` // prefijos
+	Orgs  []string    `json:"orgs,omitempty"` // subcadena del nombre, sin distinguir mayúsculas
+	Types []EventType `json:"types,omitempty"`
+}
+
+// Match indica si al destinatario le interesa el evento.
+func (r *Recipient) Match(ev Event) bool {
+	if len(r.Types) > 0 && !containsType(r.Types, ev.Type) {
+		return false
+	}
+	if len(r.CPVs) > 0 && r.matchCPV(ev.CPVs) == "" {
+		return false
+	}
+	if len(r.Orgs) > 0 {
+		org := strings.ToLower(ev.OrgName)
+		ok := false
+		for _, o := range r.Orgs {
+			if strings.Contains(org, strings.ToLower(o)) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// matchCPV devuelve el primer CPV del evento que casa con los prefijos.
+func (r *Recipient) matchCPV(cpvs []string) string {
+	for _, c := range cpvs {
+		for _, p := range r.CPVs {
+			if strings.HasPrefix(c, p) {
+				return c
+			}
+		}
+	}
+	return ""
+}
+
+func containsType(ts []EventType, t EventType) bool {
+	for _, x := range ts {
+		if x == t {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildDigest agrupa events. Con r != nil sólo entran los eventos que le
+// interesan y cada uno se agrupa bajo el CPV que casó con su filtro.
+func BuildDigest(events []Event, r *Recipient, at time.Time) *Digest {
+	d := &Digest{At: at, Recipient: r}
+	type key struct {
+		kind     DigestKind
+		cpv, org string
+	}
+	groups := make(map[key]*DigestGroup)
+
+	for _, ev := range events {
+		if r != nil && !r.Match(ev) {
+			continue
+		}
+		cpv := ""
+		if r != nil && len(r.CPVs) > 0 {
+			cpv = r.matchCPV(ev.CPVs)
+		} else if len(ev.CPVs) > 0 {
+			cpv = ev.CPVs[0]
+		}
+		k := key{digestKind(ev.Type), cpv, strings.TrimSpace(ev.OrgName)}
+		g, ok := groups[k]
+		if !ok {
+			g = &DigestGroup{CPV: k.cpv, Org: k.org}
+			groups[k] = g
+		}
+		g.Events = append(g.Events, ev)
+		d.Total++
+	}
+
+	for _, s := range digestOrder {
+		sec := DigestSection{Kind: s.kind, Title: s.title}
+		for k, g := range groups {
+			if k.kind == s.kind {
+				sec.Groups = append(sec.Groups, *g)
+				sec.Count += len(g.Events)
+			}
+		}
+		if sec.Count == 0 {
+			continue
+		}
+		sort.Slice(sec.Groups, func(i, j int) bool {
+			a, b := sec.Groups[i], sec.Groups[j]
+			if a.CPV != b.CPV {
+				return a.CPV < b.CPV
+			}
+			return a.Org < b.Org
+		})
+		for _, g := range sec.Groups {
+			sort.SliceStable(g.Events, func(i, j int) bool {
+				return g.Events[i].CreatedAt.Before(g.Events[j].CreatedAt)
+			})
+		}
+		d.Sections = append(d.Sections, sec)
+	}
+	return d
+}
+
+// ---------- Plantillas ----------
+
+var digestFuncs = map[string]any{
+	"label":  EventLabel,
+	"amount": formatAmount,
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("02/01/2006")
+	},
+	"trim": strings.TrimSpace,
+}
+
+// DigestRenderer produce los cuerpos HTML y texto de un Digest.
+type DigestRenderer struct {
+	HTML *htmltemplate.Template
+	Text *texttemplate.Template
+}
+
+// NewDigestRenderer usa las plantillas por defecto (internal/templates).
+func NewDigestRenderer() *DigestRenderer {
+	return &DigestRenderer{
+		HTML: htmltemplate.Must(htmltemplate.New("digest.html.tmpl").Funcs(digestFuncs).
+			ParseFS(digestTemplates, "templates/digest.html.tmpl")),
+		Text: texttemplate.Must(texttemplate.New("digest.txt.tmpl").Funcs(digestFuncs).
+			ParseFS(digestTemplates, "templates/digest.txt.tmpl")),
+	}
+}
+
+// LoadDigestTemplates sustituye las plantillas por las de los ficheros dados.
+// Una ruta vacía deja la plantilla por defecto.
+func (dr *DigestRenderer) LoadDigestTemplates(htmlPath, textPath string) error {
+	if htmlPath != "" {
+		t, err := htmltemplate.New("").Funcs(digestFuncs).ParseFiles(htmlPath)
+		if err != nil {
+			return err
+		}
+		dr.HTML = t.Lookup(templateBase(htmlPath))
+	}
+	if textPath != "" {
+		t, err := texttemplate.New("").Funcs(digestFuncs).ParseFiles(textPath)
+		if err != nil {
+			return err
+		}
+		dr.Text = t.Lookup(templateBase(textPath))
+	}
+	return nil
+}
+
+func templateBase(path string) string {
+	if i := strings.LastIndexAny(path, `/\`); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
+func (dr *DigestRenderer) Render(d *Digest) (text, html string, err error) {
+	var tb, hb bytes.Buffer
+	if err := dr.Text.Execute(&tb, d); err != nil {
+		return "", "", fmt.Errorf("digest text: %w", err)
+	}
+	if err := dr.HTML.Execute(&hb, d); err != nil {
+		return "", "", fmt.Errorf("digest html: %w", err)
+	}
+	return tb.String(), hb.String(), nil
+}
+
+// ---------- Notifier ----------
+
+// DigestNotifier manda un resumen por destinatario a través de SMTP. Los
+// destinatarios sin eventos que les interesen no reciben nada.
+type DigestNotifier struct {
+	SMTP       *SMTPNotifier
+	Renderer   *DigestRenderer
+	Recipients []Recipient
+	Subject    string // %d se sustituye por el número de eventos
+}
+
+func NewDigestNotifier(smtp *SMTPNotifier, recipients []Recipient) *DigestNotifier {
+	return &DigestNotifier{
+		SMTP:       smtp,
+		Renderer:   NewDigestRenderer(),
+		Recipients: recipients,
+		Subject:    "Resumen de licitaciones: %d novedades",
+	}
+}
+
+func (n *DigestNotifier) Notify(ctx context.Context, events []Event) error {
+	at := time.Now()
+	var errs []string
+	for i := range n.Recipients {
+		r := &n.Recipients[i]
+		d := BuildDigest(events, r, at)
+		if d.Total == 0 {
+			continue
+		}
+		text, html, err := n.Renderer.Render(d)
+		if err != nil {
+			return err
+		}
+		subject := n.Subject
+		if strings.Contains(subject, "%d") {
+			subject = fmt.Sprintf(subject, d.Total)
+		}
+		if err := n.SMTP.Send(ctx, []string{r.Email}, subject, text, html); err != nil {
+			errs = append(errs, r.Email+": "+err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("digest: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var digestEvents = []Event{
+	{Type: EventAwarded, EntryID: "e3", Title: "Gasóleo C", OrgName: "Ayuntamiento de Soria", CPVs: []string{"09135000"}, CreatedAt: time.Unix(30, 0)},
+	{Type: EventCreated, EntryID: "e2", Title: "Gasóleo A", OrgName: "Ayuntamiento de Soria", CPVs: []string{"90910000", "09134000"}, CreatedAt: time.Unix(20, 0)},
+	{Type: EventCreated, EntryID: "e1", Title: "Gasóleo B", OrgName: " Ayuntamiento de Soria ", CPVs: []string{"90910000", "09134000"}, CreatedAt: time.Unix(10, 0)},
+	{Type: EventCreated, EntryID: "e4", Title: "Limpieza", OrgName: "Diputación de León", CPVs: []string{"90910000"}, CreatedAt: time.Unix(40, 0)},
+	{Type: EventNewDocument, EntryID: "e5", Title: "Sin CPV", OrgName: "Diputación de León", CreatedAt: time.Unix(50, 0)},
+}
+
+// digestShape resume un Digest como "sección: CPV/organismo=entries; ...".
+func digestShape(d *Digest) string {
+	var b strings.Builder
+	for _, s := range d.Sections {
+		b.WriteString(string(s.Kind) + ":")
+		for _, g := range s.Groups {
+			b.WriteString(" " + g.CPV + "/" + g.Org + "=")
+			for i, ev := range g.Events {
+				if i > 0 {
+					b.WriteString(",")
+				}
+				b.WriteString(ev.EntryID)
+			}
+		}
+		b.WriteString("; ")
+	}
+	return b.String()
+}
+
+func TestBuildDigest(t *testing.T) {
+	at := time.Unix(100, 0)
+	cases := []struct {
+		name  string
+		r     *Recipient
+		total int
+		want  string
+	}{
+		// Sin destinatario: todo, bajo el primer CPV de cada evento, en el orden
+		// de digestOrder y por fecha dentro de cada grupo.
+		{"completo", nil, 5,
+			"new: 90910000/Ayuntamiento de Soria=e1,e2 90910000/Diputación de León=e4; " +
+				"awards: 09135000/Ayuntamiento de Soria=e3; documents: /Diputación de León=e5; "},
+		// Con prefijos, el evento va bajo el CPV que casó, no el primero.
+		{"cpv", &Recipient{Email: "a@example.com", CPVs: []string{"0913"}}, 3,
+			"new: 09134000/Ayuntamiento de Soria=e1,e2; awards: 09135000/Ayuntamiento de Soria=e3; "},
+		{"tipos", &Recipient{Email: "b@example.com", Types: []EventType{EventAwarded, EventNewDocument}}, 2,
+			"awards: 09135000/Ayuntamiento de Soria=e3; documents: /Diputación de León=e5; "},
+		{"organismo", &Recipient{Email: "c@example.com", Orgs: []string{"LEÓN"}, CPVs: []string{"9091"}}, 1,
+			"new: 90910000/Diputación de León=e4; "},
+		{"nada", &Recipient{Email: "d@example.com", CPVs: []string{"34"}}, 0, ""},
+	}
+	for _, c := range cases {
+		d := BuildDigest(digestEvents, c.r, at)
+		if d.Total != c.total || digestShape(d) != c.want {
+			t.Errorf("%s: %d eventos, %q\nwant %d, %q", c.name, d.Total, digestShape(d), c.total, c.want)
+		}
+	}
+}
+
+func TestDigestTemplates(t *testing.T) {
+	dir := t.TempDir()
+	text := filepath.Join(dir, "propio.txt.tmpl")
+	if err := os.WriteFile(text, []byte(`{{.Total}} para {{.Recipient.Email}}{{range .Sections}} {{.Kind}}={{.Count}}{{end}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dr := NewDigestRenderer()
+	if err := dr.LoadDigestTemplates("", text); err != nil {
+		t.Fatal(err)
+	}
+	r := &Recipient{Email: "a@example.com", CPVs: []string{"0913"}}
+	got, html, err := dr.Render(BuildDigest(digestEvents, r, time.Unix(100, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "3 para a@example.com new=2 awards=1" {
+		t.Errorf("texto = %q", got)
+	}
+	if !strings.Contains(html, "Gasóleo A") {
+		t.Error("el HTML debería seguir con la plantilla por defecto")
+	}
+	if err := dr.LoadDigestTemplates(filepath.Join(dir, "no-existe.html"), ""); err == nil {
+		t.Error("plantilla que no existe: want error")
+	}
+}
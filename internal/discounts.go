@@ -0,0 +1,222 @@
+package internal
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Bajas: cuánto por debajo del presupuesto base (sin impuestos) se adjudica.
+//
+//	baja = (presupuesto - adjudicado) / presupuesto * 100
+//
+// Sólo cuentan adjudicaciones con TaxExclusiveAmount adjudicado y presupuesto sin
+// impuestos (TaxExclusiveAmount) de su lote, o del expediente si no tiene lotes.
+// Ni el valor estimado ni el presupuesto del expediente sirven de sustituto.
+
+// Dimensiones por las que se agrega.
+const (
+	DimCPV       = "cpv"
+	DimBuyer     = "buyer"
+	DimProcedure = "procedure"
+	DimBidders   = "bidders"
+	DimYear      = "year"
+)
+
+var DiscountDimensions = []string{DimCPV, DimBuyer, DimProcedure, DimBidders, DimYear}
+
+type Discount struct {
+	AwardRecord
+	Discount float64 `json:"discount"` // porcentaje
+}
+
+// Discounts calcula la baja de cada adjudicación que la permite.
+func Discounts(records []AwardRecord) []Discount {
+	var out []Discount
+	for _, r := range records {
+		if r.Budget <= 0 || r.TaxExclusive <= 0 {
+			continue
+		}
+		out = append(out, Discount{AwardRecord: r, Discount: (r.Budget - r.TaxExclusive) / r.Budget * 100})
+	}
+	return out
+}
+
+type DiscountStats struct {
+	Dimension string  `json:"dimension"`
+	Key       string  `json:"key"`
+	N         int     `json:"n"`
+	Mean      float64 `json:"mean"`
+	Min       float64 `json:"min"`
+	P10       float64 `json:"p10"`
+	P25       float64 `json:"p25"`
+	P50       float64 `json:"p50"`
+	P75       float64 `json:"p75"`
+	P90       float64 `json:"p90"`
+	Max       float64 `json:"max"`
+	Budget    float64 `json:"budget"`
+	Awarded   float64 `json:"awarded"`
+	Weighted  float64 `json:"weighted"` // baja sobre la suma de importes
+}
+
+// DiscountKeys devuelve las claves de d para una dimensión. Una adjudicación
+// con varios CPV cuenta en cada uno, truncado a cpvDigits dígitos.
+func DiscountKeys(d *Discount, dim string, cpvDigits int) []string {
+	switch dim {
+	case DimCPV:
+		seen := make(map[string]bool)
+		var keys []string
+		for _, c := range d.CPVs {
+			if cpvDigits > 0 && len(c) > cpvDigits {
+				c = c[:cpvDigits]
+			}
+			if !seen[c] {
+				seen[c] = true
+				keys = append(keys, c)
+			}
+		}
+		return keys
+	case DimBuyer:
+		return []string{d.BuyerName}
+	case DimProcedure:
+		return []string{d.Procedure}
+	case DimBidders:
+		return []string{biddersBucket(d.Bidders)}
+	case DimYear:
+		t := d.Date
+		if t.IsZero() {
+			t = d.Updated
+		}
+		return []string{strconv.Itoa(t.Year())}
+	}
+	return nil
+}
+
+func biddersBucket(n int) string {
+	switch {
+	case n <= 0:
+		return "?"
+	case n <= 5:
+		return strconv.Itoa(n)
+	case n <= 10:
+		return "6-10"
+	}
+	return ">10"
+}
+
+// AggregateDiscounts agrega las bajas por cada dimensión de dims. Los grupos
+// con menos de minN adjudicaciones se omiten.
+func AggregateDiscounts(ds []Discount, dims []string, cpvDigits, minN int) []DiscountStats {
+	var out []DiscountStats
+	for _, dim := range dims {
+		groups := make(map[string][]*Discount)
+		for i := range ds {
+			for _, k := range DiscountKeys(&ds[i], dim, cpvDigits) {
+				groups[k] = append(groups[k], &ds[i])
+			}
+		}
+
+		var stats []DiscountStats
+		for key, g := range groups {
+			if len(g) < minN {
+				continue
+			}
+			stats = append(stats, discountStats(dim, key, g))
+		}
+		sort.Slice(stats, func(i, j int) bool {
+			if stats[i].N != stats[j].N {
+				return stats[i].N > stats[j].N
+			}
+			return stats[i].Key < stats[j].Key
+		})
+		out = append(out, stats...)
+	}
+	return out
+}
+
+func discountStats(dim, key string, g []*Discount) DiscountStats {
+	vals := make([]float64, len(g))
+	s := DiscountStats{Dimension: dim, Key: key, N: len(g)}
+	sum := 0.0
+	for i, d := range g {
+		vals[i] = d.Discount
+		sum += d.Discount
+		s.Budget += d.Budget
+		s.Awarded += d.TaxExclusive
+	}
+	sort.Float64s(vals)
+	s.Mean = sum / float64(len(vals))
+	s.Min, s.Max = vals[0], vals[len(vals)-1]
+	s.P10 = Percentile(vals, 10)
+	s.P25 = Percentile(vals, 25)
+	s.P50 = Percentile(vals, 50)
+	s.P75 = Percentile(vals, 75)
+	s.P90 = Percentile(vals, 90)
+	if s.Budget > 0 {
+		s.Weighted = (s.Budget - s.Awarded) / s.Budget * 100
+	}
+	return s
+}
+
+// Percentile interpola linealmente entre los valores de sorted (ya ordenados).
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	pos := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	if lo == hi {
+		return sorted[lo]
+	}
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
+
+func fixed2(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// WriteDiscountStatsCSV escribe las estadísticas en path ("-" = stdout).
+func WriteDiscountStatsCSV(path string, stats []DiscountStats) error {
+	return writeFileAtomic(path, func(f io.Writer) error {
+		w := csv.NewWriter(f)
+		_ = w.Write([]string{
+			"dimension", "key", "n", "mean", "min", "p10", "p25", "p50", "p75", "p90", "max",
+			"budget", "awarded", "weighted",
+		})
+		for _, s := range stats {
+			_ = w.Write([]string{
+				s.Dimension, s.Key, strconv.Itoa(s.N),
+				fixed2(s.Mean), fixed2(s.Min), fixed2(s.P10), fixed2(s.P25), fixed2(s.P50),
+				fixed2(s.P75), fixed2(s.P90), fixed2(s.Max),
+				fixed2(s.Budget), fixed2(s.Awarded), fixed2(s.Weighted),
+			})
+		}
+		w.Flush()
+		return w.Error()
+	})
+}
+
+// WriteDiscountsCSV escribe una fila por adjudicación, para quien quiera hacer
+// su propio análisis.
+func WriteDiscountsCSV(path string, ds []Discount) error {
+	return writeFileAtomic(path, func(f io.Writer) error {
+		w := csv.NewWriter(f)
+		_ = w.Write([]string{
+			"entry", "lot", "buyer", "procedure", "bidders", "cpvs", "date",
+			"winner_nif", "winner", "budget", "awarded", "discount", "url",
+		})
+		for _, d := range ds {
+			_ = w.Write([]string{
+				d.EntryID, d.LotID, d.BuyerName, d.Procedure, strconv.Itoa(d.Bidders), strings.Join(d.CPVs, ";"),
+				formatDate(d.Date), d.WinnerNIFs(";"), d.WinnerNames(";"),
+				fixed2(d.Budget), fixed2(d.TaxExclusive), fixed2(d.Discount), d.URL,
+			})
+		}
+		w.Flush()
+		return w.Error()
+	})
+}
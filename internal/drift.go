@@ -0,0 +1,334 @@
+package internal
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Detector de deriva del esquema: compara las rutas que aparecen en el feed
+// con las que decodifican los structs de parseFeed.go y resume las versiones
+// de las listas de códigos (listURI). Si PLACSP añade un elemento o cambia de
+// versión de CODICE, aparece aquí antes de que perdamos datos sin enterarnos.
+
+var textUnmarshaler = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// KnownPaths devuelve las rutas (mismo formato que RawNode.Path) que
+// decodifica el tipo t, normalmente Entry.
+func KnownPaths(t reflect.Type) map[string]bool {
+	out := make(map[string]bool)
+	collectPaths(t, "", out, make(map[reflect.Type]int))
+	return out
+}
+
+func collectPaths(t reflect.Type, prefix string, out map[string]bool, depth map[reflect.Type]int) {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || reflect.PointerTo(t).Implements(textUnmarshaler) {
+		return
+	}
+	// Cortar tipos recursivos
+	if depth[t] > 1 {
+		return
+	}
+	depth[t]++
+	defer func() { depth[t]-- }()
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("xml")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if i := strings.LastIndexByte(name, ' '); i >= 0 {
+			name = name[i+1:] // "namespace local"
+		}
+
+		switch {
+		case strings.Contains(opts, "attr"):
+			if name == "" {
+				name = f.Name
+			}
+			out[joinPath(prefix, "@"+name)] = true
+			continue
+		case strings.Contains(opts, "chardata"), strings.Contains(opts, "comment"):
+			continue
+		case strings.Contains(opts, "innerxml"), strings.Contains(opts, "any"):
+			out[joinPath(prefix, "*")] = true
+			continue
+		}
+		if f.Anonymous && name == "" {
+			collectPaths(f.Type, prefix, out, depth)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		p := prefix
+		for _, seg := range strings.Split(name, ">") {
+			p = joinPath(p, seg)
+			out[p] = true
+		}
+		collectPaths(f.Type, p, out, depth)
+	}
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "/" + name
+}
+
+// mapped indica si p está cubierta: ella misma o un ancestro con ",any"/",innerxml".
+func mapped(known map[string]bool, p string) bool {
+	if known[p] {
+		return true
+	}
+	for i := strings.LastIndexByte(p, '/'); i >= 0; i = strings.LastIndexByte(p[:i], '/') {
+		if known[p[:i]+"/*"] {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------- Listas de códigos ----------
+
+// reListURI separa ".../codice/cl/2.04/SyndicationContractCode-2.04.gc" en
+// lista y versión.
+var reListURI = regexp.MustCompile(`/([A-Za-z0-9_]+?)(?:-(\d+(?:\.\d+)*))?\.gc$`)
+var reListDir = regexp.MustCompile(`/(\d+(?:\.\d+)+)/`)
+
+// ParseListURI devuelve el nombre de la lista y su versión ("" si no se ve).
+func ParseListURI(uri string) (list, version string) {
+	uri = strings.TrimSpace(uri)
+	if m := reListURI.FindStringSubmatch(uri); m != nil {
+		list, version = m[1], m[2]
+	} else {
+		list = uri
+	}
+	if version == "" {
+		if m := reListDir.FindStringSubmatch(uri); m != nil {
+			version = m[1]
+		}
+	}
+	return list, version
+}
+
+type CodeListVersion struct {
+	List      string   `json:"list"`
+	Version   string   `json:"version"`
+	URI       string   `json:"uri"`
+	Count     int      `json:"count"`
+	Paths     []string `json:"paths"`
+	Examples  []string `json:"examples"`
+	FirstFile string   `json:"firstFile"`
+	LastFile  string   `json:"lastFile"`
+	New       bool     `json:"new,omitempty"`
+
+	paths map[string]bool
+}
+
+// ---------- Partial ----------
+
+type driftPartial struct {
+	census *Census
+	lists  map[string]*CodeListVersion // por URI
+	max    int
+}
+
+func newDriftPartial(opts *CensusOptions) *driftPartial {
+	return &driftPartial{census: newCensus(opts), lists: make(map[string]*CodeListVersion), max: opts.MaxExamples}
+}
+
+func (d *driftPartial) list(uri string) *CodeListVersion {
+	l, ok := d.lists[uri]
+	if !ok {
+		name, ver := ParseListURI(uri)
+		l = &CodeListVersion{List: name, Version: ver, URI: uri, paths: make(map[string]bool)}
+		d.lists[uri] = l
+	}
+	return l
+}
+
+func (d *driftPartial) addEntry(e *RawEntry, file string) {
+	d.census.addEntry(e, file)
+	seen := make(map[string]bool)
+	for _, n := range e.Nodes {
+		if !strings.HasSuffix(n.Path, "/@listURI") || n.Value == "" {
+			continue
+		}
+		l := d.list(n.Value)
+		l.Count++
+		l.paths[strings.TrimSuffix(n.Path, "/@listURI")] = true
+		if seen[n.Value] {
+			continue
+		}
+		seen[n.Value] = true
+		if len(l.Examples) < d.max {
+			l.Examples = append(l.Examples, e.ID)
+		}
+		addFileRange(&l.FirstFile, &l.LastFile, file)
+	}
+}
+
+func (d *driftPartial) Merge(other *driftPartial) {
+	d.census.Merge(other.census)
+	for _, uri := range sortedKeys(other.lists) {
+		src := other.lists[uri]
+		l := d.list(uri)
+		l.Count += src.Count
+		for p := range src.paths {
+			l.paths[p] = true
+		}
+		for _, id := range src.Examples {
+			if len(l.Examples) < d.max {
+				l.Examples = append(l.Examples, id)
+			}
+		}
+		addFileRange(&l.FirstFile, &l.LastFile, src.FirstFile)
+		addFileRange(&l.FirstFile, &l.LastFile, src.LastFile)
+	}
+}
+
+// ---------- Informe ----------
+
+type DriftPath struct {
+	Path      string   `json:"path"`
+	Count     int      `json:"count"`
+	Entries   int      `json:"entries"`
+	Examples  []string `json:"examples"`
+	FirstFile string   `json:"firstFile"`
+	LastFile  string   `json:"lastFile"`
+	New       bool     `json:"new,omitempty"`
+}
+
+type DriftReport struct {
+	Files     int               `json:"files"`
+	Entries   int               `json:"entries"`
+	Unmapped  []DriftPath       `json:"unmapped"`
+	CodeLists []CodeListVersion `json:"codeLists"`
+}
+
+// LoadDriftReport lee un informe anterior para usarlo como referencia.
+func LoadDriftReport(path string) (*DriftReport, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r DriftReport
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// DetectDrift recorre el archivo de dir. Con baseline, marca como New las rutas
+// y versiones de listas que no estaban; sin él, una lista con varias versiones
+// marca como New todas menos la más antigua. Con where sólo cuentan las entries
+// que lo cumplen.
+func DetectDrift(ctx context.Context, dir string, workers, examples int, where *Filter, baseline *DriftReport) (*DriftReport, error) {
+	if examples <= 0 {
+		examples = 5
+	}
+	opts := &CensusOptions{Paths: []string{"all"}, MaxDistinct: 1, MaxExamples: examples, Where: where}
+	files, err := listLocalAtoms(dir)
+	if err != nil {
+		return nil, err
+	}
+	d, err := ScanFiles(ctx, files, workers, func() *driftPartial { return newDriftPartial(opts) },
+		func(file string, p *driftPartial) error {
+			p.census.Files++
+			return decodeRawEntries(file, opts.Where, func(e *RawEntry) { p.addEntry(e, file) })
+		})
+	if err != nil {
+		return nil, err
+	}
+	return buildDriftReport(d, KnownPaths(reflect.TypeFor[Entry]()), baseline), nil
+}
+
+func buildDriftReport(d *driftPartial, known map[string]bool, baseline *DriftReport) *DriftReport {
+	r := &DriftReport{Files: d.census.Files, Entries: d.census.Entries}
+
+	oldPaths := make(map[string]bool)
+	oldLists := make(map[string]bool)
+	if baseline != nil {
+		for _, p := range baseline.Unmapped {
+			oldPaths[p.Path] = true
+		}
+		for _, l := range baseline.CodeLists {
+			oldLists[l.URI] = true
+		}
+	}
+
+	for _, p := range sortedKeys(d.census.Fields) {
+		if mapped(known, p) {
+			continue
+		}
+		f := d.census.Fields[p]
+		r.Unmapped = append(r.Unmapped, DriftPath{
+			Path: p, Count: f.Count, Entries: f.Entries, Examples: f.Examples,
+			FirstFile: f.FirstFile, LastFile: f.LastFile,
+			New: baseline != nil && !oldPaths[p],
+		})
+	}
+
+	for _, uri := range sortedKeys(d.lists) {
+		l := *d.lists[uri]
+		l.Paths = sortedKeys(l.paths)
+		r.CodeLists = append(r.CodeLists, l)
+	}
+	sort.SliceStable(r.CodeLists, func(i, j int) bool {
+		a, b := r.CodeLists[i], r.CodeLists[j]
+		if a.List != b.List {
+			return a.List < b.List
+		}
+		return compareVersions(a.Version, b.Version) < 0
+	})
+	for i := range r.CodeLists {
+		l := &r.CodeLists[i]
+		if baseline != nil {
+			l.New = !oldLists[l.URI]
+		} else {
+			l.New = i > 0 && r.CodeLists[i-1].List == l.List
+		}
+	}
+	return r
+}
+
+// compareVersions compara "2.04" y "2.10" numéricamente por componentes.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y string
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		x, y = strings.TrimLeft(x, "0"), strings.TrimLeft(y, "0")
+		if len(x) != len(y) {
+			if len(x) < len(y) {
+				return -1
+			}
+			return 1
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
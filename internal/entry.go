@@ -0,0 +1,235 @@
+package internal
+
+import (
+	"strings"
+	"time"
+)
+
+// Accesos comunes sobre Entry para no repetir la navegación por el CODICE.
+
+func (e *Entry) URL() string {
+	if len(e.Links) == 0 {
+		return ""
+	}
+	return e.Links[0].Href
+}
+
+func (e *Entry) Status() string {
+	return strings.TrimSpace(e.CFS.StatusCode.Value)
+}
+
+// CPVs del expediente, sin guiones ni duplicados y en orden de aparición.
+func (e *Entry) CPVs() []string {
+	return commodityCPVs(e.CFS.Project.Commodity)
+}
+
+func commodityCPVs(cs []Commodity) []string {
+	out := make([]string, 0, len(cs))
+	seen := make(map[string]bool, len(cs))
+	for _, c := range cs {
+		v := cpvDigits(c.CPV.Value) // "09134000-7" -> "09134000"
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	return out
+}
+
+func (e *Entry) NUTS() string {
+	if e.CFS.Project.Location == nil {
+		return ""
+	}
+	return strings.TrimSpace(e.CFS.Project.Location.NUTS.Value)
+}
+
+func (e *Entry) OrgName() string {
+	return e.CFS.LocatedParty.Party.PartyName.Name
+}
+
+// Identificador del órgano por esquema (NIF, DIR3, ID_PLATAFORMA…)
+func (e *Entry) PartyID(scheme string) string {
+	return partyID(e.CFS.LocatedParty.Party.Identifications, scheme)
+}
+
+func partyID(ids []PartyIdentification, scheme string) string {
+	for _, pid := range ids {
+		if strings.EqualFold(pid.ID.SchemeName, scheme) {
+			return strings.TrimSpace(pid.ID.Value)
+		}
+	}
+	return ""
+}
+
+// Fecha del anuncio de licitación (DOC_CN): la más temprana de sus
+// publicaciones, porque las rectificaciones y los otros medios repiten el
+// anuncio con fechas posteriores. Zero si aún no se ha publicado.
+func (e *Entry) PublishedDate() time.Time {
+	var first time.Time
+	for _, n := range e.CFS.Notices {
+		if !strings.EqualFold(strings.TrimSpace(n.NoticeType.Value), "DOC_CN") {
+			continue
+		}
+		for _, st := range n.Status {
+			for _, ref := range st.DocRefs {
+				if d := ref.IssueDate; d.Valid && (first.IsZero() || d.Time.Before(first)) {
+					first = d.Time
+				}
+			}
+		}
+	}
+	return first
+}
+
+// Presupuesto base sin impuestos; si no viene se usa el valor estimado.
+func (e *Entry) Budget() float64 {
+	return projectBudget(e.CFS.Project)
+}
+
+func projectBudget(p ProcurementProj) float64 {
+	if p.Budget == nil {
+		return 0
+	}
+	if p.Budget.TaxExclusive.Value != 0 {
+		return p.Budget.TaxExclusive.Value
+	}
+	return p.Budget.EstimatedOverall.Value
+}
+
+func (e *Entry) Deadline() time.Time {
+	d := e.CFS.Process.SubmissionDeadline.EndDate
+	if !d.Valid {
+		return time.Time{}
+	}
+	return d.Time
+}
+
+// Resultado asociado a un lote. Sin lotes, lotID vacío devuelve el primero.
+func (e *Entry) ResultFor(lotID string) *TenderResult {
+	for i := range e.CFS.Results {
+		r := &e.CFS.Results[i]
+		if lotID == "" {
+			return r
+		}
+		if r.Awarded != nil && strings.TrimSpace(r.Awarded.LotID) == lotID {
+			return r
+		}
+	}
+	return nil
+}
+
+func (l *ProjectLot) LotID() string {
+	return strings.TrimSpace(l.ID.Value)
+}
+
+// NIF del adjudicatario; algunos perfiles no informan el schemeName.
+func (r *TenderResult) WinnerNIF() string {
+	for i := range r.Winning {
+		if nif := r.Winning[i].NIF(); nif != "" {
+			return nif
+		}
+	}
+	return ""
+}
+
+// NIF de una de las adjudicatarias, o su primer identificador si no lo marca
+// como NIF.
+func (w *WinningParty) NIF() string {
+	if nif := partyID(w.Identification, "NIF"); nif != "" {
+		return nif
+	}
+	if len(w.Identification) > 0 {
+		return strings.TrimSpace(w.Identification[0].ID.Value)
+	}
+	return ""
+}
+
+func (r *TenderResult) WinnerName() string {
+	if len(r.Winning) == 0 {
+		return ""
+	}
+	return r.Winning[0].PartyName.Name
+}
+
+// Importe adjudicado sin impuestos; PayableAmount si no viene desglosado.
+func (r *TenderResult) AwardedAmount() float64 {
+	if r.Awarded == nil {
+		return 0
+	}
+	if v := r.Awarded.LegalMonetaryTotal.TaxExclusive.Value; v != 0 {
+		return v
+	}
+	return r.Awarded.LegalMonetaryTotal.Payable.Value
+}
+
+// Document es un pliego o anexo publicado en la entry.
+type Document struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Kind string `json:"kind"` // legal|technical|additional|general
+}
+
+// Documents devuelve los documentos de la entry sin duplicados (por ID), igual
+// que collectDocs en el worker TS.
+func (e *Entry) Documents() []Document {
+	var out []Document
+	seen := make(map[string]bool)
+	add := func(refs []DocRef, kind string) {
+		for _, r := range refs {
+			ext := r.Attachment.ExternalReference
+			id := strings.TrimSpace(r.ID)
+			if id == "" {
+				id = ext.URI
+			}
+			if id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			name := ext.FileName
+			if name == "" {
+				name = id
+			}
+			out = append(out, Document{ID: id, Name: name, URL: ext.URI, Kind: kind})
+		}
+	}
+	add(e.CFS.LegalDocs, "legal")
+	add(e.CFS.TechnicalDocs, "technical")
+	add(e.CFS.AdditionalDocs, "additional")
+	for _, g := range e.CFS.GeneralDocs {
+		add([]DocRef{g.Ref}, "general")
+	}
+	return out
+}
+
+// Award resume el resultado de un lote (o del expediente si no tiene lotes).
+type Award struct {
+	LotID      string    `json:"lotId,omitempty"`
+	ResultCode string    `json:"resultCode"`
+	NIF        string    `json:"nif,omitempty"`
+	Name       string    `json:"name,omitempty"`
+	Amount     float64   `json:"amount,omitempty"`
+	Date       time.Time `json:"date,omitzero"`
+}
+
+func (e *Entry) Awards() []Award {
+	out := make([]Award, 0, len(e.CFS.Results))
+	for i := range e.CFS.Results {
+		r := &e.CFS.Results[i]
+		a := Award{
+			ResultCode: strings.TrimSpace(r.ResultCode.Value),
+			NIF:        r.WinnerNIF(),
+			Name:       r.WinnerName(),
+			Amount:     r.AwardedAmount(),
+		}
+		if r.Awarded != nil {
+			a.LotID = strings.TrimSpace(r.Awarded.LotID)
+		}
+		if r.AwardDate.Valid {
+			a.Date = r.AwardDate.Time
+		}
+		out = append(out, a)
+	}
+	return out
+}
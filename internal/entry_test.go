@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// El anuncio de licitación se repite en varios medios y con rectificaciones
+// (p. ej. la entry 17442349, con DOC_CN del 2025-06-09 y del 2025-08-18): la
+// fecha de publicación es la más temprana.
+func TestPublishedDateEarliestDocCN(t *testing.T) {
+	const src = `<entry><id>17442349</id><ContractFolderStatus>
+  <ValidNoticeInfo>
+    <NoticeTypeCode>DOC_CN</NoticeTypeCode>
+    <AdditionalPublicationStatus>
+      <PublicationMediaName>Perfil del contratante</PublicationMediaName>
+      <AdditionalPublicationDocumentReference><IssueDate>2025-08-18</IssueDate></AdditionalPublicationDocumentReference>
+      <AdditionalPublicationDocumentReference><IssueDate>2025-06-09</IssueDate></AdditionalPublicationDocumentReference>
+    </AdditionalPublicationStatus>
+    <AdditionalPublicationStatus>
+      <PublicationMediaName>DOUE</PublicationMediaName>
+      <AdditionalPublicationDocumentReference><IssueDate>2025-06-12</IssueDate></AdditionalPublicationDocumentReference>
+    </AdditionalPublicationStatus>
+  </ValidNoticeInfo>
+  <ValidNoticeInfo>
+    <NoticeTypeCode>DOC_CAN_ADJ</NoticeTypeCode>
+    <AdditionalPublicationStatus>
+      <AdditionalPublicationDocumentReference><IssueDate>2025-01-01</IssueDate></AdditionalPublicationDocumentReference>
+    </AdditionalPublicationStatus>
+  </ValidNoticeInfo>
+</ContractFolderStatus></entry>`
+	var e Entry
+	if err := xml.Unmarshal([]byte(src), &e); err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC)
+	if got := e.PublishedDate(); !got.Equal(want) {
+		t.Errorf("PublishedDate() = %v, want %v", got, want)
+	}
+}
+
+// El índice ha de filtrar igual que la entry: sin presupuesto publicado no hay
+// valor, aunque Budget sea 0 en los dos casos.
+func TestIndexDocBudgetPresence(t *testing.T) {
+	f, err := CompileFilter("budget <= 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	withZero := parseTestEntry(t, `<ProcurementProject><BudgetAmount><TaxExclusiveAmount currencyID="EUR">0</TaxExclusiveAmount></BudgetAmount></ProcurementProject>`)
+	without := parseTestEntry(t, `<ProcurementProject></ProcurementProject>`)
+	for _, c := range []struct {
+		name string
+		e    *Entry
+		want bool
+	}{{"presupuesto 0", withZero, true}, {"sin presupuesto", without, false}} {
+		doc := NewIndexDoc(c.e)
+		if got := f.Match(c.e); got != c.want {
+			t.Errorf("%s: entry = %v, want %v", c.name, got, c.want)
+		}
+		if got := f.match(&doc); got != c.want {
+			t.Errorf("%s: IndexDoc = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+// Con where, decodeRawEntries deja pasar las mismas entries que sin filtro
+// tienen el estado pedido, y con todos sus nodos.
+func TestDecodeRawEntriesWhere(t *testing.T) {
+	files, err := filepath.Glob("../tests/*.atom")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("sin fixtures: %v", err)
+	}
+	where, err := CompileFilter("status = ADJ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		var want, got []string
+		err := decodeRawEntries(file, nil, func(e *RawEntry) {
+			for _, n := range e.Nodes {
+				if n.Path == "ContractFolderStatus/ContractFolderStatusCode" && n.Value == "ADJ" {
+					want = append(want, fmt.Sprintf("%s %s %d", e.ID, e.Updated, len(e.Nodes)))
+				}
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := decodeRawEntries(file, where, func(e *RawEntry) {
+			got = append(got, fmt.Sprintf("%s %s %d", e.ID, e.Updated, len(e.Nodes)))
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: got %v, want %v", filepath.Base(file), got, want)
+		}
+	}
+}
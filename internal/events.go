@@ -0,0 +1,282 @@
+package internal
+
+import (
+	"strings"
+	"time"
+)
+
+// EventType usa los mismos valores que cron_job/src/domain/Event.ts para que
+// ambos runtimes escriban eventos intercambiables.
+type EventType string
+
+const (
+	EventCreated                  EventType = "licitation_created"
+	EventFinishedSubmissionPeriod EventType = "licitation_finished_submission_period"
+	EventResolved                 EventType = "licitation_resolved"
+	EventLotAwarded               EventType = "licitation_lot_awarded"
+	EventAwarded                  EventType = "licitation_awarded"
+
+	// Sólo en Go por ahora
+	EventNewDocument     EventType = "licitation_new_document"
+	EventDeadlineChanged EventType = "licitation_deadline_changed"
+	EventAmountChanged   EventType = "licitation_amount_changed"
+	EventAnnulled        EventType = "licitation_annulled"
+	EventDesisted        EventType = "licitation_desisted"
+	EventRenounced       EventType = "licitation_renounced"
+	EventSearchMatch     EventType = "saved_search_match"
+)
+
+// Event lleva consigo los datos básicos de la entry para que notificadores y
+// resúmenes no tengan que volver a consultarla.
+type Event struct {
+	Type      EventType `json:"type"`
+	CreatedAt time.Time `json:"createdAt"`
+	EntryID   string    `json:"entryId"`
+	LotID     string    `json:"lotId,omitempty"`
+	Title     string    `json:"title,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	OrgName   string    `json:"orgName,omitempty"`
+	CPVs      []string  `json:"cpvs,omitempty"`
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to,omitempty"`
+	Document  *Document `json:"document,omitempty"`
+	Award     *Award    `json:"award,omitempty"`
+	Search    string    `json:"search,omitempty"` // búsqueda guardada, en EventSearchMatch
+}
+
+func newEvent(t EventType, at time.Time, e *Entry) Event {
+	return Event{
+		Type:      t,
+		CreatedAt: at,
+		EntryID:   e.ID,
+		Title:     strings.TrimSpace(e.Title),
+		URL:       e.URL(),
+		OrgName:   e.OrgName(),
+		CPVs:      e.CPVs(),
+	}
+}
+
+// ---------- Lotes como los ve cronJob.ts ----------
+
+// eventLot replica ParsedLot: sin lotes la entry cuenta como un único lote "0"
+// con el resultado del expediente.
+type eventLot struct {
+	id    string
+	award Award
+}
+
+func eventLots(e *Entry) []eventLot {
+	if len(e.CFS.Lots) == 0 {
+		l := eventLot{id: "0"}
+		if aw := e.Awards(); len(aw) > 0 {
+			l.award = aw[0]
+			l.award.LotID = "0"
+			if l.award.ResultCode == ResultDesisted {
+				l.award = Award{LotID: "0", ResultCode: ResultDesisted}
+			}
+		}
+		return []eventLot{l}
+	}
+
+	out := make([]eventLot, 0, len(e.CFS.Lots))
+	for i := range e.CFS.Lots {
+		l := eventLot{id: e.CFS.Lots[i].LotID()}
+		for _, a := range e.Awards() {
+			if a.LotID == l.id {
+				l.award = a
+				break
+			}
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+// awardedLots cuenta los lotes resueltos como lo hace feedParser.ts (lotsAdj).
+func awardedLots(e *Entry) int {
+	n := 0
+	for _, l := range eventLots(e) {
+		if l.award.ResultCode == "" {
+			continue
+		}
+		if len(e.CFS.Lots) == 0 && l.award.ResultCode == ResultDesisted {
+			continue
+		}
+		if len(e.CFS.Lots) > 0 && l.award.ResultCode == ResultDeserted {
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+func awardTime(a Award, fallback time.Time) time.Time {
+	if !a.Date.IsZero() {
+		return a.Date
+	}
+	return fallback
+}
+
+// ---------- Derivación ----------
+
+// DeriveEvents calcula los eventos que produce pasar de prev a next. prev es nil
+// si la entry no se había visto nunca. Versiones iguales o más antiguas que prev
+// no producen eventos.
+func DeriveEvents(prev *Entry, next *Entry) []Event {
+	if prev == nil {
+		return deriveCreated(next)
+	}
+	if !next.Updated.After(prev.Updated.Time) {
+		return nil
+	}
+
+	var events []Event
+	at := next.Updated.Time
+	prevStatus, status := prev.Status(), next.Status()
+
+	if prevStatus == StatusPublished && status == StatusEvaluated {
+		events = append(events, newEvent(EventFinishedSubmissionPeriod, at, next))
+	} else if prevStatus != StatusResolved && status == StatusResolved {
+		events = append(events, newEvent(EventResolved, at, next))
+	}
+	if prevStatus != StatusAnnulled && status == StatusAnnulled {
+		events = append(events, newEvent(EventAnnulled, at, next))
+	}
+
+	prevLots := make(map[string]eventLot)
+	for _, l := range eventLots(prev) {
+		prevLots[l.id] = l
+	}
+	lots := eventLots(next)
+	for _, l := range lots {
+		p, ok := prevLots[l.id]
+		if !ok {
+			continue
+		}
+		if p.award.NIF == "" && l.award.NIF != "" {
+			ev := newEvent(EventLotAwarded, awardTime(l.award, at), next)
+			ev.LotID = l.id
+			aw := l.award
+			ev.Award = &aw
+			events = append(events, ev)
+		}
+		if p.award.ResultCode != l.award.ResultCode {
+			if t, ok := withdrawalEvent(l.award.ResultCode); ok {
+				ev := newEvent(t, awardTime(l.award, at), next)
+				if len(next.CFS.Lots) > 0 {
+					ev.LotID = l.id
+				}
+				events = append(events, ev)
+			}
+		}
+	}
+
+	if n := len(lots); awardedLots(prev) < n && awardedLots(next) == n {
+		ev := newEvent(EventAwarded, at, next)
+		if r := next.ResultFor(""); len(next.CFS.Lots) == 0 && r != nil && r.AwardDate.Valid {
+			ev.CreatedAt = r.AwardDate.Time
+		}
+		events = append(events, ev)
+	}
+
+	if a, b := prev.Deadline(), next.Deadline(); !a.IsZero() && !b.IsZero() && !a.Equal(b) {
+		ev := newEvent(EventDeadlineChanged, at, next)
+		ev.From, ev.To = formatDate(a), formatDate(b)
+		events = append(events, ev)
+	}
+	if a, b := prev.Budget(), next.Budget(); a != 0 && b != 0 && a != b {
+		ev := newEvent(EventAmountChanged, at, next)
+		ev.From, ev.To = formatAmount(a), formatAmount(b)
+		events = append(events, ev)
+	}
+
+	known := make(map[string]bool)
+	for _, d := range prev.Documents() {
+		known[d.ID] = true
+	}
+	for _, d := range next.Documents() {
+		if known[d.ID] {
+			continue
+		}
+		ev := newEvent(EventNewDocument, at, next)
+		doc := d
+		ev.Document = &doc
+		events = append(events, ev)
+	}
+
+	return events
+}
+
+// deriveCreated cubre la primera vez que vemos una entry, que puede llegar ya en
+// cualquier estado.
+func deriveCreated(e *Entry) []Event {
+	var events []Event
+	at := e.Updated.Time
+	status := e.Status()
+	lots := eventLots(e)
+
+	if status == StatusAwarded {
+		for _, l := range lots {
+			if l.award.NIF == "" {
+				continue
+			}
+			ev := newEvent(EventLotAwarded, awardTime(l.award, at), e)
+			ev.LotID = l.id
+			aw := l.award
+			ev.Award = &aw
+			events = append(events, ev)
+		}
+	}
+
+	switch {
+	case status == StatusPublished:
+		created := at
+		if p := e.PublishedDate(); !p.IsZero() {
+			created = p
+		}
+		events = append(events, newEvent(EventCreated, created, e))
+	case status == StatusEvaluated:
+		events = append(events, newEvent(EventFinishedSubmissionPeriod, at, e))
+	case status == StatusAwarded && awardedLots(e) == len(lots):
+		events = append(events, newEvent(EventAwarded, at, e))
+	case status == StatusResolved:
+		events = append(events, newEvent(EventResolved, at, e))
+		for _, l := range lots {
+			if t, ok := withdrawalEvent(l.award.ResultCode); ok {
+				ev := newEvent(t, awardTime(l.award, at), e)
+				if len(e.CFS.Lots) > 0 {
+					ev.LotID = l.id
+				}
+				events = append(events, ev)
+			}
+		}
+	case status == StatusAnnulled:
+		events = append(events, newEvent(EventAnnulled, at, e))
+	}
+	return events
+}
+
+func withdrawalEvent(resultCode string) (EventType, bool) {
+	switch resultCode {
+	case ResultDesisted:
+		return EventDesisted, true
+	case ResultRenounced:
+		return EventRenounced, true
+	}
+	return "", false
+}
+
+// DeriveTombstoneEvents traduce un at:deleted-entry. Sólo ANULADA genera evento;
+// CERRADA es el archivado normal de expedientes ya terminados.
+func DeriveTombstoneEvents(prev *Entry, t Tombstone) []Event {
+	if !strings.EqualFold(t.Type, "ANULADA") {
+		return nil
+	}
+	if prev == nil {
+		return []Event{{Type: EventAnnulled, CreatedAt: t.When.Time, EntryID: t.Ref}}
+	}
+	if prev.Status() == StatusAnnulled || t.When.Before(prev.Updated.Time) {
+		return nil
+	}
+	return []Event{newEvent(EventAnnulled, t.When.Time, prev)}
+}
@@ -10,7 +10,6 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
-	"strings"
 	"sync"
 	"time"
 )
@@ -34,13 +33,18 @@ func parseTimestampFromPath(p string, loc *time.Location) (time.Time, bool) {
 	return ts, true
 }
 
-func ExtractContractHistory(dir string, refID string, createdAt time.Time) error {
+// ExtractContractHistory busca en el archivo de dir todas las versiones de
+// refID. Con save no vacío vuelca además los <entry> en bruto en ese fichero,
+// que es el formato de los fixtures de tests/ para replay. Si no hay ninguna
+// versión devuelve un error y no escribe save.
+func ExtractContractHistory(dir string, refID string, createdAt time.Time, save string) (*History, error) {
 	files, err := listLocalAtoms(dir)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	entryHistory := make([]string, 0)
+	versions := make([]Entry, 0)
 	var mu sync.Mutex
 
 	workers := 8
@@ -142,8 +146,14 @@ func ExtractContractHistory(dir string, refID string, createdAt time.Time) error
 							continue
 						}
 						if mini.ID == refID {
+							var e Entry
+							if err := xml.Unmarshal(frag, &e); err != nil {
+								log.Printf("[XML] %s unmarshal entry: %v", path, err)
+								continue
+							}
 							mu.Lock()
 							entryHistory = append(entryHistory, string(frag))
+							versions = append(versions, e)
 							mu.Unlock()
 						}
 					}
@@ -154,20 +164,24 @@ func ExtractContractHistory(dir string, refID string, createdAt time.Time) error
 
 	wg.Wait()
 
-	var buffer bytes.Buffer
-	for _, val := range entryHistory {
-		buffer.WriteString(val)
+	if len(versions) == 0 {
+		if !createdAt.IsZero() {
+			return nil, fmt.Errorf("%s: ninguna versión en %s desde %s", refID, dir, createdAt.Format("2006-01-02"))
+		}
+		return nil, fmt.Errorf("%s: ninguna versión en %s", refID, dir)
 	}
-
-	path := strings.ReplaceAll(refID[8:], "/", "_")
-	path = strings.ReplaceAll(path, ".", "")
-	err = os.WriteFile("tests/"+path+".atom", buffer.Bytes(), 0644)
-	if err != nil {
-		log.Println("[ERROR]" + err.Error())
-		return nil
+	if save != "" {
+		var buffer bytes.Buffer
+		for _, val := range entryHistory {
+			buffer.WriteString(val)
+		}
+		if err := os.WriteFile(save, buffer.Bytes(), 0644); err != nil {
+			return nil, err
+		}
 	}
 
-	fmt.Println("Vamos a ver esto", len(entryHistory))
+	h := NewHistory(refID, versions)
+	log.Printf("[done] %s: %d fragmentos, %d versiones", refID, len(entryHistory), len(h.Versions))
 
-	return nil
+	return h, nil
 }
@@ -1,18 +1,15 @@
 package internal
 
 import (
-	"bufio"
+	"context"
 	"encoding/csv"
-	"encoding/xml"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
@@ -33,12 +30,28 @@ type Org struct {
 	TendersCount      int
 }
 
+// OrgAgg es el Partial de ParseAtom.
 type OrgAgg struct {
 	byKey map[string]*Org // key = dir3|normalizedName
+	opts  *ParseAtomOptions
 }
 
-func NewOrgAgg() *OrgAgg {
-	return &OrgAgg{byKey: make(map[string]*Org)}
+func NewOrgAgg(opts *ParseAtomOptions) *OrgAgg {
+	if opts == nil {
+		opts = &ParseAtomOptions{}
+	}
+	return &OrgAgg{byKey: make(map[string]*Org), opts: opts}
+}
+
+func (a *OrgAgg) Add(e *Entry, _ string) {
+	a.ingestEntry(*e, a.opts)
+}
+
+// Merge suma las filas de other como si sus entries hubieran llegado después.
+func (a *OrgAgg) Merge(other *OrgAgg) {
+	for key, row := range other.byKey {
+		a.addRow(key, row)
+	}
 }
 
 func (a *OrgAgg) keyFor(dir3, name string) string {
@@ -50,30 +63,33 @@ func (a *OrgAgg) keyFor(dir3, name string) string {
 	return "NAME:" + n
 }
 
-var CPVPrefixes = []string{"09132", "09134"}
-
-func isValidCPV(cpv string) bool {
-	c := strings.TrimSpace(cpv)
-	c = strings.ReplaceAll(c, "-", "") // "09120000-6" -> "091200006"
-	for _, p := range CPVPrefixes {
-		if strings.HasPrefix(c, p) {
-			return true
-		}
-	}
-	return false
+// ParseAtomOptions configura el directorio de organismos que genera ParseAtom.
+type ParseAtomOptions struct {
+	CPV     CPVFilter
+	Output  string    // ruta del CSV; por defecto "dondesea.csv", como antes
+	From    time.Time // ventana sobre la fecha de publicación (DOC_CN, o updated si no hay)
+	To      time.Time // exclusiva; cero = sin límite
+	Workers int       // por defecto 8
+	Where   *Filter   // sobre cada versión de la entry
 }
 
-func (a *OrgAgg) ingestEntry(e Entry) {
-	// 1) Extraer CPVs y filtrar por hidrocarburos
-	var hasCPV bool
-	for _, c := range e.CFS.Project.Commodity {
-		if isValidCPV(c.CPV.Value) {
-			hasCPV = true
-			break
-		}
+func (o *ParseAtomOptions) inWindow(e *Entry) bool {
+	t := e.PublishedDate()
+	if t.IsZero() {
+		t = e.Updated.Time
 	}
+	if !o.From.IsZero() && t.Before(o.From) {
+		return false
+	}
+	if !o.To.IsZero() && !t.Before(o.To) {
+		return false
+	}
+	return true
+}
 
-	if !hasCPV {
+func (a *OrgAgg) ingestEntry(e Entry, opts *ParseAtomOptions) {
+	// 1) Filtrar por CPV y fecha
+	if !opts.CPV.MatchAny(e.CPVs()) || !opts.inWindow(&e) || !opts.Where.Match(&e) {
 		return
 	}
 
@@ -89,79 +105,83 @@ func (a *OrgAgg) ingestEntry(e Entry) {
 		}
 	}
 
-	var publishedDate time.Time
-	for _, n := range e.CFS.Notices {
-		if strings.EqualFold(n.NoticeType.Value, "DOC_CN") {
-			publishedDate = n.Status.DocRef.IssueDate.Time
-			break
-		}
-	}
+	publishedDate := e.PublishedDate()
 
-	fields := e.CFS.LocatedParty.Party.PostalAddress
+	var fields Address
+	if addr := e.CFS.LocatedParty.Party.PostalAddress; addr != nil {
+		fields = *addr
+	}
 
-	email := e.CFS.LocatedParty.Party.Contact.Mail
-	phone := e.CFS.LocatedParty.Party.Contact.Phone
+	var email, phone string
+	if c := e.CFS.LocatedParty.Party.Contact; c != nil {
+		email, phone = c.Mail, c.Phone
+	}
 
-	lastTenderUrl := e.Links[0].Href
+	lastTenderUrl := e.URL()
+
+	a.addRow(a.keyFor(dir3, orgName), &Org{
+		Dir3:              dir3,
+		NIF:               nif,
+		OrgName:           orgName,
+		ProfileUrl:        profileUrl,
+		Email:             email,
+		Phone:             phone,
+		AddressLine:       fields.Line,
+		PostalCode:        fields.PostalZone,
+		City:              fields.City,
+		Country:           fields.Country.Code.Value,
+		LastPublishedDate: publishedDate,
+		LastTenderURL:     lastTenderUrl,
+		TendersCount:      1,
+	})
+}
 
-	key := a.keyFor(dir3, orgName)
+// addRow acumula src en la fila de key: los datos de contacto son los de la
+// publicación más reciente, completados con los anteriores si faltan.
+func (a *OrgAgg) addRow(key string, src *Org) {
 	row, ok := a.byKey[key]
 	if !ok {
-		row = &Org{
-			Dir3:              dir3,
-			NIF:               nif,
-			OrgName:           orgName,
-			ProfileUrl:        profileUrl,
-			Email:             email,
-			Phone:             phone,
-			AddressLine:       fields.Line,
-			PostalCode:        fields.PostalZone,
-			City:              fields.City,
-			Country:           fields.Country.Code.Value,
-			LastPublishedDate: publishedDate,
-			LastTenderURL:     lastTenderUrl,
-			TendersCount:      1,
-		}
-		a.byKey[key] = row
+		cp := *src
+		a.byKey[key] = &cp
 		return
 	}
 
-	row.TendersCount++
-
-	if publishedDate.After(row.LastPublishedDate) {
-		row.LastPublishedDate = publishedDate
-		row.LastTenderURL = lastTenderUrl
-		row.Email = email
-		row.Phone = phone
-		row.AddressLine = fields.Line
-		row.PostalCode = fields.PostalZone
-		row.City = fields.City
-		row.Country = fields.Country.Code.Value
-		row.NIF = nif
+	row.TendersCount += src.TendersCount
+
+	if src.LastPublishedDate.After(row.LastPublishedDate) {
+		row.LastPublishedDate = src.LastPublishedDate
+		row.LastTenderURL = src.LastTenderURL
+		row.Email = src.Email
+		row.Phone = src.Phone
+		row.AddressLine = src.AddressLine
+		row.PostalCode = src.PostalCode
+		row.City = src.City
+		row.Country = src.Country
+		row.NIF = src.NIF
 		return
 	}
 
 	// Rellenar si antes estaban vacíos
-	if row.Email == "" && email != "" {
-		row.Email = email
+	if row.Email == "" && src.Email != "" {
+		row.Email = src.Email
 	}
-	if row.Phone == "" && phone != "" {
-		row.Phone = phone
+	if row.Phone == "" && src.Phone != "" {
+		row.Phone = src.Phone
 	}
-	if row.AddressLine == "" && fields.Line != "" {
-		row.AddressLine = fields.Line
+	if row.AddressLine == "" && src.AddressLine != "" {
+		row.AddressLine = src.AddressLine
 	}
-	if row.PostalCode == "" && fields.PostalZone != "" {
-		row.PostalCode = fields.PostalZone
+	if row.PostalCode == "" && src.PostalCode != "" {
+		row.PostalCode = src.PostalCode
 	}
-	if row.City == "" && fields.City != "" {
-		row.City = fields.City
+	if row.City == "" && src.City != "" {
+		row.City = src.City
 	}
-	if row.Country == "" && fields.Country.Code.Value != "" {
-		row.Country = fields.Country.Code.Value
+	if row.Country == "" && src.Country != "" {
+		row.Country = src.Country
 	}
-	if row.NIF == "" && nif != "" {
-		row.NIF = nif
+	if row.NIF == "" && src.NIF != "" {
+		row.NIF = src.NIF
 	}
 }
 
@@ -184,7 +204,7 @@ func (a *OrgAgg) writeCSV(filename string) error {
 	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
 		return err
 	}
-	f, err := os.Create(".\\" + filename + ".tmp")
+	f, err := os.Create(filename + ".tmp")
 	if err != nil {
 		return err
 	}
@@ -217,73 +237,37 @@ func (a *OrgAgg) writeCSV(filename string) error {
 		return err
 	}
 	// Publicación atómica
-	return os.Rename(".\\"+filename+".tmp", ".\\"+filename)
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(filename+".tmp", filename)
 }
 
-func ParseAtom(dir string) error {
+// ParseAtom recorre los atom de dir y escribe el directorio de organismos con
+// licitaciones que pasan los filtros de opts.
+func ParseAtom(dir string, opts ParseAtomOptions) error {
 	startTime := time.Now()
+	if opts.Output == "" {
+		opts.Output = "dondesea.csv"
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 8
+	}
 
 	files, err := listLocalAtoms(dir)
 	if err != nil {
 		return err
 	}
 
-	workers := 8
-	fileCh := make(chan string, workers*2)
-	agg := NewOrgAgg()
-
-	var wg sync.WaitGroup
-	wg.Add(workers)
-	for w := 0; w < workers; w++ {
-		go func() {
-			defer wg.Done()
-
-			dec := xml.NewDecoder(nil) //gets reassigned every file
-			for path := range fileCh {
-				pathStart := time.Now()
-
-				f, err := os.Open(path)
-				if err != nil {
-					log.Printf("[WARN] %v", err)
-				}
-
-				dec = xml.NewDecoder(bufio.NewReaderSize(f, 256<<10))
-				for {
-					tok, err := dec.Token()
-
-					if err == io.EOF {
-						break
-					}
-
-					if err != nil {
-						log.Printf("[XML] %s %v", path, err)
-						break
-					}
-
-					if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "entry" {
-						var e Entry
-						if err := dec.DecodeElement(&e, &se); err == nil {
-							agg.ingestEntry(e)
-						}
-					}
-				}
-
-				_ = f.Close()
-				log.Printf("Took %s to process %s", time.Since(pathStart), path)
-			}
-		}()
-	}
-
-	for _, f := range files {
-		fileCh <- f
+	agg, err := ScanAtoms(context.Background(), files, opts.Workers, func() *OrgAgg {
+		return NewOrgAgg(&opts)
+	})
+	if err != nil {
+		return err
 	}
-	close(fileCh)
-	wg.Wait()
 
 	elapsed := time.Since(startTime)
 	log.Printf("[done] Total: %d organismos únicos, tiempo: %s",
 		len(agg.byKey), elapsed)
-	agg.writeCSV("dondesea.csv")
-
-	return nil
+	return agg.writeCSV(opts.Output)
 }
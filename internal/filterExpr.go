@@ -0,0 +1,685 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Lenguaje de filtros para seleccionar entries, p.ej.
+//
+//	cpv ^= "0913" and nuts ^= "ES52" and budget >= 100000 and status in (PUB, EV)
+//
+// Comparaciones campo-operador-valor combinadas con and, or, not y paréntesis.
+// Los textos se comparan sin distinguir mayúsculas ni tildes; en los campos con
+// varios valores (cpv, winner_nif) basta con que uno cumpla. Un campo vacío no
+// cumple ninguna comparación salvo !=.
+
+type fieldKind int
+
+const (
+	kindText fieldKind = iota
+	kindNumber
+	kindDate
+)
+
+func (k fieldKind) String() string {
+	switch k {
+	case kindNumber:
+		return "número"
+	case kindDate:
+		return "fecha"
+	}
+	return "texto"
+}
+
+type filterField struct {
+	Name string
+	kind fieldKind
+	Doc  string
+}
+
+// FilterFields son los campos que admite el lenguaje.
+var FilterFields = []filterField{
+	{"id", kindText, "URL de la entry"},
+	{"folder", kindText, "número de expediente (ContractFolderID)"},
+	{"title", kindText, "título"},
+	{"summary", kindText, "resumen"},
+	{"status", kindText, "estado (PRE, PUB, EV, ADJ, RES, ANUL)"},
+	{"cpv", kindText, "CPV del expediente y de los lotes (^= con un código completo incluye sus descendientes)"},
+	{"nuts", kindText, "NUTS del lugar de ejecución"},
+	{"province", kindText, "provincia (por el NUTS o el código postal del órgano)"},
+	{"region", kindText, "comunidad autónoma (por el NUTS o el código postal del órgano)"},
+	{"buyer", kindText, "nombre del órgano de contratación"},
+	{"buyer_nif", kindText, "NIF del órgano"},
+	{"dir3", kindText, "DIR3 del órgano"},
+	{"procedure", kindText, "ProcedureCode"},
+	{"type", kindText, "tipo de contrato (TypeCode)"},
+	{"winner_nif", kindText, "NIF de los adjudicatarios"},
+	{"budget", kindNumber, "presupuesto sin impuestos"},
+	{"lots", kindNumber, "número de lotes"},
+	{"updated", kindDate, "fecha de la versión"},
+	{"published", kindDate, "fecha del anuncio de licitación (DOC_CN)"},
+	{"deadline", kindDate, "fin del plazo de presentación"},
+}
+
+func lookupField(name string) *filterField {
+	for i := range FilterFields {
+		if FilterFields[i].Name == name {
+			return &FilterFields[i]
+		}
+	}
+	return nil
+}
+
+// filterRecord es aquello sobre lo que se evalúa un filtro: una Entry o, en el
+// buscador, un IndexDoc.
+type filterRecord interface {
+	texts(field string) []string
+	number(field string) (float64, bool)
+	date(field string) time.Time
+}
+
+type entryRecord struct{ e *Entry }
+
+func (r entryRecord) texts(field string) []string {
+	e := r.e
+	one := func(s string) []string {
+		if s = strings.TrimSpace(s); s == "" {
+			return nil
+		}
+		return []string{s}
+	}
+	switch field {
+	case "id":
+		return one(e.ID)
+	case "folder":
+		return one(e.CFS.ContractFolderID)
+	case "title":
+		return one(e.Title)
+	case "summary":
+		return one(e.Summary)
+	case "status":
+		return one(e.Status())
+	case "cpv":
+		cpvs := e.CPVs()
+		for i := range e.CFS.Lots {
+			for _, c := range commodityCPVs(e.CFS.Lots[i].Project.Commodity) {
+				if !slices.Contains(cpvs, c) {
+					cpvs = append(cpvs, c)
+				}
+			}
+		}
+		return cpvs
+	case "nuts":
+		return one(e.NUTS())
+	case "province", "region":
+		g := e.Geo()
+		if g == nil {
+			return nil
+		}
+		if field == "province" {
+			return one(g.Province)
+		}
+		return one(g.Region)
+	case "buyer":
+		return one(e.OrgName())
+	case "buyer_nif":
+		return one(e.PartyID("NIF"))
+	case "dir3":
+		return one(e.PartyID("DIR3"))
+	case "procedure":
+		return one(e.CFS.Process.ProcedureCode.Value)
+	case "type":
+		return one(e.CFS.Project.TypeCode.Value)
+	case "winner_nif":
+		var out []string
+		for i := range e.CFS.Results {
+			for _, p := range awardParties(&e.CFS.Results[i]) {
+				if !slices.Contains(out, p.NIF) {
+					out = append(out, p.NIF)
+				}
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func (r entryRecord) number(field string) (float64, bool) {
+	switch field {
+	case "budget":
+		b := r.e.Budget()
+		return b, r.e.CFS.Project.Budget != nil
+	case "lots":
+		return float64(len(r.e.CFS.Lots)), true
+	}
+	return 0, false
+}
+
+func (r entryRecord) date(field string) time.Time {
+	switch field {
+	case "updated":
+		return r.e.Updated.Time
+	case "published":
+		return r.e.PublishedDate()
+	case "deadline":
+		return r.e.Deadline()
+	}
+	return time.Time{}
+}
+
+// ---------- Errores ----------
+
+// FilterError señala la posición (en bytes) de la expresión donde falla.
+type FilterError struct {
+	Expr string
+	Pos  int
+	Msg  string
+}
+
+func (e *FilterError) Error() string {
+	col := len([]rune(e.Expr[:min(e.Pos, len(e.Expr))]))
+	return fmt.Sprintf("filtro: %s (columna %d)\n  %s\n  %s^", e.Msg, col+1, e.Expr, strings.Repeat(" ", col))
+}
+
+// ---------- Léxico ----------
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type filterTok struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+var filterOps = []string{"^=", "$=", "~=", "!=", "<=", ">=", "=", "<", ">"}
+
+func lexFilter(src string) ([]filterTok, error) {
+	var toks []filterTok
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			toks = append(toks, filterTok{tokLParen, "(", i})
+			i++
+		case c == ')':
+			toks = append(toks, filterTok{tokRParen, ")", i})
+			i++
+		case c == ',':
+			toks = append(toks, filterTok{tokComma, ",", i})
+			i++
+		case c == '"' || c == '\'':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				b.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, &FilterError{src, i, "comillas sin cerrar"}
+			}
+			toks = append(toks, filterTok{tokString, b.String(), i})
+			i = j + 1
+		case strings.ContainsRune("^$~!<>=", rune(c)):
+			op := ""
+			for _, o := range filterOps {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, &FilterError{src, i, fmt.Sprintf("operador desconocido %q (válidos: %s)", string(c), strings.Join(filterOps, " "))}
+			}
+			toks = append(toks, filterTok{tokOp, op, i})
+			i += len(op)
+		default:
+			j := i
+			for j < len(src) {
+				r := rune(src[j])
+				if r >= 0x80 || unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.-:/+", r) {
+					j++
+					continue
+				}
+				break
+			}
+			if j == i {
+				return nil, &FilterError{src, i, fmt.Sprintf("carácter inesperado %q", string(c))}
+			}
+			toks = append(toks, filterTok{tokWord, src[i:j], i})
+			i = j
+		}
+	}
+	return append(toks, filterTok{tokEOF, "", len(src)}), nil
+}
+
+// ---------- Sintaxis ----------
+
+type filterNode interface {
+	eval(r filterRecord) bool
+}
+
+type andNode struct{ l, r filterNode }
+type orNode struct{ l, r filterNode }
+type notNode struct{ x filterNode }
+
+func (n andNode) eval(r filterRecord) bool { return n.l.eval(r) && n.r.eval(r) }
+func (n orNode) eval(r filterRecord) bool  { return n.l.eval(r) || n.r.eval(r) }
+func (n notNode) eval(r filterRecord) bool { return !n.x.eval(r) }
+
+type cmpNode struct {
+	field *filterField
+	op    string // operador, o "in"
+	texts []string
+	nums  []float64
+	dates []time.Time
+}
+
+type filterParser struct {
+	src    string
+	toks   []filterTok
+	i      int
+	fields map[string]bool
+}
+
+func (p *filterParser) peek() filterTok { return p.toks[p.i] }
+func (p *filterParser) next() filterTok {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *filterParser) errf(t filterTok, format string, args ...any) error {
+	return &FilterError{p.src, t.pos, fmt.Sprintf(format, args...)}
+}
+
+func isKeyword(t filterTok, kw string) bool {
+	return t.kind == tokWord && strings.EqualFold(t.text, kw)
+}
+
+func describe(t filterTok) string {
+	switch t.kind {
+	case tokEOF:
+		return "el final"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for isKeyword(p.peek(), "or") {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = orNode{l, r}
+	}
+	return l, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for isKeyword(p.peek(), "and") {
+		p.next()
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = andNode{l, r}
+	}
+	return l, nil
+}
+
+func (p *filterParser) parseNot() (filterNode, error) {
+	if isKeyword(p.peek(), "not") {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (filterNode, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokLParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.next(); c.kind != tokRParen {
+			return nil, p.errf(c, "se esperaba ')' para cerrar el '(' de la columna %d y hay %s", t.pos+1, describe(c))
+		}
+		return n, nil
+	case t.kind != tokWord:
+		return nil, p.errf(t, "se esperaba un campo y hay %s", describe(t))
+	}
+
+	name := strings.ToLower(t.text)
+	f := lookupField(name)
+	if f == nil {
+		msg := fmt.Sprintf("campo desconocido %q", t.text)
+		if s := suggestField(name); s != "" {
+			msg += fmt.Sprintf(" (¿%s?)", s)
+		}
+		return nil, p.errf(t, "%s; campos: %s", msg, strings.Join(FilterFieldNames(), ", "))
+	}
+	p.fields[f.Name] = true
+
+	opTok := p.next()
+	n := &cmpNode{field: f}
+	switch {
+	case isKeyword(opTok, "in"):
+		n.op = "in"
+		if c := p.next(); c.kind != tokLParen {
+			return nil, p.errf(c, "se esperaba '(' tras in y hay %s", describe(c))
+		}
+		for {
+			v := p.next()
+			if err := p.addValue(n, v); err != nil {
+				return nil, err
+			}
+			c := p.next()
+			if c.kind == tokRParen {
+				break
+			}
+			if c.kind != tokComma {
+				return nil, p.errf(c, "se esperaba ',' o ')' en la lista de in y hay %s", describe(c))
+			}
+		}
+	case opTok.kind == tokOp:
+		n.op = opTok.text
+		switch n.op {
+		case "^=", "$=", "~=":
+			if f.kind != kindText {
+				return nil, p.errf(opTok, "%s sólo se aplica a campos de texto y %s es un %s", n.op, f.Name, f.kind)
+			}
+		case "<", "<=", ">", ">=":
+			if f.kind == kindText {
+				return nil, p.errf(opTok, "%s no se aplica a %s, que es texto (usa =, !=, ^=, $=, ~= o in)", n.op, f.Name)
+			}
+		}
+		if err := p.addValue(n, p.next()); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, p.errf(opTok, "se esperaba un operador (%s o in) tras %s y hay %s", strings.Join(filterOps, " "), f.Name, describe(opTok))
+	}
+	return n, nil
+}
+
+func (p *filterParser) addValue(n *cmpNode, v filterTok) error {
+	if v.kind != tokWord && v.kind != tokString {
+		return p.errf(v, "se esperaba un valor para %s y hay %s", n.field.Name, describe(v))
+	}
+	switch n.field.kind {
+	case kindNumber:
+		x, err := strconv.ParseFloat(strings.ReplaceAll(v.text, "_", ""), 64)
+		if err != nil {
+			return p.errf(v, "%s es un número y %s no lo es", n.field.Name, describe(v))
+		}
+		n.nums = append(n.nums, x)
+	case kindDate:
+		d, err := parseFilterDate(v.text)
+		if err != nil {
+			return p.errf(v, "%s es una fecha (AAAA-MM-DD) y %s no lo es", n.field.Name, describe(v))
+		}
+		n.dates = append(n.dates, d)
+	default:
+		s := FoldAccents(strings.TrimSpace(v.text))
+		if n.field.Name == "cpv" {
+			// ^= con un código completo incluye sus descendientes; = y in
+			// comparan el código sin el dígito de control.
+			var err error
+			switch n.op {
+			case "^=":
+				s, err = ParseCPVPrefix(s)
+			case "=", "!=", "in":
+				s, err = ParseCPV(s)
+			default:
+				s = cpvDigits(s)
+			}
+			if err != nil {
+				return p.errf(v, "%v", err)
+			}
+		}
+		n.texts = append(n.texts, s)
+	}
+	return nil
+}
+
+func parseFilterDate(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("fecha no válida %q", s)
+}
+
+// suggestField devuelve el campo más parecido si está a 2 ediciones o menos.
+func suggestField(name string) string {
+	best, bestD := "", 3
+	for _, f := range FilterFields {
+		if d := editDistance(name, f.Name); d < bestD {
+			best, bestD = f.Name, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// ---------- Evaluación ----------
+
+func (n *cmpNode) eval(r filterRecord) bool {
+	switch n.field.kind {
+	case kindNumber:
+		v, ok := r.number(n.field.Name)
+		if !ok {
+			return n.op == "!="
+		}
+		return n.any(func(i int) int { return compareFloat(v, n.nums[i]) }, len(n.nums))
+	case kindDate:
+		v := r.date(n.field.Name)
+		if v.IsZero() {
+			return n.op == "!="
+		}
+		return n.any(func(i int) int { return v.Compare(n.dates[i]) }, len(n.dates))
+	}
+
+	vals := r.texts(n.field.Name)
+	if n.op == "!=" {
+		for _, v := range vals {
+			if FoldAccents(v) == n.texts[0] {
+				return false
+			}
+		}
+		return true
+	}
+	for _, v := range vals {
+		v = FoldAccents(v)
+		for _, want := range n.texts {
+			var ok bool
+			switch n.op {
+			case "=", "in":
+				ok = v == want
+			case "^=":
+				ok = strings.HasPrefix(v, want)
+			case "$=":
+				ok = strings.HasSuffix(v, want)
+			case "~=":
+				ok = strings.Contains(v, want)
+			}
+			if ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// any aplica el operador de n comparando con cada valor (cmp(i) = valor - n[i]).
+func (n *cmpNode) any(cmp func(i int) int, count int) bool {
+	for i := 0; i < count; i++ {
+		c := cmp(i)
+		var ok bool
+		switch n.op {
+		case "=", "in":
+			ok = c == 0
+		case "!=":
+			ok = c != 0
+		case "<":
+			ok = c < 0
+		case "<=":
+			ok = c <= 0
+		case ">":
+			ok = c > 0
+		case ">=":
+			ok = c >= 0
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// ---------- API ----------
+
+// Filter es una expresión compilada. Un *Filter nil deja pasar todo.
+type Filter struct {
+	src    string
+	root   filterNode
+	fields []string
+}
+
+// CompileFilter analiza src. Los errores son *FilterError, con la posición.
+func CompileFilter(src string) (*Filter, error) {
+	toks, err := lexFilter(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{src: src, toks: toks, fields: make(map[string]bool)}
+	if p.peek().kind == tokEOF {
+		return nil, p.errf(p.peek(), "expresión vacía")
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		hint := ""
+		if t.kind == tokWord && lookupField(strings.ToLower(t.text)) != nil {
+			hint = " (¿falta and/or?)"
+		}
+		return nil, p.errf(t, "sobra %s%s", describe(t), hint)
+	}
+	f := &Filter{src: src, root: root}
+	for name := range p.fields {
+		f.fields = append(f.fields, name)
+	}
+	sort.Strings(f.fields)
+	return f, nil
+}
+
+// ParseFilterFlag compila el valor de un flag -where; vacío es sin filtro.
+func ParseFilterFlag(s string) (*Filter, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	return CompileFilter(s)
+}
+
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.src
+}
+
+// Fields devuelve los campos que usa la expresión.
+func (f *Filter) Fields() []string {
+	if f == nil {
+		return nil
+	}
+	return f.fields
+}
+
+func (f *Filter) Match(e *Entry) bool {
+	return f == nil || f.root.eval(entryRecord{e})
+}
+
+func (f *Filter) match(r filterRecord) bool {
+	return f == nil || f.root.eval(r)
+}
+
+// FilterFieldNames lista los campos en el orden de FilterFields.
+func FilterFieldNames() []string {
+	out := make([]string, len(FilterFields))
+	for i, f := range FilterFields {
+		out[i] = f.Name
+	}
+	return out
+}
@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilterErrors(t *testing.T) {
+	cases := []struct {
+		expr string
+		pos  int
+		msg  string
+	}{
+		{"", 0, "expresión vacía"},
+		{"title < 3", 6, "< no se aplica a title, que es texto"},
+		{"budget ^= 1", 7, "^= sólo se aplica a campos de texto y budget es un número"},
+		{"budget = mucho", 9, `budget es un número y "mucho" no lo es`},
+		{"updated > 2025-13-01", 10, "updated es una fecha"},
+		{`titel = "x"`, 0, `campo desconocido "titel" (¿title?)`},
+		{`status = "PUB`, 9, "comillas sin cerrar"},
+		{"status ! PUB", 7, `operador desconocido "!"`},
+		{"status # PUB", 7, `carácter inesperado "#"`},
+		{"status in (PUB EV)", 15, "se esperaba ',' o ')' en la lista de in"},
+		{"status in PUB", 10, "se esperaba '(' tras in"},
+		{"(status = PUB", 13, "se esperaba ')' para cerrar el '(' de la columna 1 y hay el final"},
+		{"status = PUB cpv = 09134000", 13, `sobra "cpv" (¿falta and/or?)`},
+		{"status =", 8, "se esperaba un valor para status y hay el final"},
+		{"status PUB", 7, `se esperaba un operador (^= $= ~= != <= >= = < > o in) tras status y hay "PUB"`},
+		{"cpv ^= 99", 7, "la división 99 no existe"},
+		{"cpv = 0913", 6, "deben ser 8 dígitos"},
+	}
+	for _, c := range cases {
+		_, err := CompileFilter(c.expr)
+		var fe *FilterError
+		if !errors.As(err, &fe) {
+			t.Errorf("%q: error %v, want *FilterError", c.expr, err)
+			continue
+		}
+		if fe.Pos != c.pos || !strings.Contains(fe.Msg, c.msg) {
+			t.Errorf("%q: posición %d, %q; want %d, %q", c.expr, fe.Pos, fe.Msg, c.pos, c.msg)
+		}
+	}
+
+	// La columna del mensaje cuenta caracteres, no bytes.
+	_, err := CompileFilter(`title ~= "camión" and budget < x`)
+	if err == nil || !strings.Contains(err.Error(), "(columna 32)\n  title ~= \"camión\" and budget < x\n"+strings.Repeat(" ", 33)+"^") {
+		t.Errorf("Error() = %q", err)
+	}
+}
+
+func TestFilterEval(t *testing.T) {
+	doc := &IndexDoc{
+		Title:     "Suministro de gasóleo",
+		Status:    "PUB",
+		CPVs:      []string{"09134000", "34100000"},
+		Budget:    50000,
+		HasBudget: true,
+		Updated:   time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	noBudget := &IndexDoc{Title: "Sin presupuesto", Status: "PUB"}
+	cases := []struct {
+		expr string
+		doc  *IndexDoc
+		want bool
+	}{
+		{"status = pub", doc, true},
+		{`title ~= "GASOLEO"`, doc, true},
+		{`title ^= suministro and title $= "óleo"`, doc, true},
+
+		// not > and > or
+		{"status = ADJ and status = PUB or status = PUB", doc, true},
+		{"status = PUB or status = ADJ and status = ADJ", doc, true},
+		{"(status = PUB or status = ADJ) and status = ADJ", doc, false},
+		{"not status = PUB and status = ADJ", doc, false},
+		{"not (status = PUB and status = ADJ)", doc, true},
+		{"not not status = PUB", doc, true},
+		{"STATUS = PUB AND NOT status = ADJ", doc, true},
+
+		// in
+		{"status in (ADJ, PUB)", doc, true},
+		{"status in (ADJ, RES)", doc, false},
+		{"cpv in (09134000-7, 45000000)", doc, true},
+		{"budget in (1, 50_000)", doc, true},
+
+		// cpv: ^= con un código completo incluye sus descendientes
+		{"cpv ^= 09100000", doc, true},
+		{"cpv ^= 09130000-9", doc, true},
+		{"cpv ^= 09200000", doc, false},
+		{"cpv ^= 341", doc, true},
+		{"cpv = 09130000", doc, false},
+		{"cpv = 34100000-8", doc, true},
+		{"cpv != 09134000", doc, false},
+		{"cpv != 09000000", doc, true},
+
+		// Un campo vacío sólo cumple !=
+		{"buyer ~= ''", doc, false},
+		{"buyer = ''", doc, false},
+		{"buyer != 'Ayuntamiento'", doc, true},
+		{"budget != 0", noBudget, true},
+		{"budget < 1", noBudget, false},
+		{"budget = 0", noBudget, false},
+		{"budget >= 50000 and budget <= 50000", doc, true},
+		{"updated >= 2025-03-01", doc, true},
+		{"updated > 2025-03-01T00:00:00Z", doc, false},
+		{"updated != 2025-01-01", noBudget, true},
+		{"updated < 2030-01-01", noBudget, false},
+	}
+	for _, c := range cases {
+		f, err := CompileFilter(c.expr)
+		if err != nil {
+			t.Errorf("%q: %v", c.expr, err)
+			continue
+		}
+		if got := f.match(c.doc); got != c.want {
+			t.Errorf("%q sobre %q = %v, want %v", c.expr, c.doc.Title, got, c.want)
+		}
+	}
+
+	f, err := CompileFilter("status = PUB or budget > 1 and cpv ^= 09")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"budget", "cpv", "status"}; !slices.Equal(f.Fields(), want) {
+		t.Errorf("Fields() = %v, want %v", f.Fields(), want)
+	}
+	var none *Filter
+	if !none.Match(&Entry{}) || none.Fields() != nil {
+		t.Error("un *Filter nil deja pasar todo")
+	}
+	if f, err := ParseFilterFlag("  "); f != nil || err != nil {
+		t.Errorf("ParseFilterFlag vacío = %v, %v", f, err)
+	}
+}
@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version es una publicación concreta de una entry.
+type Version struct {
+	Updated time.Time `json:"updated"`
+	Status  string    `json:"status"`
+	Entry   Entry     `json:"-"`
+}
+
+// History agrupa todas las versiones conocidas de un mismo ID, de la más antigua
+// a la más reciente, con el diff entre cada par consecutivo.
+type History struct {
+	ID       string        `json:"id"`
+	Versions []Version     `json:"versions"`
+	Diffs    []VersionDiff `json:"diffs"`
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+type VersionDiff struct {
+	From       time.Time     `json:"from"`
+	To         time.Time     `json:"to"`
+	Changes    []FieldChange `json:"changes,omitempty"`
+	NewDocs    []Document    `json:"newDocs,omitempty"`
+	NewWinners []Award       `json:"newWinners,omitempty"`
+	// Adjudicaciones de la versión anterior que ya no están, cambian de
+	// adjudicatario o pasan a desistimiento o renuncia.
+	RemovedWinners []Award `json:"removedWinners,omitempty"`
+}
+
+// NewHistory ordena las versiones por Updated y descarta duplicados exactos
+// (la misma versión aparece en varios ficheros del archivo).
+func NewHistory(id string, entries []Entry) *History {
+	h := &History{ID: id}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Updated.Before(entries[j].Updated.Time)
+	})
+	for _, e := range entries {
+		if n := len(h.Versions); n > 0 && h.Versions[n-1].Updated.Equal(e.Updated.Time) {
+			continue
+		}
+		h.Versions = append(h.Versions, Version{Updated: e.Updated.Time, Status: e.Status(), Entry: e})
+	}
+	for i := 1; i < len(h.Versions); i++ {
+		h.Diffs = append(h.Diffs, DiffVersions(&h.Versions[i-1].Entry, &h.Versions[i].Entry))
+	}
+	return h
+}
+
+// Latest devuelve la última versión o nil si no hay ninguna.
+func (h *History) Latest() *Entry {
+	if len(h.Versions) == 0 {
+		return nil
+	}
+	return &h.Versions[len(h.Versions)-1].Entry
+}
+
+// Filter devuelve la historia con sólo las versiones que cumplen f; los diffs
+// pasan a ser entre versiones consecutivas de las que quedan.
+func (h *History) Filter(f *Filter) *History {
+	if f == nil {
+		return h
+	}
+	var entries []Entry
+	for _, v := range h.Versions {
+		if f.Match(&v.Entry) {
+			entries = append(entries, v.Entry)
+		}
+	}
+	return NewHistory(h.ID, entries)
+}
+
+// LoadHistoryFile lee un fichero con los <entry> concatenados de un ID, como los
+// que vuelca ExtractContractHistory con save.
+func LoadHistoryFile(path string) (*History, error) {
+	var entries []Entry
+	if err := decodeEntries(path, func(e Entry) { entries = append(entries, e) }); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s: no entries", path)
+	}
+	return NewHistory(entries[0].ID, entries), nil
+}
+
+// ---------- Diff ----------
+
+type diffField struct {
+	name string
+	get  func(e *Entry) string
+}
+
+var entryDiffFields = []diffField{
+	{"status", func(e *Entry) string { return e.Status() }},
+	{"title", func(e *Entry) string { return strings.TrimSpace(e.Title) }},
+	{"budget", func(e *Entry) string { return formatAmount(e.Budget()) }},
+	{"estimatedAmount", func(e *Entry) string {
+		if e.CFS.Project.Budget == nil {
+			return ""
+		}
+		return formatAmount(e.CFS.Project.Budget.EstimatedOverall.Value)
+	}},
+	{"deadline", func(e *Entry) string { return formatDate(e.Deadline()) }},
+	{"procedure", func(e *Entry) string { return e.CFS.Process.ProcedureCode.Value }},
+	{"cpvs", func(e *Entry) string { return strings.Join(e.CPVs(), ",") }},
+	{"nuts", func(e *Entry) string { return e.NUTS() }},
+	{"lots", func(e *Entry) string { return strconv.Itoa(len(e.CFS.Lots)) }},
+}
+
+// DiffVersions compara dos versiones de la misma entry campo a campo.
+func DiffVersions(prev, next *Entry) VersionDiff {
+	d := VersionDiff{From: prev.Updated.Time, To: next.Updated.Time}
+
+	for _, f := range entryDiffFields {
+		if a, b := f.get(prev), f.get(next); a != b {
+			d.Changes = append(d.Changes, FieldChange{Field: f.name, From: a, To: b})
+		}
+	}
+
+	// Los lotes de las dos versiones: uno que desaparece también es un cambio.
+	prevAwards, nextAwards := awardsByLot(prev), awardsByLot(next)
+	lots := next.Awards()
+	for _, p := range prev.Awards() {
+		if _, ok := nextAwards[p.LotID]; !ok {
+			lots = append(lots, Award{LotID: p.LotID})
+		}
+	}
+	for _, a := range lots {
+		p := prevAwards[a.LotID]
+		prefix := ""
+		if a.LotID != "" {
+			prefix = "lot[" + a.LotID + "]."
+		}
+		if p.ResultCode != a.ResultCode {
+			d.Changes = append(d.Changes, FieldChange{Field: prefix + "resultCode", From: p.ResultCode, To: a.ResultCode})
+		}
+		if p.Amount != a.Amount {
+			d.Changes = append(d.Changes, FieldChange{
+				Field: prefix + "awardedAmount", From: formatAmount(p.Amount), To: formatAmount(a.Amount),
+			})
+		}
+		_, withdrawn := withdrawalEvent(a.ResultCode)
+		if a.NIF != "" && p.NIF != a.NIF && !withdrawn {
+			d.NewWinners = append(d.NewWinners, a)
+		}
+		if p.NIF != "" && (p.NIF != a.NIF || withdrawn) {
+			if _, was := withdrawalEvent(p.ResultCode); !was {
+				d.RemovedWinners = append(d.RemovedWinners, p)
+			}
+		}
+	}
+
+	prevDocs := make(map[string]bool)
+	for _, doc := range prev.Documents() {
+		prevDocs[doc.ID] = true
+	}
+	for _, doc := range next.Documents() {
+		if !prevDocs[doc.ID] {
+			d.NewDocs = append(d.NewDocs, doc)
+		}
+	}
+	return d
+}
+
+func (d VersionDiff) Empty() bool {
+	return len(d.Changes) == 0 && len(d.NewDocs) == 0 && len(d.NewWinners) == 0 && len(d.RemovedWinners) == 0
+}
+
+// StatusChange devuelve el cambio de ContractFolderStatusCode, si lo hubo.
+func (d VersionDiff) StatusChange() *FieldChange {
+	for i := range d.Changes {
+		if d.Changes[i].Field == "status" {
+			return &d.Changes[i]
+		}
+	}
+	return nil
+}
+
+func awardsByLot(e *Entry) map[string]Award {
+	out := make(map[string]Award)
+	for _, a := range e.Awards() {
+		out[a.LotID] = a
+	}
+	return out
+}
+
+func formatAmount(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
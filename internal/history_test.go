@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffVersionsAwards(t *testing.T) {
+	const lot = `<TenderResult><ResultCode>%s</ResultCode>%s<AwardedTenderedProject><ProcurementProjectLotID>%s</ProcurementProjectLotID></AwardedTenderedProject></TenderResult>`
+	winner := func(nif string) string {
+		return `<WinningParty><PartyIdentification><ID schemeName="NIF">` + nif + `</ID></PartyIdentification></WinningParty>`
+	}
+	version := func(day int, results ...string) *Entry {
+		e := parseTestEntry(t, strings.Join(results, ""))
+		e.Updated.Time = time.Date(2025, 3, day, 0, 0, 0, 0, time.UTC)
+		return e
+	}
+	v1 := version(1,
+		fmt.Sprintf(lot, ResultAwarded, winner("B11111111"), "1"),
+		fmt.Sprintf(lot, ResultAwarded, winner("B22222222"), "2"),
+		fmt.Sprintf(lot, ResultAwarded, winner("B33333333"), "3"))
+	// El lote 1 sigue igual, el 2 pasa a renuncia, el 3 desaparece y el 4 es nuevo.
+	v2 := version(2,
+		fmt.Sprintf(lot, ResultAwarded, winner("B11111111"), "1"),
+		fmt.Sprintf(lot, ResultRenounced, "", "2"),
+		fmt.Sprintf(lot, ResultAwarded, winner("B44444444"), "4"))
+
+	d := DiffVersions(v1, v2)
+	nifs := func(as []Award) []string {
+		var out []string
+		for _, a := range as {
+			out = append(out, a.LotID+":"+a.NIF)
+		}
+		slices.Sort(out)
+		return out
+	}
+	if got, want := nifs(d.NewWinners), []string{"4:B44444444"}; !slices.Equal(got, want) {
+		t.Errorf("NewWinners = %v, want %v", got, want)
+	}
+	if got, want := nifs(d.RemovedWinners), []string{"2:B22222222", "3:B33333333"}; !slices.Equal(got, want) {
+		t.Errorf("RemovedWinners = %v, want %v", got, want)
+	}
+	var changes []string
+	for _, c := range d.Changes {
+		changes = append(changes, c.Field+"="+c.From+">"+c.To)
+	}
+	for _, want := range []string{"lot[2].resultCode=8>5", "lot[3].resultCode=8>", "lot[4].resultCode=>8"} {
+		if !slices.Contains(changes, want) {
+			t.Errorf("falta el cambio %s en %v", want, changes)
+		}
+	}
+
+	// Sin cambios en las adjudicaciones no se informa de nada.
+	if d := DiffVersions(v2, version(3,
+		fmt.Sprintf(lot, ResultAwarded, winner("B11111111"), "1"),
+		fmt.Sprintf(lot, ResultRenounced, "", "2"),
+		fmt.Sprintf(lot, ResultAwarded, winner("B44444444"), "4"))); !d.Empty() {
+		t.Errorf("versiones iguales: %+v", d)
+	}
+}
+
+// Un ID que no está en el archivo es un error, no un historial vacío.
+func TestExtractContractHistoryUnknownID(t *testing.T) {
+	dir := t.TempDir()
+	feed := `<feed><entry><id>https://contrataciondelestado.es/sindicacion/licitacionesPerfilContratante/123</id><updated>2025-01-02T10:00:00+01:00</updated></entry></feed>`
+	if err := os.WriteFile(filepath.Join(dir, "licitacionesPerfilesContratanteCompleto3_20250102_100000.atom"), []byte(feed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	save := filepath.Join(dir, "versiones.atom")
+	h, err := ExtractContractHistory(dir, "https://contrataciondelestado.es/sindicacion/licitacionesPerfilContratante/999", time.Time{}, save)
+	if err == nil {
+		t.Fatalf("ID desconocido: %d versiones, want error", len(h.Versions))
+	}
+	if _, err := os.Stat(save); err == nil {
+		t.Error("no debería escribir -save sin versiones")
+	}
+}
@@ -0,0 +1,316 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API HTTP de sólo lectura sobre un Catalog (y el índice de búsqueda si lo hay).
+// Todas las respuestas son JSON con el modelo canónico; las rutas se declaran en
+// apiRoutes, que también genera /openapi.json.
+
+const (
+	apiDefaultLimit = 50
+	apiMaxLimit     = 500
+)
+
+type API struct {
+	Catalog *Catalog
+	Index   *Index // nil: /search responde 503
+}
+
+type apiParam struct {
+	Name        string
+	In          string // query o path
+	Type        string // tipo OpenAPI: string, integer, number
+	Description string
+}
+
+type apiRoute struct {
+	Method   string
+	Path     string // sintaxis de ServeMux, que coincide con la de OpenAPI
+	ID       string
+	Summary  string
+	Params   []apiParam
+	Response any // valor del tipo devuelto, para el esquema
+	handle   func(a *API, r *http.Request) (any, error)
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+type apiError struct {
+	status int
+	msg    string
+}
+
+func (e *apiError) Error() string {
+	return e.msg
+}
+
+func badRequest(err error) error {
+	return &apiError{http.StatusBadRequest, err.Error()}
+}
+
+func notFound(what string) error {
+	return &apiError{http.StatusNotFound, what + " no encontrado"}
+}
+
+// EntryVersions es una entry con su historial.
+type EntryVersions struct {
+	CanonicalEntry
+	Versions []Version     `json:"versions"`
+	Diffs    []VersionDiff `json:"diffs"`
+}
+
+type SearchEntry struct {
+	Score float64 `json:"score"`
+	CanonicalEntry
+}
+
+type SearchPage struct {
+	Total int           `json:"total"`
+	Hits  []SearchEntry `json:"hits"`
+}
+
+var entryFilterParams = []apiParam{
+	{"cpv", "query", "string", "prefijos CPV a incluir, separados por comas"},
+	{"exclude", "query", "string", "prefijos CPV a excluir, separados por comas"},
+	{"nuts", "query", "string", "prefijos NUTS, separados por comas"},
+	{"status", "query", "string", "estados (PUB, EV, ADJ, RES…), separados por comas"},
+	{"min", "query", "number", "presupuesto mínimo sin impuestos"},
+	{"max", "query", "number", "presupuesto máximo sin impuestos"},
+	{"where", "query", "string", "expresión de filtro (ver README)"},
+	{"limit", "query", "integer", "resultados por página (50 por defecto, máximo 500)"},
+	{"offset", "query", "integer", "resultados a saltar"},
+}
+
+var apiRoutes = []apiRoute{
+	{
+		Method: "GET", Path: "/entries", ID: "listEntries",
+		Summary:  "Entries en su versión más reciente, las últimas actualizadas primero",
+		Params:   entryFilterParams,
+		Response: EntryPage{},
+		handle:   (*API).entries,
+	},
+	{
+		Method: "GET", Path: "/entries/{id}", ID: "getEntry",
+		Summary:  "Una entry con su historial de versiones",
+		Params:   []apiParam{{"id", "path", "string", "ID completo (codificado) o el número final de la URL si no lo comparten varias entries"}},
+		Response: EntryVersions{},
+		handle:   (*API).entry,
+	},
+	{
+		Method: "GET", Path: "/parties/{nif}", ID: "getParty",
+		Summary:  "Órgano de contratación con sus entries",
+		Params:   append([]apiParam{{"nif", "path", "string", "NIF o código DIR3"}}, entryFilterParams...),
+		Response: PartyProfile{},
+		handle:   (*API).party,
+	},
+	{
+		Method: "GET", Path: "/winners/{nif}", ID: "getWinner",
+		Summary:  "Adjudicatario con sus adjudicaciones",
+		Params:   []apiParam{{"nif", "path", "string", "NIF del adjudicatario"}},
+		Response: WinnerProfile{},
+		handle:   (*API).winner,
+	},
+	{
+		Method: "GET", Path: "/search", ID: "search",
+		Summary:  "Búsqueda de texto completo por relevancia",
+		Params:   append([]apiParam{{"q", "query", "string", `palabras, "frases" y -exclusiones`}}, entryFilterParams...),
+		Response: SearchPage{},
+		handle:   (*API).search,
+	},
+}
+
+// Handler devuelve el http.Handler con todas las rutas y /openapi.json.
+func (a *API) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, rt := range apiRoutes {
+		mux.HandleFunc(rt.Method+" "+rt.Path, func(w http.ResponseWriter, r *http.Request) {
+			v, err := rt.handle(a, r)
+			if err != nil {
+				var ae *apiError
+				if !errors.As(err, &ae) {
+					log.Printf("[HTTP] %s %s: %v", r.Method, r.URL, err)
+					ae = &apiError{http.StatusInternalServerError, "error interno"}
+				}
+				writeAPIJSON(w, ae.status, ErrorResponse{ae.msg})
+				return
+			}
+			writeAPIJSON(w, http.StatusOK, v)
+		})
+	}
+	doc := OpenAPI()
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIJSON(w, http.StatusOK, doc)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIJSON(w, http.StatusNotFound, ErrorResponse{"ruta no encontrada"})
+	})
+	return logRequests(mux)
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func logRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		h.ServeHTTP(w, r)
+		log.Printf("[HTTP] %s %s (%s)", r.Method, r.URL, time.Since(start))
+	})
+}
+
+// ---------- Parámetros ----------
+
+func parseEntryQuery(r *http.Request) (EntryQuery, error) {
+	v := r.URL.Query()
+	q := EntryQuery{
+		Filter: SearchFilter{
+			NUTS:   splitCSV(v.Get("nuts")),
+			Status: splitCSV(v.Get("status")),
+		},
+		Limit: apiDefaultLimit,
+	}
+	var err error
+	if q.Filter.CPV.Include, err = ParseCPVPrefixes(v.Get("cpv")); err != nil {
+		return q, badRequest(err)
+	}
+	if q.Filter.CPV.Exclude, err = ParseCPVPrefixes(v.Get("exclude")); err != nil {
+		return q, badRequest(err)
+	}
+	if q.Filter.MinAmount, err = floatParam(v.Get("min"), "min"); err != nil {
+		return q, err
+	}
+	if q.Filter.MaxAmount, err = floatParam(v.Get("max"), "max"); err != nil {
+		return q, err
+	}
+	if s := v.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit <= 0 || q.Limit > apiMaxLimit {
+			return q, &apiError{http.StatusBadRequest, "limit debe estar entre 1 y " + strconv.Itoa(apiMaxLimit)}
+		}
+	}
+	if s := v.Get("offset"); s != "" {
+		if q.Offset, err = strconv.Atoi(s); err != nil || q.Offset < 0 {
+			return q, &apiError{http.StatusBadRequest, "offset inválido: " + s}
+		}
+	}
+	if q.Where, err = ParseFilterFlag(v.Get("where")); err != nil {
+		return q, badRequest(err)
+	}
+	return q, nil
+}
+
+func floatParam(s, name string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, &apiError{http.StatusBadRequest, name + " no es un número: " + s}
+	}
+	return f, nil
+}
+
+func splitCSV(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// ---------- Handlers ----------
+
+func (a *API) entries(r *http.Request) (any, error) {
+	q, err := parseEntryQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	return a.Catalog.Entries(q), nil
+}
+
+func (a *API) entry(r *http.Request) (any, error) {
+	e, err := a.Catalog.Get(r.PathValue("id"))
+	if err != nil {
+		return nil, badRequest(err)
+	}
+	if e == nil {
+		return nil, notFound("entry")
+	}
+	h, err := a.Catalog.History(e)
+	if err != nil {
+		return nil, err
+	}
+	return EntryVersions{CanonicalEntry: NewCanonicalEntry(e), Versions: h.Versions, Diffs: h.Diffs}, nil
+}
+
+func (a *API) party(r *http.Request) (any, error) {
+	q, err := parseEntryQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	p := a.Catalog.Party(r.PathValue("nif"), q)
+	if p == nil {
+		return nil, notFound("órgano")
+	}
+	return p, nil
+}
+
+func (a *API) winner(r *http.Request) (any, error) {
+	w := a.Catalog.Winner(r.PathValue("nif"))
+	if w == nil {
+		return nil, notFound("adjudicatario")
+	}
+	return w, nil
+}
+
+func (a *API) search(r *http.Request) (any, error) {
+	if a.Index == nil {
+		return nil, &apiError{http.StatusServiceUnavailable, "no hay índice de búsqueda (ver index)"}
+	}
+	eq, err := parseEntryQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	q := SearchQuery{Text: r.URL.Query().Get("q"), Filter: eq.Filter, Where: eq.Where, Limit: eq.Limit, Offset: eq.Offset}
+	if err := q.Validate(); err != nil {
+		return nil, badRequest(err)
+	}
+	res, err := a.Index.Search(q)
+	if err != nil {
+		return nil, err
+	}
+
+	// El índice puede ser de otra carga del archivo: lo que no está en el
+	// catálogo se devuelve con los campos que guarda el índice.
+	out := SearchPage{Total: res.Total, Hits: []SearchEntry{}}
+	for _, h := range res.Hits {
+		hit := SearchEntry{Score: h.Score}
+		if e, _ := a.Catalog.Get(h.ID); e != nil {
+			hit.CanonicalEntry = NewCanonicalEntry(e)
+		} else {
+			hit.CanonicalEntry = CanonicalEntry{
+				ID: h.ID, Title: h.Title, URL: h.URL, Status: h.Status, Updated: h.Updated,
+				Buyer: CanonicalParty{Name: h.Org}, CPVs: h.CPVs, NUTS: h.NUTS, Budget: h.Budget,
+			}
+		}
+		out.Hits = append(out.Hits, hit)
+	}
+	return out, nil
+}
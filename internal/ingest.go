@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// decodeEntries recorre un fichero atom y llama a fn con cada <entry> decodificado.
+func decodeEntries(path string, fn func(Entry)) error {
+	return decodeAtom(path, fn, nil)
+}
+
+// decodeAtom es como decodeEntries pero también entrega los at:deleted-entry.
+func decodeAtom(path string, onEntry func(Entry), onTomb func(Tombstone)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := xml.NewDecoder(bufio.NewReaderSize(f, 256<<10))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch {
+		case se.Name.Local == "entry":
+			var e Entry
+			if err := dec.DecodeElement(&e, &se); err == nil {
+				onEntry(e)
+			}
+		case se.Name.Local == "deleted-entry" && onTomb != nil:
+			var t Tombstone
+			if err := dec.DecodeElement(&t, &se); err == nil {
+				onTomb(t)
+			}
+		}
+	}
+}
+
+// Ingest es el camino de ingesta de una versión: compara con lo guardado,
+// deriva los eventos y actualiza el store.
+func Ingest(ctx context.Context, st Store, e Entry) ([]Event, error) {
+	prev, err := st.Get(ctx, e.ID)
+	if err != nil {
+		return nil, err
+	}
+	events := DeriveEvents(prev, &e)
+	if err := st.Upsert(ctx, e); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// IngestTombstone aplica un at:deleted-entry sobre lo guardado.
+func IngestTombstone(ctx context.Context, st Store, t Tombstone) ([]Event, error) {
+	prev, err := st.Get(ctx, t.Ref)
+	if err != nil {
+		return nil, err
+	}
+	return DeriveTombstoneEvents(prev, t), nil
+}
+
+// IngestAtoms vuelca en el store todas las entries de los atom de dir.
+// El orden entre ficheros no importa: el store descarta versiones antiguas.
+// Con onChange != nil se le llama con cada versión nueva o posterior a la
+// guardada (desde varias goroutines).
+func IngestAtoms(ctx context.Context, dir string, workers int, st Store, onChange func(e *Entry)) error {
+	startTime := time.Now()
+
+	files, err := listLocalAtoms(dir)
+	if err != nil {
+		return err
+	}
+
+	if workers <= 0 {
+		workers = 8
+	}
+	fileCh := make(chan string, workers*2)
+
+	var mu sync.Mutex
+	var count, failed int
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for path := range fileCh {
+				pathStart := time.Now()
+				n, nErr := 0, 0
+				err := decodeEntries(path, func(e Entry) {
+					if ctx.Err() != nil {
+						return
+					}
+					var prev *Entry
+					if onChange != nil {
+						var err error
+						if prev, err = st.Get(ctx, e.ID); err != nil {
+							log.Printf("[STORE] %s %v", e.ID, err)
+							nErr++
+							return
+						}
+					}
+					if err := st.Upsert(ctx, e); err != nil {
+						log.Printf("[STORE] %s %v", e.ID, err)
+						nErr++
+						return
+					}
+					n++
+					if onChange != nil && (prev == nil || e.Updated.After(prev.Updated.Time)) {
+						onChange(&e)
+					}
+				})
+				if err != nil {
+					log.Printf("[XML] %s %v", path, err)
+				}
+
+				mu.Lock()
+				count += n
+				failed += nErr
+				mu.Unlock()
+				log.Printf("Took %s to ingest %s", time.Since(pathStart), path)
+			}
+		}()
+	}
+
+	for _, f := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		fileCh <- f
+	}
+	close(fileCh)
+	wg.Wait()
+
+	log.Printf("[done] Ingested %d entries (%d errors), tiempo: %s",
+		count, failed, time.Since(startTime))
+	return ctx.Err()
+}
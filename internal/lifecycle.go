@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Ciclo de vida de ContractFolderStatusCode (ver drawings/Estados.excalidraw):
+//
+//	PRE → PUB → EV → ADJ → RES
+//
+// ANUL puede llegar desde cualquier estado y es terminal.
+const (
+	StatusPrior     = "PRE"  // anuncio previo
+	StatusPublished = "PUB"  // en plazo
+	StatusEvaluated = "EV"   // pendiente de adjudicación
+	StatusAwarded   = "ADJ"  // adjudicada
+	StatusResolved  = "RES"  // resuelta (formalizada, desierta, desistida…)
+	StatusAnnulled  = "ANUL" // anulada
+)
+
+// TenderResultCode (codice/cl/2.09)
+const (
+	ResultAwardedProvisional  = "1"
+	ResultAwardedDefinitive   = "2"
+	ResultDeserted            = "3"
+	ResultDesisted            = "4"
+	ResultRenounced           = "5"
+	ResultDesertedProvisional = "6"
+	ResultDesertedDefinitive  = "7"
+	ResultAwarded             = "8"
+	ResultFormalized          = "9"
+)
+
+var lifecycleOrder = []string{StatusPrior, StatusPublished, StatusEvaluated, StatusAwarded, StatusResolved}
+
+func lifecycleRank(status string) int {
+	for i, s := range lifecycleOrder {
+		if s == status {
+			return i
+		}
+	}
+	return -1
+}
+
+// KnownStatus indica si el código pertenece al ciclo de vida conocido.
+func KnownStatus(status string) bool {
+	return status == StatusAnnulled || lifecycleRank(status) >= 0
+}
+
+// ValidTransition indica si se puede pasar de from a to. Repetir estado es válido;
+// retroceder o salir de ANUL no.
+func ValidTransition(from, to string) bool {
+	if from == to {
+		return KnownStatus(from)
+	}
+	if from == StatusAnnulled {
+		return false
+	}
+	if to == StatusAnnulled {
+		return KnownStatus(from)
+	}
+	fr, tr := lifecycleRank(from), lifecycleRank(to)
+	return fr >= 0 && tr >= 0 && fr < tr
+}
+
+// Un expediente resuelto sin adjudicatario (desierto, desistido, renuncia) no
+// pasa por ADJ.
+func resolvedWithoutAward(e *Entry) bool {
+	if len(e.CFS.Results) == 0 {
+		return true
+	}
+	for _, a := range e.Awards() {
+		switch a.ResultCode {
+		case ResultDeserted, ResultDesisted, ResultRenounced,
+			ResultDesertedProvisional, ResultDesertedDefinitive:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+type Transition struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+// InferredState es un estado que no hemos visto en ninguna versión pero por el
+// que el expediente tuvo que pasar.
+type InferredState struct {
+	Status string    `json:"status"`
+	Before time.Time `json:"before"` // primera versión vista tras el estado
+}
+
+type LifecycleReport struct {
+	ID       string          `json:"id"`
+	Observed []Transition    `json:"observed"`
+	Invalid  []Transition    `json:"invalid,omitempty"`
+	Inferred []InferredState `json:"inferred,omitempty"`
+	Unknown  []string        `json:"unknown,omitempty"`
+}
+
+func (r *LifecycleReport) OK() bool {
+	return len(r.Invalid) == 0 && len(r.Unknown) == 0
+}
+
+// CheckLifecycle recorre las versiones de h y valida cada cambio de estado.
+func CheckLifecycle(h *History) LifecycleReport {
+	r := LifecycleReport{ID: h.ID}
+	prev := ""
+	unknown := make(map[string]bool)
+
+	for i := range h.Versions {
+		v := &h.Versions[i]
+		cur := v.Status
+		if !KnownStatus(cur) {
+			if !unknown[cur] {
+				unknown[cur] = true
+				r.Unknown = append(r.Unknown, cur)
+			}
+			continue
+		}
+		if cur == prev {
+			continue
+		}
+
+		t := Transition{From: prev, To: cur, At: v.Updated}
+		r.Observed = append(r.Observed, t)
+
+		switch {
+		case prev == "":
+			// Primera versión vista: si no empieza en PRE/PUB, hubo publicación antes.
+			r.Inferred = append(r.Inferred, skippedStates(StatusPublished, cur, &v.Entry, true, v.Updated)...)
+		case !ValidTransition(prev, cur):
+			r.Invalid = append(r.Invalid, t)
+		default:
+			r.Inferred = append(r.Inferred, skippedStates(prev, cur, &v.Entry, false, v.Updated)...)
+		}
+		prev = cur
+	}
+	return r
+}
+
+// skippedStates devuelve los estados entre from y to (from incluido si inclusive).
+func skippedStates(from, to string, e *Entry, inclusive bool, at time.Time) []InferredState {
+	if to == StatusAnnulled {
+		return nil
+	}
+	fr, tr := lifecycleRank(from), lifecycleRank(to)
+	if fr < 0 || tr < 0 {
+		return nil
+	}
+	if !inclusive {
+		fr++
+	}
+	var out []InferredState
+	for i := fr; i < tr; i++ {
+		s := lifecycleOrder[i]
+		if s == StatusAwarded && to == StatusResolved && resolvedWithoutAward(e) {
+			continue
+		}
+		out = append(out, InferredState{Status: s, Before: at})
+	}
+	return out
+}
+
+func (r LifecycleReport) String() string {
+	var b strings.Builder
+	path := make([]string, 0, len(r.Observed))
+	for _, t := range r.Observed {
+		path = append(path, t.To)
+	}
+	fmt.Fprintf(&b, "%s: %s", r.ID, strings.Join(path, " → "))
+	for _, s := range r.Inferred {
+		fmt.Fprintf(&b, "\n    inferido %s (antes de %s)", s.Status, s.Before.Format(time.RFC3339))
+	}
+	for _, t := range r.Invalid {
+		fmt.Fprintf(&b, "\n    transición imposible %s → %s (%s)", t.From, t.To, t.At.Format(time.RFC3339))
+	}
+	for _, s := range r.Unknown {
+		fmt.Fprintf(&b, "\n    estado desconocido %q", s)
+	}
+	return b.String()
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore es un Store en memoria para replays y pruebas locales.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	latest   map[string]Entry
+	versions map[string][]Entry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		latest:   make(map[string]Entry),
+		versions: make(map[string][]Entry),
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id string) (*Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.latest[id]
+	if !ok {
+		return nil, nil
+	}
+	return &e, nil
+}
+
+func (s *MemoryStore) Upsert(ctx context.Context, e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versions[e.ID] = append(s.versions[e.ID], e)
+	if cur, ok := s.latest[e.ID]; ok && !e.Updated.After(cur.Updated.Time) {
+		return nil
+	}
+	s.latest[e.ID] = e
+	return nil
+}
+
+func (s *MemoryStore) History(ctx context.Context, id string) (*History, error) {
+	s.mu.RLock()
+	vs := append([]Entry(nil), s.versions[id]...)
+	s.mu.RUnlock()
+	if len(vs) == 0 {
+		return nil, nil
+	}
+	return NewHistory(id, vs), nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
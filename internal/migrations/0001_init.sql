@@ -0,0 +1,65 @@
+-- Esquema inicial: órganos, expedientes y lotes.
+CREATE TABLE parties (
+    nif          TEXT PRIMARY KEY,
+    dir3         TEXT NOT NULL DEFAULT '',
+    name         TEXT NOT NULL DEFAULT '',
+    profile_url  TEXT NOT NULL DEFAULT '',
+    email        TEXT NOT NULL DEFAULT '',
+    phone        TEXT NOT NULL DEFAULT '',
+    address      TEXT NOT NULL DEFAULT '',
+    postal_code  TEXT NOT NULL DEFAULT '',
+    city         TEXT NOT NULL DEFAULT '',
+    country      TEXT NOT NULL DEFAULT '',
+    updated      TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE entries (
+    id              TEXT PRIMARY KEY,
+    folder_id       TEXT NOT NULL DEFAULT '',
+    title           TEXT NOT NULL DEFAULT '',
+    summary         TEXT NOT NULL DEFAULT '',
+    url             TEXT NOT NULL DEFAULT '',
+    status          TEXT NOT NULL DEFAULT '',
+    updated         TIMESTAMPTZ NOT NULL,
+    published       DATE,
+    party_nif       TEXT NOT NULL DEFAULT '',
+    party_name      TEXT NOT NULL DEFAULT '',
+    type_code       TEXT NOT NULL DEFAULT '',
+    procedure_code  TEXT NOT NULL DEFAULT '',
+    cpvs            TEXT[] NOT NULL DEFAULT '{}',
+    nuts            TEXT NOT NULL DEFAULT '',
+    budget          NUMERIC,
+    deadline        DATE,
+    result_code     TEXT NOT NULL DEFAULT '',
+    winner_nif      TEXT NOT NULL DEFAULT '',
+    winner_name     TEXT NOT NULL DEFAULT '',
+    awarded_amount  NUMERIC,
+    award_date      DATE,
+    raw             JSONB NOT NULL
+);
+
+CREATE INDEX entries_cpvs_idx ON entries USING GIN (cpvs);
+CREATE INDEX entries_nuts_idx ON entries (nuts text_pattern_ops);
+CREATE INDEX entries_status_idx ON entries (status);
+CREATE INDEX entries_deadline_idx ON entries (deadline);
+CREATE INDEX entries_party_nif_idx ON entries (party_nif);
+
+CREATE TABLE lots (
+    entry_id        TEXT NOT NULL REFERENCES entries (id) ON DELETE CASCADE,
+    lot_id          TEXT NOT NULL,
+    name            TEXT NOT NULL DEFAULT '',
+    cpvs            TEXT[] NOT NULL DEFAULT '{}',
+    nuts            TEXT NOT NULL DEFAULT '',
+    budget          NUMERIC,
+    result_code     TEXT NOT NULL DEFAULT '',
+    winner_nif      TEXT NOT NULL DEFAULT '',
+    winner_name     TEXT NOT NULL DEFAULT '',
+    awarded_amount  NUMERIC,
+    award_date      DATE,
+    raw             JSONB NOT NULL,
+    PRIMARY KEY (entry_id, lot_id)
+);
+
+CREATE INDEX lots_cpvs_idx ON lots USING GIN (cpvs);
+CREATE INDEX lots_nuts_idx ON lots (nuts text_pattern_ops);
+CREATE INDEX lots_winner_nif_idx ON lots (winner_nif);
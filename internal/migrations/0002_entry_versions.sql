@@ -0,0 +1,8 @@
+-- Todas las versiones publicadas de cada entry, no sólo la última.
+CREATE TABLE entry_versions (
+    entry_id  TEXT NOT NULL,
+    updated   TIMESTAMPTZ NOT NULL,
+    status    TEXT NOT NULL DEFAULT '',
+    raw       JSONB NOT NULL,
+    PRIMARY KEY (entry_id, updated)
+);
@@ -0,0 +1,275 @@
+package internal
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Red comprador–adjudicatario: grafo bipartito con los organismos
+// (LocatedParty) a un lado y los adjudicatarios (WinningParty) al otro. Cada
+// arista acumula las adjudicaciones entre ambos.
+
+const (
+	NodeBuyer    = "buyer"
+	NodeSupplier = "supplier"
+)
+
+type NetworkNode struct {
+	ID     string  `json:"id"`
+	Label  string  `json:"label"`
+	Kind   string  `json:"kind"`
+	Awards int     `json:"awards"`
+	Amount float64 `json:"amount"`
+}
+
+type NetworkEdge struct {
+	Source string  `json:"source"` // comprador
+	Target string  `json:"target"` // adjudicatario
+	Count  int     `json:"count"`
+	Amount float64 `json:"amount"`
+}
+
+type Network struct {
+	Nodes []NetworkNode `json:"nodes"`
+	Edges []NetworkEdge `json:"edges"`
+}
+
+func supplierNodeID(nif string) string {
+	return "SUP:" + nif
+}
+
+// BuildNetwork agrega las adjudicaciones en nodos y aristas, en orden estable.
+func BuildNetwork(records []AwardRecord) *Network {
+	nodes := make(map[string]*NetworkNode)
+	edges := make(map[[2]string]*NetworkEdge)
+
+	node := func(id, label, kind string) *NetworkNode {
+		n, ok := nodes[id]
+		if !ok {
+			n = &NetworkNode{ID: id, Label: label, Kind: kind}
+			nodes[id] = n
+		}
+		if n.Label == "" {
+			n.Label = label
+		}
+		return n
+	}
+
+	for i := range records {
+		r := &records[i]
+		amount := r.Amount()
+		b := node(r.BuyerID, r.BuyerName, NodeBuyer)
+		b.Awards++
+		b.Amount += amount
+		// Cada empresa de una UTE tiene su arista con el importe entero.
+		for _, p := range r.Winners {
+			s := node(supplierNodeID(p.NIF), p.Name, NodeSupplier)
+			s.Awards++
+			s.Amount += amount
+
+			k := [2]string{b.ID, s.ID}
+			e, ok := edges[k]
+			if !ok {
+				e = &NetworkEdge{Source: b.ID, Target: s.ID}
+				edges[k] = e
+			}
+			e.Count++
+			e.Amount += amount
+		}
+	}
+
+	net := &Network{}
+	for _, n := range nodes {
+		net.Nodes = append(net.Nodes, *n)
+	}
+	sort.Slice(net.Nodes, func(i, j int) bool {
+		if net.Nodes[i].Kind != net.Nodes[j].Kind {
+			return net.Nodes[i].Kind < net.Nodes[j].Kind
+		}
+		return net.Nodes[i].ID < net.Nodes[j].ID
+	})
+	for _, e := range edges {
+		net.Edges = append(net.Edges, *e)
+	}
+	sort.Slice(net.Edges, func(i, j int) bool {
+		if net.Edges[i].Source != net.Edges[j].Source {
+			return net.Edges[i].Source < net.Edges[j].Source
+		}
+		return net.Edges[i].Target < net.Edges[j].Target
+	})
+	return net
+}
+
+// EdgeWeight elige el peso de las aristas: "count" o "amount".
+func EdgeWeight(e NetworkEdge, by string) float64 {
+	if by == "amount" {
+		return e.Amount
+	}
+	return float64(e.Count)
+}
+
+// ---------- GraphML ----------
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+// WriteGraphML escribe la red en GraphML (Gephi, yEd, Cytoscape…).
+func WriteGraphML(w io.Writer, net *Network, weightBy string) error {
+	g := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{"label", "node", "label", "string"},
+			{"kind", "node", "kind", "string"},
+			{"nawards", "node", "awards", "int"},
+			{"namount", "node", "amount", "double"},
+			{"count", "edge", "count", "int"},
+			{"amount", "edge", "amount", "double"},
+			{"weight", "edge", "weight", "double"},
+		},
+		Graph: graphMLGraph{EdgeDefault: "undirected"},
+	}
+	for _, n := range net.Nodes {
+		g.Graph.Nodes = append(g.Graph.Nodes, graphMLNode{ID: n.ID, Data: []graphMLData{
+			{"label", n.Label},
+			{"kind", n.Kind},
+			{"nawards", strconv.Itoa(n.Awards)},
+			{"namount", fixed2(n.Amount)},
+		}})
+	}
+	for _, e := range net.Edges {
+		g.Graph.Edges = append(g.Graph.Edges, graphMLEdge{Source: e.Source, Target: e.Target, Data: []graphMLData{
+			{"count", strconv.Itoa(e.Count)},
+			{"amount", fixed2(e.Amount)},
+			{"weight", strconv.FormatFloat(EdgeWeight(e, weightBy), 'f', -1, 64)},
+		}})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(g); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ---------- DOT ----------
+
+// WriteDOT escribe la red para Graphviz. El grosor de cada arista es
+// proporcional a su peso (1 a 8).
+func WriteDOT(w io.Writer, net *Network, weightBy string) error {
+	maxW := 0.0
+	for _, e := range net.Edges {
+		maxW = math.Max(maxW, EdgeWeight(e, weightBy))
+	}
+
+	var b strings.Builder
+	b.WriteString("graph licitaciones {\n")
+	b.WriteString("  graph [overlap=false, splines=true];\n")
+	for _, n := range net.Nodes {
+		shape := "ellipse"
+		if n.Kind == NodeBuyer {
+			shape = "box"
+		}
+		fmt.Fprintf(&b, "  %s [label=%s, shape=%s, awards=%d];\n", dotID(n.ID), dotID(n.Label), shape, n.Awards)
+	}
+	for _, e := range net.Edges {
+		wgt := EdgeWeight(e, weightBy)
+		pen := 1.0
+		if maxW > 0 {
+			pen = 1 + 7*wgt/maxW
+		}
+		fmt.Fprintf(&b, "  %s -- %s [weight=%s, penwidth=%.2f, label=%s];\n",
+			dotID(e.Source), dotID(e.Target), strconv.FormatFloat(wgt, 'f', -1, 64), pen, dotID(edgeLabel(e)))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func edgeLabel(e NetworkEdge) string {
+	return strconv.Itoa(e.Count) + " / " + fixed2(e.Amount)
+}
+
+func dotID(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ").Replace(s) + `"`
+}
+
+// ---------- CSV ----------
+
+// WriteEdgeListCSV escribe una arista por fila con las etiquetas de sus nodos.
+func WriteEdgeListCSV(w io.Writer, net *Network, weightBy string) error {
+	labels := make(map[string]string, len(net.Nodes))
+	for _, n := range net.Nodes {
+		labels[n.ID] = n.Label
+	}
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"source", "target", "source_label", "target_label", "count", "amount", "weight"})
+	for _, e := range net.Edges {
+		_ = cw.Write([]string{
+			e.Source, e.Target, labels[e.Source], labels[e.Target],
+			strconv.Itoa(e.Count), fixed2(e.Amount),
+			strconv.FormatFloat(EdgeWeight(e, weightBy), 'f', -1, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteNetwork escribe la red en path con el formato graphml, dot o csv.
+func WriteNetwork(path, format string, net *Network, weightBy string) error {
+	var fn func(io.Writer, *Network, string) error
+	switch format {
+	case "graphml":
+		fn = WriteGraphML
+	case "dot":
+		fn = WriteDOT
+	case "csv":
+		fn = WriteEdgeListCSV
+	default:
+		return fmt.Errorf("formato desconocido %q (graphml, dot, csv)", format)
+	}
+	return writeFileAtomic(path, func(w io.Writer) error { return fn(w, net, weightBy) })
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Notifier entrega los eventos derivados de una ingesta. Es el equivalente Go de
+// cron_job/src/domain/Notifier.ts.
+type Notifier interface {
+	Notify(ctx context.Context, events []Event) error
+}
+
+// Mismas etiquetas que EVENT_LABELS en cron_job/src/infra/EmailNotifier.ts.
+var eventLabels = map[EventType]string{
+	EventCreated:                  "Nueva licitación",
+	EventFinishedSubmissionPeriod: "Fin de plazo de presentación",
+	EventLotAwarded:               "Adjudicación de lote",
+	EventAwarded:                  "Adjudicación del expediente",
+	EventResolved:                 "Licitación resuelta",
+	EventNewDocument:              "Nuevo documento",
+	EventDeadlineChanged:          "Cambio de plazo",
+	EventAmountChanged:            "Cambio de importe",
+	EventAnnulled:                 "Licitación anulada",
+	EventDesisted:                 "Desistimiento",
+	EventRenounced:                "Renuncia",
+	EventSearchMatch:              "Búsqueda guardada",
+}
+
+// EventLabel devuelve el nombre legible de un tipo de evento.
+func EventLabel(t EventType) string {
+	if l, ok := eventLabels[t]; ok {
+		return l
+	}
+	return string(t)
+}
+
+// MultiNotifier reparte los mismos eventos a varios notificadores. Un fallo no
+// impide intentar los demás.
+type MultiNotifier []Notifier
+
+func (m MultiNotifier) Notify(ctx context.Context, events []Event) error {
+	var errs []string
+	for _, n := range m {
+		if err := n.Notify(ctx, events); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("notify: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// EventFilter pasa a Next sólo los eventos de Types (todos si está vacío). Si
+// no queda ninguno no llama a Next.
+type EventFilter struct {
+	Next  Notifier
+	Types []EventType
+}
+
+func (f EventFilter) Notify(ctx context.Context, events []Event) error {
+	if len(f.Types) == 0 {
+		return f.Next.Notify(ctx, events)
+	}
+	var keep []Event
+	for _, ev := range events {
+		if slices.Contains(f.Types, ev.Type) {
+			keep = append(keep, ev)
+		}
+	}
+	if len(keep) == 0 {
+		return nil
+	}
+	return f.Next.Notify(ctx, keep)
+}
+
+// eventsText es el cuerpo en texto plano de una lista de eventos.
+func eventsText(events []Event) string {
+	var b strings.Builder
+	for _, ev := range events {
+		fmt.Fprintf(&b, "- %s: %s", EventLabel(ev.Type), strings.TrimSpace(ev.Title))
+		if ev.LotID != "" && ev.LotID != "0" {
+			fmt.Fprintf(&b, " (lote %s)", ev.LotID)
+		}
+		b.WriteString("\n")
+		if ev.OrgName != "" {
+			fmt.Fprintf(&b, "  %s\n", ev.OrgName)
+		}
+		if ev.From != "" || ev.To != "" {
+			fmt.Fprintf(&b, "  %s → %s\n", ev.From, ev.To)
+		}
+		if ev.Award != nil && ev.Award.NIF != "" {
+			fmt.Fprintf(&b, "  Adjudicatario: %s %s %s\n", ev.Award.NIF, ev.Award.Name, formatAmount(ev.Award.Amount))
+		}
+		if ev.Document != nil {
+			fmt.Fprintf(&b, "  Documento: %s %s\n", ev.Document.Name, ev.Document.URL)
+		}
+		if ev.URL != "" {
+			fmt.Fprintf(&b, "  %s\n", ev.URL)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,190 @@
+package internal
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+)
+
+// Nomenclatura NUTS 2021 embebida (data/nuts2021.tsv) y tabla de provincias
+// (data/provincias.tsv) para situar entries y partes en provincia y comunidad
+// autónoma. De España están todos los niveles; del resto de países sólo el
+// nivel 0, que es lo que aparece en la plataforma para licitadores extranjeros.
+//
+// En España NUTS 2 es la comunidad autónoma y NUTS 3 la provincia, salvo en
+// Baleares y Canarias, donde NUTS 3 es la isla (o grupo de islas): por eso la
+// provincia no sale del nombre NUTS sino de la tabla de provincias.
+
+//go:embed data/nuts2021.tsv
+var nutsData string
+
+//go:embed data/provincias.tsv
+var provinceData string
+
+// Province es una provincia: el código INE coincide con las dos primeras
+// cifras del código postal.
+type Province struct {
+	Code string   `json:"code"`
+	Name string   `json:"name"`
+	NUTS []string `json:"nuts"` // NUTS 3 que la forman; el primero es el de defecto
+
+	byPostal map[string]string // tres primeras cifras del CP -> NUTS 3 (islas)
+}
+
+var (
+	nutsNames      map[string]string
+	provinces      map[string]*Province // por código INE
+	provinceByNUTS map[string]*Province // por NUTS 3
+)
+
+func init() {
+	nutsNames = make(map[string]string)
+	forEachDataLine("data/nuts2021.tsv", nutsData, 2, func(f []string) {
+		nutsNames[f[0]] = f[1]
+	})
+	provinces = make(map[string]*Province)
+	provinceByNUTS = make(map[string]*Province)
+	forEachDataLine("data/provincias.tsv", provinceData, 4, func(f []string) {
+		p := &Province{Code: f[0], Name: f[1], NUTS: []string{f[2]}, byPostal: make(map[string]string)}
+		for _, ex := range strings.Fields(f[3]) {
+			prefix, code, _ := strings.Cut(ex, "=")
+			p.byPostal[prefix] = code
+			if !strings.HasPrefix(prefix, p.Code) || nutsNames[code] == "" {
+				panic(fmt.Sprintf("data/provincias.tsv: excepción %q de la provincia %s mal formada", ex, p.Code))
+			}
+			p.NUTS = append(p.NUTS, code)
+		}
+		for _, code := range p.NUTS {
+			provinceByNUTS[code] = p
+		}
+		provinces[p.Code] = p
+	})
+}
+
+// forEachDataLine recorre un fichero de datos embebido: una fila por línea,
+// campos separados por tabuladores y comentarios con "#".
+func forEachDataLine(name, data string, fields int, fn func([]string)) {
+	for i, line := range strings.Split(data, "\n") {
+		if line = strings.TrimRight(line, "\r"); line == "" || line[0] == '#' {
+			continue
+		}
+		f := strings.Split(line, "\t")
+		if len(f) != fields {
+			panic(fmt.Sprintf("%s:%d: se esperaban %d campos", name, i+1, fields))
+		}
+		fn(f)
+	}
+}
+
+// normalizeNUTS pasa a mayúsculas y quita espacios; la plataforma a veces
+// manda "es523".
+func normalizeNUTS(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// NUTSName devuelve el nombre de code, o "" si no está en la nomenclatura.
+func NUTSName(code string) string {
+	return nutsNames[normalizeNUTS(code)]
+}
+
+// NUTSLevel devuelve el nivel de code: 0 país, 1, 2 o 3.
+func NUTSLevel(code string) int {
+	return max(len(normalizeNUTS(code))-2, 0)
+}
+
+// ProvinceByPostalCode devuelve la provincia de un código postal español y el
+// NUTS 3 que le corresponde, que en las islas depende de las tres primeras
+// cifras. nil si el código no tiene cinco cifras o la provincia no existe.
+func ProvinceByPostalCode(cp string) (*Province, string) {
+	cp = strings.TrimSpace(cp)
+	if len(cp) != 5 || !isDigits(cp) {
+		return nil, ""
+	}
+	p := provinces[cp[:2]]
+	if p == nil {
+		return nil, ""
+	}
+	if code, ok := p.byPostal[cp[:3]]; ok {
+		return p, code
+	}
+	return p, p.NUTS[0]
+}
+
+// Geo es la localización de una entry o de una parte a nivel de provincia y
+// comunidad autónoma.
+type Geo struct {
+	NUTS       string `json:"nuts"` // el más preciso conocido
+	NUTSName   string `json:"nutsName,omitempty"`
+	Province   string `json:"province,omitempty"`
+	Region     string `json:"region,omitempty"` // comunidad autónoma (NUTS 2)
+	RegionNUTS string `json:"regionNuts,omitempty"`
+	Country    string `json:"country,omitempty"`
+	Source     string `json:"source"` // "nuts" o "postal"
+}
+
+// GeolocateNUTS sitúa un código NUTS. Con un NUTS 1 o un país sólo se rellena
+// lo que se sabe; nil si el código no está en la nomenclatura.
+func GeolocateNUTS(code string) *Geo {
+	code = normalizeNUTS(code)
+	name := nutsNames[code]
+	if name == "" {
+		return nil
+	}
+	g := &Geo{NUTS: code, NUTSName: name, Country: code[:2], Source: "nuts"}
+	if len(code) >= 4 {
+		g.RegionNUTS = code[:4]
+		g.Region = nutsNames[g.RegionNUTS]
+	}
+	if p := provinceByNUTS[code]; p != nil {
+		g.Province = p.Name
+	}
+	return g
+}
+
+// GeolocatePostal sitúa una dirección por su código postal. Sólo vale para
+// España (country "ES" o vacío); nil si no se reconoce.
+func GeolocatePostal(cp, country string) *Geo {
+	if c := normalizeNUTS(country); c != "" && c != "ES" {
+		return nil
+	}
+	_, code := ProvinceByPostalCode(cp)
+	if code == "" {
+		return nil
+	}
+	g := GeolocateNUTS(code)
+	g.Source = "postal"
+	return g
+}
+
+// addressGeo sitúa una dirección de la plataforma por su código postal.
+func addressGeo(a *Address) *Geo {
+	if a == nil {
+		return nil
+	}
+	return GeolocatePostal(a.PostalZone, a.Country.Code.Value)
+}
+
+// Geo sitúa la entry: el NUTS del lugar de ejecución si llega al menos a la
+// comunidad autónoma y, si no, el código postal del órgano. nil si no hay
+// ninguno de los dos.
+func (e *Entry) Geo() *Geo {
+	byNUTS := GeolocateNUTS(e.NUTS())
+	if byNUTS != nil && byNUTS.Region != "" {
+		return byNUTS
+	}
+	if g := e.BuyerGeo(); g != nil {
+		return g
+	}
+	return byNUTS
+}
+
+// BuyerGeo sitúa el órgano de contratación por su código postal.
+func (e *Entry) BuyerGeo() *Geo {
+	return addressGeo(e.CFS.LocatedParty.Party.PostalAddress)
+}
+
+// Geo sitúa al adjudicatario por el NUTS de su domicilio, que es lo único que
+// publica la plataforma de él.
+func (w *WinningParty) Geo() *Geo {
+	return GeolocateNUTS(w.PhysicalLoc.NUTS.Value)
+}
@@ -0,0 +1,80 @@
+package internal
+
+import "testing"
+
+func TestProvinceByPostalCode(t *testing.T) {
+	cases := []struct {
+		cp, province, nuts string
+	}{
+		{"46701", "Valencia/València", "ES523"},
+		{"01001", "Araba/Álava", "ES211"},
+		{"07001", "Illes Balears", "ES532"},
+		{"07760", "Illes Balears", "ES533"},
+		{"07800", "Illes Balears", "ES531"},
+		{"35500", "Las Palmas", "ES708"},
+		{"35600", "Las Palmas", "ES704"},
+		{"35001", "Las Palmas", "ES705"},
+		{"38700", "Santa Cruz de Tenerife", "ES707"},
+		{"38900", "Santa Cruz de Tenerife", "ES703"},
+		{"52001", "Melilla", "ES640"},
+		{"53001", "", ""},
+		{"4670", "", ""},
+		{"4670A", "", ""},
+	}
+	for _, c := range cases {
+		p, nuts := ProvinceByPostalCode(c.cp)
+		var name string
+		if p != nil {
+			name = p.Name
+		}
+		if name != c.province || nuts != c.nuts {
+			t.Errorf("ProvinceByPostalCode(%q) = %q, %q; want %q, %q", c.cp, name, nuts, c.province, c.nuts)
+		}
+	}
+}
+
+func TestGeolocateNUTS(t *testing.T) {
+	g := GeolocateNUTS(" es705 ")
+	if g == nil || g.NUTSName != "Gran Canaria" || g.Province != "Las Palmas" || g.Region != "Canarias" || g.RegionNUTS != "ES70" {
+		t.Fatalf("ES705 = %+v", g)
+	}
+	if g := GeolocateNUTS("ES5"); g == nil || g.NUTSName != "Este" || g.Region != "" || g.Province != "" {
+		t.Errorf("ES5 = %+v", g)
+	}
+	if g := GeolocateNUTS("FR"); g == nil || g.Country != "FR" || g.Region != "" {
+		t.Errorf("FR = %+v", g)
+	}
+	if g := GeolocateNUTS("ES999"); g != nil {
+		t.Errorf("ES999 = %+v, want nil", g)
+	}
+	// Todas las provincias tienen un NUTS 3 de la nomenclatura.
+	for code, p := range provinces {
+		for _, n := range p.NUTS {
+			if NUTSLevel(n) != 3 || NUTSName(n) == "" {
+				t.Errorf("provincia %s: NUTS %q", code, n)
+			}
+		}
+	}
+	if len(provinces) != 52 {
+		t.Errorf("%d provincias, want 52", len(provinces))
+	}
+}
+
+func TestEntryGeo(t *testing.T) {
+	e := &Entry{}
+	e.CFS.Project.Location = &RealizedLoc{}
+	e.CFS.Project.Location.NUTS.Value = "ES"
+	e.CFS.LocatedParty.Party.PostalAddress = &Address{PostalZone: "28001"}
+	if g := e.Geo(); g == nil || g.Source != "postal" || g.Region != "Comunidad de Madrid" {
+		t.Errorf("NUTS de país: Geo() = %+v, want el del código postal", g)
+	}
+	e.CFS.Project.Location.NUTS.Value = "ES618"
+	if g := e.Geo(); g == nil || g.Source != "nuts" || g.Province != "Sevilla" || g.Region != "Andalucía" {
+		t.Errorf("NUTS 3: Geo() = %+v", g)
+	}
+	e.CFS.LocatedParty.Party.PostalAddress.Country.Code.Value = "PT"
+	e.CFS.Project.Location = nil
+	if g := e.Geo(); g != nil {
+		t.Errorf("dirección extranjera: Geo() = %+v, want nil", g)
+	}
+}
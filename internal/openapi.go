@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Documento OpenAPI 3.0 generado a partir de la tabla de rutas de la API: los
+// parámetros salen de apiRoute y los esquemas de reflejar los tipos de respuesta
+// por sus etiquetas json, así que no hay un fichero aparte que mantener.
+
+type openAPISchemas struct {
+	defs map[string]any
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema devuelve el esquema de t; los structs con nombre van a components y se
+// referencian con $ref.
+func (s *openAPISchemas) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.String:
+		return map[string]any{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]any{"type": "number"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return map[string]any{"type": "array", "items": s.schema(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case t.Kind() != reflect.Struct:
+		return map[string]any{}
+	}
+
+	ref := map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	if t.Name() != "" {
+		if _, ok := s.defs[t.Name()]; ok {
+			return ref
+		}
+		s.defs[t.Name()] = nil // reservado, por si el tipo es recursivo
+	}
+	props := make(map[string]any)
+	var required []string
+	s.fields(t, props, &required)
+	obj := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	if t.Name() == "" {
+		return obj
+	}
+	s.defs[t.Name()] = obj
+	return ref
+}
+
+// fields añade las propiedades de t, aplanando los structs embebidos como hace
+// encoding/json.
+func (s *openAPISchemas) fields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			s.fields(f.Type, props, required)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = s.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// OpenAPI devuelve el documento de las rutas de la API.
+func OpenAPI() map[string]any {
+	s := &openAPISchemas{defs: make(map[string]any)}
+	errRef := s.schema(reflect.TypeOf(ErrorResponse{}))
+	paths := make(map[string]any)
+	for _, rt := range apiRoutes {
+		var params []any
+		for _, p := range rt.Params {
+			params = append(params, map[string]any{
+				"name":        p.Name,
+				"in":          p.In,
+				"required":    p.In == "path",
+				"description": p.Description,
+				"schema":      map[string]any{"type": p.Type},
+			})
+		}
+		op := map[string]any{
+			"summary":     rt.Summary,
+			"operationId": rt.ID,
+			"responses": map[string]any{
+				"200": jsonResponse("OK", s.schema(reflect.TypeOf(rt.Response))),
+				"400": jsonResponse("Parámetros inválidos", errRef),
+				"404": jsonResponse("No encontrado", errRef),
+			},
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		item, _ := paths[rt.Path].(map[string]any)
+		if item == nil {
+			item = make(map[string]any)
+			paths[rt.Path] = item
+		}
+		item[strings.ToLower(rt.Method)] = op
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "licitaciones",
+			"version":     "1",
+			"description": "API de sólo lectura sobre el archivo de la Plataforma de Contratación del Sector Público.",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": s.defs},
+	}
+}
+
+func jsonResponse(desc string, schema map[string]any) map[string]any {
+	return map[string]any{
+		"description": desc,
+		"content":     map[string]any{"application/json": map[string]any{"schema": schema}},
+	}
+}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -76,6 +77,19 @@ func (d *DateYMD) UnmarshalText(b []byte) error {
 	return nil
 }
 
+// En JSON se guarda el valor original para no perder Valid/Raw al releerlo.
+func (d DateYMD) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Raw)
+}
+
+func (d *DateYMD) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	return d.UnmarshalText([]byte(s))
+}
+
 // ===== Modelos de Feed y Tombstones =====
 
 type Feed struct {
@@ -224,14 +238,16 @@ type ContractState struct {
 	StatusCode       Code             `xml:"ContractFolderStatusCode"` // estado (p.ej. ENP, ADJ…)
 	LocatedParty     LocatedParty     `xml:"LocatedContractingParty"`  // órgano
 	Project          ProcurementProj  `xml:"ProcurementProject"`       // CPV, importes, NUTS…
-	Result           *TenderResult    `xml:"TenderResult"`             // adjudicación (si existe)
+	Lots             []ProjectLot     `xml:"ProcurementProjectLot"`    // lotes (si los hay)
+	Results          []TenderResult   `xml:"TenderResult"`             // adjudicación, uno por lote
 	Terms            *TenderingTerms  `xml:"TenderingTerms"`           // idioma, criterios, etc.
 	Process          TenderingProcess `xml:"TenderingProcess"`         // procedimiento, plazo ofertas
 	// Documentación y anuncios
-	LegalDocs     []DocRef        `xml:"LegalDocumentReference"`     // PCAP, etc.
-	TechnicalDocs []DocRef        `xml:"TechnicalDocumentReference"` // PPT, etc.
-	Notices       []ValidNotice   `xml:"ValidNoticeInfo"`
-	GeneralDocs   []GeneralDocRef `xml:"GeneralDocument"`
+	LegalDocs      []DocRef        `xml:"LegalDocumentReference"`      // PCAP, etc.
+	TechnicalDocs  []DocRef        `xml:"TechnicalDocumentReference"`  // PPT, etc.
+	AdditionalDocs []DocRef        `xml:"AdditionalDocumentReference"` // memorias, acuerdos…
+	Notices        []ValidNotice   `xml:"ValidNoticeInfo"`
+	GeneralDocs    []GeneralDocRef `xml:"GeneralDocument"`
 }
 
 // Órgano de contratación y contacto
@@ -290,6 +306,12 @@ type ProcurementProj struct {
 	Extension   *ContractExtension `xml:"ContractExtension"`
 }
 
+// Lote: mismo bloque ProcurementProject que el expediente, con su ID_LOTE
+type ProjectLot struct {
+	ID      Code            `xml:"ID"`
+	Project ProcurementProj `xml:"ProcurementProject"`
+}
+
 type Budget struct {
 	EstimatedOverall Amount `xml:"EstimatedOverallContractAmount"`
 	TotalAmount      Amount `xml:"TotalAmount"`
@@ -315,6 +337,9 @@ type PlannedPeriod struct {
 		Value    int    `xml:",chardata"`
 		UnitCode string `xml:"unitCode,attr"` // DAY|MON|ANN
 	} `xml:"DurationMeasure"`
+	// En vez de (o además de) la duración pueden venir las fechas
+	StartDate DateYMD `xml:"StartDate"`
+	EndDate   DateYMD `xml:"EndDate"`
 }
 
 type ContractExtension struct {
@@ -334,10 +359,14 @@ type ContractExtension struct {
 
 // Adjudicación
 type TenderResult struct {
-	ResultCode Code           `xml:"ResultCode"`
-	AwardDate  DateYMD        `xml:"AwardDate"`
-	Winning    []WinningParty `xml:"WinningParty"`
-	Awarded    *AwardedProj   `xml:"AwardedTenderedProject"`
+	ResultCode     Code           `xml:"ResultCode"`
+	Description    string         `xml:"Description"`
+	AwardDate      DateYMD        `xml:"AwardDate"`
+	ReceivedTender int            `xml:"ReceivedTenderQuantity"`
+	LowerTender    Amount         `xml:"LowerTenderAmount"`
+	HigherTender   Amount         `xml:"HigherTenderAmount"`
+	Winning        []WinningParty `xml:"WinningParty"`
+	Awarded        *AwardedProj   `xml:"AwardedTenderedProject"`
 }
 
 type WinningParty struct {
@@ -351,6 +380,7 @@ type WinningParty struct {
 }
 
 type AwardedProj struct {
+	LotID              string `xml:"ProcurementProjectLotID"`
 	LegalMonetaryTotal struct {
 		TaxExclusive Amount `xml:"TaxExclusiveAmount"`
 		Payable      Amount `xml:"PayableAmount"`
@@ -394,12 +424,16 @@ type GeneralDocRef struct {
 	Ref DocRef `xml:"GeneralDocumentDocumentReference"`
 }
 
+// Un anuncio se publica en varios medios (perfil, BOE, DOUE…) y cada medio
+// puede traer varias referencias: ambos se repiten.
 type ValidNotice struct {
-	NoticeType Code `xml:"NoticeTypeCode"`
-	Status     struct {
-		MediaName string `xml:"PublicationMediaName"`
-		DocRef    struct {
-			IssueDate DateYMD `xml:"IssueDate"`
-		} `xml:"AdditionalPublicationDocumentReference"`
-	} `xml:"AdditionalPublicationStatus"`
+	NoticeType Code                `xml:"NoticeTypeCode"`
+	Status     []PublicationStatus `xml:"AdditionalPublicationStatus"`
+}
+
+type PublicationStatus struct {
+	MediaName string `xml:"PublicationMediaName"`
+	DocRefs   []struct {
+		IssueDate DateYMD `xml:"IssueDate"`
+	} `xml:"AdditionalPublicationDocumentReference"`
 }
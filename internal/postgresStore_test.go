@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// Test de integración: necesita una base de datos desechable en PG_DSN.
+func testPostgresStore(t *testing.T) (*PostgresStore, context.Context) {
+	t.Helper()
+	dsn := os.Getenv("PG_DSN")
+	if dsn == "" {
+		t.Skip("PG_DSN no definida")
+	}
+	ctx := context.Background()
+	st, err := NewPostgresStore(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st, ctx
+}
+
+func pgEntry(t *testing.T, id string, updated time.Time, lots string) Entry {
+	t.Helper()
+	src := fmt.Sprintf(`<entry><id>%s</id><updated>%s</updated><ContractFolderStatus>
+  <ContractFolderStatusCode>PUB</ContractFolderStatusCode>
+  <ProcurementProject><BudgetAmount><TaxExclusiveAmount currencyID="EUR">0</TaxExclusiveAmount></BudgetAmount></ProcurementProject>
+  %s
+</ContractFolderStatus></entry>`, id, updated.Format(time.RFC3339), lots)
+	var e Entry
+	if err := xml.Unmarshal([]byte(src), &e); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestPostgresStoreLots(t *testing.T) {
+	st, ctx := testPostgresStore(t)
+	id := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		st.db.Exec(`DELETE FROM entries WHERE id = $1`, id)
+		st.db.Exec(`DELETE FROM entry_versions WHERE entry_id = $1`, id)
+	})
+	lot := func(n, budget string) string {
+		return `<ProcurementProjectLot><ID schemeName="ID_LOTE">` + n + `</ID><ProcurementProject>` + budget + `</ProcurementProject></ProcurementProjectLot>`
+	}
+	withBudget := `<BudgetAmount><TaxExclusiveAmount currencyID="EUR">1000</TaxExclusiveAmount></BudgetAmount>`
+	t0 := time.Date(2025, 6, 9, 10, 0, 0, 0, time.UTC)
+
+	if err := st.Upsert(ctx, pgEntry(t, id, t0, lot("1", withBudget)+lot("2", "")+lot("3", withBudget))); err != nil {
+		t.Fatal(err)
+	}
+	// La versión nueva ya no trae el lote 3.
+	if err := st.Upsert(ctx, pgEntry(t, id, t0.Add(time.Hour), lot("1", withBudget)+lot("2", ""))); err != nil {
+		t.Fatal(err)
+	}
+
+	budgets := make(map[string]sql.NullFloat64)
+	rows, err := st.db.QueryContext(ctx, `SELECT lot_id, budget FROM lots WHERE entry_id = $1`, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var lotID string
+		var b sql.NullFloat64
+		if err := rows.Scan(&lotID, &b); err != nil {
+			t.Fatal(err)
+		}
+		budgets[lotID] = b
+	}
+	if len(budgets) != 2 {
+		t.Fatalf("lotes guardados %v, want 1 y 2", budgets)
+	}
+	if b := budgets["1"]; !b.Valid || b.Float64 != 1000 {
+		t.Errorf("lote 1: budget %+v, want 1000", b)
+	}
+	if b := budgets["2"]; b.Valid {
+		t.Errorf("lote 2 sin presupuesto: budget %+v, want NULL", b)
+	}
+
+	// Un 0 publicado se guarda como 0, no como NULL.
+	var budget sql.NullFloat64
+	if err := st.db.QueryRowContext(ctx, `SELECT budget FROM entries WHERE id = $1`, id).Scan(&budget); err != nil {
+		t.Fatal(err)
+	}
+	if !budget.Valid || budget.Float64 != 0 {
+		t.Errorf("entry: budget %+v, want 0", budget)
+	}
+}
@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+	"os"
+	"strings"
+)
+
+// Lectura de <entry> sin pasar por los structs de parseFeed.go: cada elemento y
+// atributo con su ruta relativa a la entry, sin prefijos de namespace
+// ("ContractFolderStatus/ProcurementProject/Name",
+// "ContractFolderStatus/ContractFolderStatusCode/@listURI").
+
+type RawNode struct {
+	Path  string
+	Value string // texto del elemento hoja, o valor del atributo
+	Leaf  bool   // elemento sin hijos, o atributo
+}
+
+type RawEntry struct {
+	ID      string
+	Updated string
+	Nodes   []RawNode
+}
+
+// decodeRawEntries recorre un fichero atom y llama a fn con cada entry. Con
+// where, sólo con las que lo cumplen: para evaluarlo cada entry se decodifica
+// también en Entry.
+func decodeRawEntries(path string, where *Filter, fn func(*RawEntry)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := xml.NewDecoder(bufio.NewReaderSize(f, 256<<10))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "entry" {
+			var e *RawEntry
+			if where == nil {
+				e, err = readRawEntry(dec)
+			} else {
+				e, err = readFilteredEntry(dec, se, where)
+			}
+			if err != nil {
+				return err
+			}
+			if e != nil {
+				fn(e)
+			}
+		}
+	}
+}
+
+// readFilteredEntry lee la entry que abre start y la devuelve si cumple where,
+// o nil si no. Guarda sus tokens para leerla dos veces: como Entry y en bruto.
+func readFilteredEntry(dec *xml.Decoder, start xml.StartElement, where *Filter) (*RawEntry, error) {
+	toks := tokenList{start.Copy()}
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+		toks = append(toks, xml.CopyToken(tok))
+	}
+
+	var e Entry
+	replay := toks
+	if err := xml.NewTokenDecoder(&replay).Decode(&e); err != nil {
+		return nil, err
+	}
+	if !where.Match(&e) {
+		return nil, nil
+	}
+	replay = toks
+	raw := xml.NewTokenDecoder(&replay)
+	if _, err := raw.Token(); err != nil { // <entry>
+		return nil, err
+	}
+	return readRawEntry(raw)
+}
+
+// tokenList es un xml.TokenReader sobre tokens ya leídos.
+type tokenList []xml.Token
+
+func (l *tokenList) Token() (xml.Token, error) {
+	if len(*l) == 0 {
+		return nil, io.EOF
+	}
+	t := (*l)[0]
+	*l = (*l)[1:]
+	return t, nil
+}
+
+// readRawEntry lee hasta el </entry> correspondiente.
+func readRawEntry(dec *xml.Decoder) (*RawEntry, error) {
+	type frame struct {
+		path     string
+		text     strings.Builder
+		children bool
+		node     int // índice en Nodes
+	}
+	e := &RawEntry{}
+	var stack []*frame
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			p := t.Name.Local
+			if n := len(stack); n > 0 {
+				stack[n-1].children = true
+				p = stack[n-1].path + "/" + p
+			}
+			fr := &frame{path: p, node: len(e.Nodes)}
+			e.Nodes = append(e.Nodes, RawNode{Path: p})
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+					continue
+				}
+				e.Nodes = append(e.Nodes, RawNode{Path: p + "/@" + a.Name.Local, Value: a.Value, Leaf: true})
+			}
+			stack = append(stack, fr)
+		case xml.CharData:
+			if n := len(stack); n > 0 {
+				stack[n-1].text.Write(t)
+			}
+		case xml.EndElement:
+			n := len(stack)
+			if n == 0 {
+				return e, nil // </entry>
+			}
+			fr := stack[n-1]
+			stack = stack[:n-1]
+			if !fr.children {
+				v := strings.TrimSpace(fr.text.String())
+				e.Nodes[fr.node].Value = v
+				e.Nodes[fr.node].Leaf = true
+				switch fr.path {
+				case "id":
+					e.ID = v
+				case "updated":
+					e.Updated = v
+				}
+			}
+		}
+	}
+}
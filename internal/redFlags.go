@@ -0,0 +1,331 @@
+package internal
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Indicadores de riesgo ("red flags") por expediente. Son reglas simples sobre
+// lo publicado: no prueban nada, señalan qué expedientes conviene revisar. Cada
+// regla suma su peso a la puntuación (máximo 100) y deja una explicación.
+
+const (
+	FlagSingleBidder   = "single_bidder"
+	FlagAboveBudget    = "above_budget"
+	FlagShortWindow    = "short_window"
+	FlagRepeatedWinner = "repeated_winner"
+	FlagNoPublicity    = "negotiated_no_publicity"
+	FlagDeadlineFirst  = "deadline_before_publication"
+)
+
+// Pesos de cada regla en la puntuación.
+var RedFlagWeights = map[string]int{
+	FlagSingleBidder:   25,
+	FlagAboveBudget:    20,
+	FlagShortWindow:    15,
+	FlagRepeatedWinner: 15,
+	FlagNoPublicity:    25,
+	FlagDeadlineFirst:  10,
+}
+
+// ProcedureCode de "Negociado sin publicidad" en la lista
+// SyndicationTenderingProcessCode de PLACSP (versión 2.07: 1 abierto, 2
+// restringido, 3 negociado sin publicidad, 4 negociado con publicidad…).
+const (
+	procedureList                  = "SyndicationTenderingProcessCode"
+	procedureNegotiatedNoPublicity = "3"
+)
+
+// UrgencyCode "2" (urgente) y "3" (emergencia) permiten plazos reducidos.
+const urgencyOrdinary = "1"
+
+type RedFlagOptions struct {
+	MinWindowDays    int     // plazo mínimo entre DOC_CN y fin de presentación (15 por defecto)
+	UrgentWindowDays int     // idem con tramitación urgente (8 por defecto)
+	RepeatAwards     int     // adjudicaciones de un órgano a un NIF a partir de las que se marca (3 por defecto)
+	BudgetTolerance  float64 // fracción sobre el presupuesto que no se marca (0 = cualquier exceso)
+	MinScore         int     // omitir expedientes con menos puntuación
+}
+
+func (o *RedFlagOptions) defaults() {
+	if o.MinWindowDays <= 0 {
+		o.MinWindowDays = 15
+	}
+	if o.UrgentWindowDays <= 0 {
+		o.UrgentWindowDays = 8
+	}
+	if o.RepeatAwards <= 0 {
+		o.RepeatAwards = 3
+	}
+}
+
+type RedFlag struct {
+	Code   string `json:"code"`
+	Weight int    `json:"weight"`
+	LotID  string `json:"lotId,omitempty"`
+	Detail string `json:"detail"`
+}
+
+type TenderFlags struct {
+	EntryID   string    `json:"entryId"`
+	Title     string    `json:"title"`
+	URL       string    `json:"url,omitempty"`
+	BuyerID   string    `json:"buyerId"`
+	BuyerName string    `json:"buyerName"`
+	Procedure string    `json:"procedure,omitempty"`
+	Published time.Time `json:"published,omitzero"`
+	Budget    float64   `json:"budget,omitempty"`
+	Score     int       `json:"score"`
+	Flags     []RedFlag `json:"flags"`
+}
+
+// Explanation une los detalles de las reglas que saltan.
+func (t *TenderFlags) Explanation() string {
+	parts := make([]string, len(t.Flags))
+	for i, f := range t.Flags {
+		parts[i] = f.Detail
+	}
+	return strings.Join(parts, "; ")
+}
+
+// tender es lo que las reglas necesitan de un expediente.
+type tender struct {
+	id        string
+	updated   time.Time
+	title     string
+	url       string
+	buyerID   string
+	buyerName string
+	procedure string
+	procList  string // lista del listURI de ProcedureCode, "" si no viene
+	urgency   string
+	published time.Time
+	deadline  time.Time
+	budget    float64
+	cpvs      []string
+	awards    []AwardRecord
+	match     bool // pasa el filtro Where
+}
+
+func procedureListName(uri string) string {
+	list, _ := ParseListURI(uri)
+	return list
+}
+
+func newTender(e *Entry) tender {
+	return tender{
+		id:        e.ID,
+		updated:   e.Updated.Time,
+		title:     strings.TrimSpace(e.Title),
+		url:       e.URL(),
+		buyerID:   buyerID(e),
+		buyerName: strings.TrimSpace(e.OrgName()),
+		procedure: strings.TrimSpace(e.CFS.Process.ProcedureCode.Value),
+		procList:  procedureListName(e.CFS.Process.ProcedureCode.ListURI),
+		urgency:   strings.TrimSpace(e.CFS.Process.UrgencyCode.Value),
+		published: e.PublishedDate(),
+		deadline:  e.Deadline(),
+		budget:    e.Budget(),
+		cpvs:      e.CPVs(),
+		awards:    AwardRecords(e),
+	}
+}
+
+// tenderSet es el Partial del análisis: la versión más reciente de cada expediente.
+type tenderSet struct {
+	byID  map[string]tender
+	where *Filter
+}
+
+func newTenderSet(where *Filter) *tenderSet {
+	return &tenderSet{byID: make(map[string]tender), where: where}
+}
+
+func (s *tenderSet) Add(e *Entry, _ string) {
+	t := newTender(e)
+	t.match = s.where.Match(e)
+	s.put(t)
+}
+
+func (s *tenderSet) Merge(other *tenderSet) {
+	for _, t := range other.byID {
+		s.put(t)
+	}
+}
+
+func (s *tenderSet) put(t tender) {
+	if old, ok := s.byID[t.id]; ok && !t.updated.After(old.updated) {
+		return
+	}
+	s.byID[t.id] = t
+}
+
+// match aplica el filtro de CPV y fechas; la fecha es la de publicación, o
+// updated si no hay DOC_CN.
+func (t *tender) matchFilter(f *AwardFilter) bool {
+	if !t.match || !f.CPV.MatchAny(t.cpvs) {
+		return false
+	}
+	d := t.published
+	if d.IsZero() {
+		d = t.updated
+	}
+	if !f.From.IsZero() && d.Before(f.From) {
+		return false
+	}
+	return f.To.IsZero() || d.Before(f.To)
+}
+
+// redFlags aplica las reglas a los expedientes y devuelve los marcados,
+// de mayor a menor puntuación.
+func redFlags(tenders []tender, opts RedFlagOptions) []TenderFlags {
+	opts.defaults()
+
+	// Adjudicaciones por órgano y NIF, contando cada expediente una vez.
+	type pair struct{ buyer, nif string }
+	repeated := make(map[pair]int)
+	for i := range tenders {
+		seen := make(map[pair]bool)
+		for _, a := range tenders[i].awards {
+			for _, w := range a.Winners {
+				k := pair{a.BuyerID, w.NIF}
+				if !seen[k] {
+					seen[k] = true
+					repeated[k]++
+				}
+			}
+		}
+	}
+
+	var out []TenderFlags
+	for i := range tenders {
+		t := &tenders[i]
+		tf := TenderFlags{
+			EntryID: t.id, Title: t.title, URL: t.url, BuyerID: t.buyerID, BuyerName: t.buyerName,
+			Procedure: t.procedure, Published: t.published, Budget: t.budget,
+		}
+		add := func(code, lot, detail string) {
+			tf.Flags = append(tf.Flags, RedFlag{Code: code, Weight: RedFlagWeights[code], LotID: lot, Detail: detail})
+		}
+
+		// Con otra lista de códigos el "3" significaría otra cosa.
+		if t.procedure == procedureNegotiatedNoPublicity && (t.procList == "" || t.procList == procedureList) {
+			add(FlagNoPublicity, "", "procedimiento negociado sin publicidad")
+		}
+		if !t.published.IsZero() && !t.deadline.IsZero() {
+			minDays := opts.MinWindowDays
+			if t.urgency != "" && t.urgency != urgencyOrdinary {
+				minDays = opts.UrgentWindowDays
+			}
+			days := int(t.deadline.Sub(t.published).Hours() / 24)
+			if days < 0 {
+				// No es un plazo corto sino fechas incoherentes: se marca aparte.
+				add(FlagDeadlineFirst, "", fmt.Sprintf("el plazo de presentación acaba %d días antes del anuncio", -days))
+			} else if days < minDays {
+				add(FlagShortWindow, "", fmt.Sprintf("plazo de presentación de %d días (mínimo %d)", days, minDays))
+			}
+		}
+		flaggedPairs := make(map[pair]bool)
+		for _, a := range t.awards {
+			if a.Bidders == 1 {
+				add(FlagSingleBidder, a.LotID, fmt.Sprintf("una sola oferta%s", lotSuffix(a.LotID)))
+			}
+			// Los dos sin impuestos: el PayableAmount los lleva y saltaría siempre.
+			if amt := a.TaxExclusive; a.Budget > 0 && amt > a.Budget*(1+opts.BudgetTolerance) {
+				add(FlagAboveBudget, a.LotID, fmt.Sprintf("adjudicado %s sin impuestos sobre presupuesto %s (+%s%%)%s",
+					fixed2(amt), fixed2(a.Budget), strconv.FormatFloat((amt/a.Budget-1)*100, 'f', 1, 64), lotSuffix(a.LotID)))
+			}
+			for _, w := range a.Winners {
+				k := pair{a.BuyerID, w.NIF}
+				if n := repeated[k]; n >= opts.RepeatAwards && !flaggedPairs[k] {
+					flaggedPairs[k] = true
+					add(FlagRepeatedWinner, a.LotID, fmt.Sprintf("%d expedientes del órgano adjudicados a %s %s", n, w.NIF, w.Name))
+				}
+			}
+		}
+		if len(tf.Flags) == 0 {
+			continue
+		}
+
+		// Cada regla puntúa una vez aunque salte en varios lotes.
+		scored := make(map[string]bool)
+		for _, f := range tf.Flags {
+			if !scored[f.Code] {
+				scored[f.Code] = true
+				tf.Score += f.Weight
+			}
+		}
+		tf.Score = min(tf.Score, 100)
+		if tf.Score < opts.MinScore {
+			continue
+		}
+		out = append(out, tf)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].EntryID < out[j].EntryID
+	})
+	return out
+}
+
+func lotSuffix(lot string) string {
+	if lot == "" {
+		return ""
+	}
+	return " (lote " + lot + ")"
+}
+
+// ScanRedFlags lee los atom de dir y analiza los expedientes que pasan f.
+func ScanRedFlags(ctx context.Context, dir string, workers int, f AwardFilter, opts RedFlagOptions) ([]TenderFlags, error) {
+	files, err := listLocalAtoms(dir)
+	if err != nil {
+		return nil, err
+	}
+	set, err := ScanAtoms(ctx, files, workers, func() *tenderSet { return newTenderSet(f.Where) })
+	if err != nil {
+		return nil, err
+	}
+	tenders := make([]tender, 0, len(set.byID))
+	for _, id := range sortedKeys(set.byID) {
+		if t := set.byID[id]; t.matchFilter(&f) {
+			tenders = append(tenders, t)
+		}
+	}
+	return redFlags(tenders, opts), nil
+}
+
+// WriteRedFlagsCSV escribe un expediente por fila con sus reglas y la explicación.
+func WriteRedFlagsCSV(path string, flags []TenderFlags) error {
+	return writeFileAtomic(path, func(f io.Writer) error {
+		w := csv.NewWriter(f)
+		_ = w.Write([]string{"entry_id", "score", "flags", "explanation", "buyer_id", "buyer_name", "procedure", "published", "budget", "title", "url"})
+		for _, t := range flags {
+			codes := make([]string, 0, len(t.Flags))
+			for _, fl := range t.Flags {
+				if !slices.Contains(codes, fl.Code) {
+					codes = append(codes, fl.Code)
+				}
+			}
+			published := ""
+			if !t.Published.IsZero() {
+				published = t.Published.Format("2006-01-02")
+			}
+			_ = w.Write([]string{
+				t.EntryID, strconv.Itoa(t.Score), strings.Join(codes, "|"), t.Explanation(),
+				t.BuyerID, t.BuyerName, t.Procedure, published, fixed2(t.Budget), t.Title, t.URL,
+			})
+		}
+		w.Flush()
+		return w.Error()
+	})
+}
@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func flagCodes(tf []TenderFlags) []string {
+	var out []string
+	for _, t := range tf {
+		for _, f := range t.Flags {
+			out = append(out, f.Code)
+		}
+	}
+	return out
+}
+
+func TestRedFlagRules(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 6, d, 0, 0, 0, 0, time.UTC) }
+	cases := []struct {
+		name string
+		t    tender
+		want []string
+	}{
+		{"plazo corto", tender{id: "a", published: day(1), deadline: day(5), urgency: "1"}, []string{FlagShortWindow}},
+		{"plazo negativo", tender{id: "a", published: day(10), deadline: day(5)}, []string{FlagDeadlineFirst}},
+		{"plazo suficiente", tender{id: "a", published: day(1), deadline: day(20)}, nil},
+		{"negociado sin publicidad", tender{id: "a", procedure: "3", procList: procedureList}, []string{FlagNoPublicity}},
+		{"negociado con publicidad", tender{id: "a", procedure: "4", procList: procedureList}, nil},
+		{"3 de otra lista", tender{id: "a", procedure: "3", procList: "OtraLista"}, nil},
+		{"sobre presupuesto", tender{id: "a", awards: []AwardRecord{{Budget: 100, TaxExclusive: 121}}}, []string{FlagAboveBudget}},
+		// 100 sin impuestos son 121 con IVA: el PayableAmount no cuenta.
+		{"sólo payable", tender{id: "a", awards: []AwardRecord{{Budget: 100, Payable: 121}}}, nil},
+		{"sin presupuesto", tender{id: "a", awards: []AwardRecord{{TaxExclusive: 121}}}, nil},
+	}
+	for _, c := range cases {
+		got := flagCodes(redFlags([]tender{c.t}, RedFlagOptions{}))
+		if !slices.Equal(got, c.want) {
+			t.Errorf("%s: flags %v, want %v", c.name, got, c.want)
+		}
+	}
+}
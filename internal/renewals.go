@@ -0,0 +1,415 @@
+package internal
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Previsión de vencimientos: a partir de la fecha de adjudicación, la duración
+// del contrato (PlannedPeriod) y las prórrogas (ContractExtension), cuándo vence
+// cada contrato adjudicado y cuándo es probable que se vuelva a licitar.
+
+// Renewal es la previsión para una adjudicación (expediente o lote).
+type Renewal struct {
+	AwardRecord
+	Start           time.Time `json:"start"`               // inicio previsto (StartDate, o fecha de adjudicación)
+	DurationMonths  int       `json:"durationMonths"`      // duración inicial
+	ExtensionMonths int       `json:"extensionMonths"`     // prórrogas máximas
+	ExtensionSource string    `json:"extensionSource"`     // duration, dates, description o none
+	Extension       string    `json:"extension,omitempty"` // descripción de las prórrogas
+	Expiry          time.Time `json:"expiry"`              // fin del plazo inicial
+	MaxExpiry       time.Time `json:"maxExpiry"`           // fin con todas las prórrogas
+	Retender        time.Time `json:"retender"`            // nueva licitación prevista
+}
+
+// durationMonths pasa DurationMeasure a meses (DAY redondea hacia arriba a 30 días).
+func durationMonths(value int, unit string) int {
+	switch strings.ToUpper(strings.TrimSpace(unit)) {
+	case "ANN":
+		return value * 12
+	case "MON":
+		return value
+	case "DAY":
+		return (value + 29) / 30
+	}
+	return 0
+}
+
+func addMonths(t time.Time, months int) time.Time {
+	return t.AddDate(0, months, 0)
+}
+
+var spanishNumbers = map[string]int{
+	"un": 1, "una": 1, "uno": 1, "dos": 2, "tres": 3, "cuatro": 4, "cinco": 5, "seis": 6,
+	"siete": 7, "ocho": 8, "nueve": 9, "diez": 10, "doce": 12, "dieciocho": 18, "veinticuatro": 24,
+}
+
+const reNumber = `(\d+|un|una|uno|dos|tres|cuatro|cinco|seis|siete|ocho|nueve|diez|doce|dieciocho|veinticuatro)`
+
+var (
+	// "Sin prórroga", "no cabe prórroga", "No se prevén" (la descripción entera
+	// responde a si hay prórroga); no un "no hay revisión de precios" cualquiera.
+	reNoExtension = regexp.MustCompile(`^no\b|\bsin (posibilidad de )?pr[oó]rroga|\bno (se )?(prev[eé]n?|admiten?|cabe|hay) (ninguna |posibilidad de )?pr[oó]rroga|\bno (es )?prorrogable`)
+	// "dos prórrogas de un año", "2 prórrogas de 6 meses"
+	reExtensionsOf = regexp.MustCompile(reNumber + ` pr[oó]rrogas? (?:de|por) (?:hasta )?` + reNumber + ` (años?|mes(?:es)?|d[ií]as?)`)
+	// "dos prórrogas anuales", "una prórroga anual"
+	reExtensionsAnnual = regexp.MustCompile(reNumber + ` pr[oó]rrogas? anual(?:es)?`)
+	// "hasta 2 años", "plazo máximo de 12 meses"
+	rePeriod = regexp.MustCompile(reNumber + ` (años?|mes(?:es)?|d[ií]as?)`)
+	// lo que sigue a un plazo: " más otro año", ", más 6 meses"
+	reMorePeriod = regexp.MustCompile(`^,? m[aá]s (?:otr[oa]s? )?(?:` + reNumber + ` )?(años?|mes(?:es)?|d[ií]as?)`)
+)
+
+func spanishNumber(s string) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	return spanishNumbers[s]
+}
+
+func unitMonths(n int, unit string) int {
+	switch {
+	case strings.HasPrefix(unit, "año"):
+		return n * 12
+	case strings.HasPrefix(unit, "mes"):
+		return n
+	}
+	return (n + 29) / 30
+}
+
+// ParseExtensionDescription saca los meses de prórroga de textos como
+// "Dos prórrogas anuales" o "Sí se prevé, por un plazo máximo de 12 meses".
+// ok es false si el texto no dice nada reconocible.
+func ParseExtensionDescription(desc string) (months int, ok bool) {
+	d := strings.ToLower(strings.Join(strings.Fields(desc), " "))
+	if d == "" {
+		return 0, false
+	}
+	if m := reExtensionsOf.FindStringSubmatch(d); m != nil {
+		return spanishNumber(m[1]) * unitMonths(spanishNumber(m[2]), m[3]), true
+	}
+	if m := reExtensionsAnnual.FindStringSubmatch(d); m != nil {
+		return spanishNumber(m[1]) * 12, true
+	}
+	if reNoExtension.MatchString(d) {
+		return 0, true
+	}
+	// Los plazos encadenados con "más" se suman ("1 año más otro año" son dos
+	// años); de varios plazos sueltos ("hasta 2 años, en periodos de 1 año") se
+	// toma el mayor.
+	for _, loc := range rePeriod.FindAllStringSubmatchIndex(d, -1) {
+		total := unitMonths(spanishNumber(d[loc[2]:loc[3]]), d[loc[4]:loc[5]])
+		rest := d[loc[1]:]
+		for {
+			m := reMorePeriod.FindStringSubmatchIndex(rest)
+			if m == nil {
+				break
+			}
+			n := 1 // "más otro año"
+			if m[2] >= 0 {
+				n = spanishNumber(rest[m[2]:m[3]])
+			}
+			total += unitMonths(n, rest[m[4]:m[5]])
+			rest = rest[m[1]:]
+		}
+		months = max(months, total)
+		ok = true
+	}
+	return months, ok
+}
+
+// extensionMonths devuelve las prórrogas máximas de un proyecto y de dónde salen.
+func extensionMonths(ext *ContractExtension) (months int, source, desc string) {
+	if ext == nil || ext.OptionValidityPeriod == nil {
+		return 0, "none", ""
+	}
+	o := ext.OptionValidityPeriod
+	desc = strings.TrimSpace(o.Description)
+	if o.Duration != nil && o.Duration.Value > 0 {
+		return durationMonths(o.Duration.Value, o.Duration.UnitCode), "duration", desc
+	}
+	if o.StartDate != nil && o.EndDate != nil && o.StartDate.Valid && o.EndDate.Valid {
+		return monthsBetween(o.StartDate.Time, o.EndDate.Time), "dates", desc
+	}
+	if m, ok := ParseExtensionDescription(desc); ok {
+		return m, "description", desc
+	}
+	return 0, "none", desc
+}
+
+func monthsBetween(a, b time.Time) int {
+	m := (b.Year()-a.Year())*12 + int(b.Month()-a.Month())
+	if b.Day() > a.Day() {
+		m++
+	}
+	return max(m, 0)
+}
+
+// NewRenewal calcula la previsión de una adjudicación de e. Sin duración ni
+// fechas del periodo previsto no hay previsión (ok = false).
+func NewRenewal(e *Entry, r AwardRecord, leadMonths int) (Renewal, bool) {
+	p := e.CFS.Project
+	if lot := e.lot(r.LotID); lot != nil && lot.Project.Planned != nil {
+		p = lot.Project
+	}
+	ren := Renewal{AwardRecord: r}
+	ren.ExtensionMonths, ren.ExtensionSource, ren.Extension = extensionMonths(p.Extension)
+	if p.Extension == nil && e.CFS.Project.Extension != nil {
+		ren.ExtensionMonths, ren.ExtensionSource, ren.Extension = extensionMonths(e.CFS.Project.Extension)
+	}
+
+	pp := p.Planned
+	if pp == nil {
+		return ren, false
+	}
+	ren.Start = r.Date
+	if pp.StartDate.Valid {
+		ren.Start = pp.StartDate.Time
+	}
+	switch {
+	case pp.EndDate.Valid:
+		ren.Expiry = pp.EndDate.Time
+		if !ren.Start.IsZero() {
+			ren.DurationMonths = monthsBetween(ren.Start, ren.Expiry)
+		}
+	case pp.Duration.Value > 0 && !ren.Start.IsZero():
+		ren.DurationMonths = durationMonths(pp.Duration.Value, pp.Duration.UnitCode)
+		if strings.EqualFold(pp.Duration.UnitCode, "DAY") {
+			ren.Expiry = ren.Start.AddDate(0, 0, pp.Duration.Value)
+		} else {
+			ren.Expiry = addMonths(ren.Start, ren.DurationMonths)
+		}
+	default:
+		return ren, false
+	}
+	ren.MaxExpiry = addMonths(ren.Expiry, ren.ExtensionMonths)
+	ren.Retender = addMonths(ren.MaxExpiry, -leadMonths)
+	return ren, true
+}
+
+// RenewalSet es el Partial de la previsión. Como en AwardSet, de cada entry
+// cuenta sólo su versión más reciente, aunque ya no traiga adjudicaciones.
+type RenewalSet struct {
+	Where *Filter
+
+	byEntry map[string]renewalVersion
+	lead    int
+}
+
+// renewalVersion son las previsiones de la versión más reciente de una entry.
+type renewalVersion struct {
+	updated  time.Time
+	match    bool
+	renewals []Renewal
+}
+
+func NewRenewalSet(leadMonths int) *RenewalSet {
+	return &RenewalSet{byEntry: make(map[string]renewalVersion), lead: leadMonths}
+}
+
+func (s *RenewalSet) Add(e *Entry, _ string) {
+	v := renewalVersion{updated: e.Updated.Time, match: s.Where.Match(e)}
+	for _, r := range AwardRecords(e) {
+		if ren, ok := NewRenewal(e, r, s.lead); ok {
+			v.renewals = append(v.renewals, ren)
+		}
+	}
+	s.put(e.ID, v)
+}
+
+func (s *RenewalSet) Merge(other *RenewalSet) {
+	for id, v := range other.byEntry {
+		s.put(id, v)
+	}
+}
+
+func (s *RenewalSet) put(id string, v renewalVersion) {
+	if old, ok := s.byEntry[id]; ok && !v.updated.After(old.updated) {
+		return
+	}
+	s.byEntry[id] = v
+}
+
+// Fechas sobre las que se acota el informe.
+const (
+	RenewalByExpiry    = "expiry"
+	RenewalByMaxExpiry = "max"
+	RenewalByRetender  = "retender"
+)
+
+func (r *Renewal) DateBy(by string) time.Time {
+	switch by {
+	case RenewalByExpiry:
+		return r.Expiry
+	case RenewalByMaxExpiry:
+		return r.MaxExpiry
+	}
+	return r.Retender
+}
+
+type RenewalOptions struct {
+	Awards     AwardFilter // sobre la adjudicación
+	From, To   time.Time   // ventana sobre la fecha By; To exclusiva
+	By         string      // expiry, max o retender
+	LeadMonths int         // meses antes del fin en que se suele volver a licitar
+}
+
+// ScanRenewals lee los atom de dir y devuelve las previsiones que caen en la
+// ventana, ordenadas por fecha.
+func ScanRenewals(ctx context.Context, dir string, workers int, opts RenewalOptions) ([]Renewal, error) {
+	if opts.LeadMonths < 0 {
+		opts.LeadMonths = 0
+	}
+	files, err := listLocalAtoms(dir)
+	if err != nil {
+		return nil, err
+	}
+	set, err := ScanAtoms(ctx, files, workers, func() *RenewalSet {
+		s := NewRenewalSet(opts.LeadMonths)
+		s.Where = opts.Awards.Where
+		return s
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Renewal
+	for _, id := range sortedKeys(set.byEntry) {
+		v := set.byEntry[id]
+		if !v.match {
+			continue
+		}
+		for _, r := range v.renewals {
+			if !opts.Awards.Match(&r.AwardRecord) {
+				continue
+			}
+			d := r.DateBy(opts.By)
+			if !opts.From.IsZero() && d.Before(opts.From) {
+				continue
+			}
+			if !opts.To.IsZero() && !d.Before(opts.To) {
+				continue
+			}
+			out = append(out, r)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].DateBy(opts.By).Before(out[j].DateBy(opts.By))
+	})
+	return out, nil
+}
+
+// RenewalGroup resume los vencimientos de un CPV o un órgano.
+type RenewalGroup struct {
+	Dimension string    `json:"dimension"`
+	Key       string    `json:"key"`
+	Name      string    `json:"name,omitempty"` // nombre del órgano
+	Contracts int       `json:"contracts"`
+	Amount    float64   `json:"amount"`
+	Next      time.Time `json:"next"` // primera fecha de la ventana
+	Suppliers int       `json:"suppliers"`
+}
+
+// SummarizeRenewals agrupa por CPV (prefijo de cpvDigits) y por órgano. Un
+// contrato con varios CPV cuenta en cada uno.
+func SummarizeRenewals(rs []Renewal, by string, cpvDigits int) []RenewalGroup {
+	type acc struct {
+		RenewalGroup
+		suppliers map[string]bool
+	}
+	groups := make(map[[2]string]*acc)
+	add := func(dim, key, name string, r *Renewal) {
+		g, ok := groups[[2]string{dim, key}]
+		if !ok {
+			g = &acc{RenewalGroup: RenewalGroup{Dimension: dim, Key: key, Name: name}, suppliers: make(map[string]bool)}
+			groups[[2]string{dim, key}] = g
+		}
+		g.Contracts++
+		g.Amount += r.Amount()
+		if d := r.DateBy(by); g.Next.IsZero() || d.Before(g.Next) {
+			g.Next = d
+		}
+		for _, w := range r.Winners {
+			g.suppliers[w.NIF] = true
+		}
+	}
+	for i := range rs {
+		r := &rs[i]
+		seen := make(map[string]bool)
+		for _, c := range r.CPVs {
+			if cpvDigits > 0 && len(c) > cpvDigits {
+				c = c[:cpvDigits]
+			}
+			if !seen[c] {
+				seen[c] = true
+				add(DimCPV, c, "", r)
+			}
+		}
+		add(DimBuyer, r.BuyerID, r.BuyerName, r)
+	}
+
+	out := make([]RenewalGroup, 0, len(groups))
+	for _, g := range groups {
+		g.Suppliers = len(g.suppliers)
+		out = append(out, g.RenewalGroup)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Dimension != out[j].Dimension {
+			return out[i].Dimension < out[j].Dimension
+		}
+		if out[i].Contracts != out[j].Contracts {
+			return out[i].Contracts > out[j].Contracts
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
+
+func dateOrEmpty(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// WriteRenewalsCSV escribe una fila por contrato.
+func WriteRenewalsCSV(path string, rs []Renewal) error {
+	return writeFileAtomic(path, func(f io.Writer) error {
+		w := csv.NewWriter(f)
+		_ = w.Write([]string{
+			"entry_id", "lot_id", "buyer_id", "buyer_name", "cpvs", "winner_nif", "winner_name", "amount",
+			"award_date", "start", "duration_months", "extension_months", "extension_source",
+			"expiry", "max_expiry", "retender", "title", "url",
+		})
+		for _, r := range rs {
+			_ = w.Write([]string{
+				r.EntryID, r.LotID, r.BuyerID, r.BuyerName, strings.Join(r.CPVs, "|"), r.WinnerNIFs("|"), r.WinnerNames("|"), fixed2(r.Amount()),
+				dateOrEmpty(r.Date), dateOrEmpty(r.Start), strconv.Itoa(r.DurationMonths), strconv.Itoa(r.ExtensionMonths), r.ExtensionSource,
+				dateOrEmpty(r.Expiry), dateOrEmpty(r.MaxExpiry), dateOrEmpty(r.Retender), r.Title, r.URL,
+			})
+		}
+		w.Flush()
+		return w.Error()
+	})
+}
+
+// WriteRenewalGroupsCSV escribe el resumen por CPV y órgano.
+func WriteRenewalGroupsCSV(path string, gs []RenewalGroup) error {
+	return writeFileAtomic(path, func(f io.Writer) error {
+		w := csv.NewWriter(f)
+		_ = w.Write([]string{"dimension", "key", "name", "contracts", "amount", "next", "suppliers"})
+		for _, g := range gs {
+			_ = w.Write([]string{
+				g.Dimension, g.Key, g.Name, strconv.Itoa(g.Contracts), fixed2(g.Amount), dateOrEmpty(g.Next), strconv.Itoa(g.Suppliers),
+			})
+		}
+		w.Flush()
+		return w.Error()
+	})
+}
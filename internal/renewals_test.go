@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseExtensionDescription(t *testing.T) {
+	cases := []struct {
+		desc   string
+		months int
+		ok     bool
+	}{
+		{"Dos prórrogas anuales", 24, true},
+		{"una prórroga anual", 12, true},
+		{"2 prórrogas de 6 meses", 12, true},
+		{"Tres prórrogas por hasta un año", 36, true},
+		{"Sí se prevé, por un plazo máximo de 12 meses", 12, true},
+		{"Prorrogable hasta 2 años", 24, true},
+		{"1 año más otro año", 24, true},
+		{"Prorrogable por un año más otros 6 meses", 18, true},
+		{"6 meses, más 6 meses más 6 meses", 18, true},
+		{"Hasta 2 años, en periodos de 1 año", 24, true},
+		{"Dos años más", 24, true},
+		{"30 días", 1, true},
+		{"Sin prórroga", 0, true},
+		{"No se prevén", 0, true},
+		{"No cabe prórroga", 0, true},
+		{"No hay prórrogas. Duración de 2 años", 0, true},
+		{"Dos prórrogas de un año. No hay revisión de precios", 24, true},
+		{"Se prevén dos prórrogas anuales; no cabe revisión de precios", 24, true},
+		{"Un año; no se admite revisión de precios", 12, true},
+		{"Según pliego", 0, false},
+		{"", 0, false},
+	}
+	for _, c := range cases {
+		months, ok := ParseExtensionDescription(c.desc)
+		if months != c.months || ok != c.ok {
+			t.Errorf("ParseExtensionDescription(%q) = %d, %v; want %d, %v", c.desc, months, ok, c.months, c.ok)
+		}
+	}
+}
+
+// Como en TestAwardSetStaleVersion: la versión posterior sin adjudicación deja
+// fuera la previsión de la anterior.
+func TestRenewalSetStaleVersion(t *testing.T) {
+	const planned = `<ProcurementProject><PlannedPeriod><StartDate>2025-01-01</StartDate><EndDate>2026-01-01</EndDate></PlannedPeriod></ProcurementProject>`
+	v1 := parseTestEntry(t, planned+fmt.Sprintf(testAward, ""))
+	v1.Updated.Time = time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	v2 := parseTestEntry(t, planned)
+	v2.Updated.Time = v1.Updated.Time.AddDate(0, 1, 0)
+
+	s := NewRenewalSet(0)
+	s.Add(v1, "")
+	if n := len(s.byEntry["e"].renewals); n != 1 {
+		t.Fatalf("v1: %d previsiones, want 1", n)
+	}
+	s.Add(v2, "")
+	if n := len(s.byEntry["e"].renewals); n != 0 {
+		t.Errorf("v1 y luego v2: %d previsiones, want 0", n)
+	}
+
+	a, b := NewRenewalSet(0), NewRenewalSet(0)
+	a.Add(v2, "")
+	b.Add(v1, "")
+	a.Merge(b)
+	if n := len(a.byEntry["e"].renewals); n != 0 {
+		t.Errorf("v2 y luego v1: %d previsiones, want 0", n)
+	}
+}
@@ -0,0 +1,236 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Harness de replay: reproduce versiones archivadas (versionN.atom, o el fichero
+// concatenado de ExtractContractHistory) por el camino de ingesta y compara los
+// eventos emitidos y el estado final con un fichero golden.
+
+type ReplayEvent struct {
+	Version   time.Time `json:"version"` // updated de la versión que lo produjo
+	Type      EventType `json:"type"`
+	CreatedAt time.Time `json:"createdAt"`
+	EntryID   string    `json:"entryId"`
+	LotID     string    `json:"lotId,omitempty"`
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to,omitempty"`
+	Document  string    `json:"document,omitempty"`
+	Winner    string    `json:"winner,omitempty"`
+}
+
+type LotSnapshot struct {
+	ID         string  `json:"id"`
+	ResultCode string  `json:"resultCode,omitempty"`
+	Winner     string  `json:"winner,omitempty"`
+	Amount     float64 `json:"amount,omitempty"`
+}
+
+// EntrySnapshot es el estado final guardado de una entry, reducido a lo que
+// interesa comprobar.
+type EntrySnapshot struct {
+	ID        string        `json:"id"`
+	Updated   time.Time     `json:"updated"`
+	Status    string        `json:"status"`
+	Budget    float64       `json:"budget,omitempty"`
+	Deadline  string        `json:"deadline,omitempty"`
+	Lots      []LotSnapshot `json:"lots,omitempty"`
+	Documents []string      `json:"documents,omitempty"`
+}
+
+type ReplayResult struct {
+	Versions int             `json:"versions"`
+	Events   []ReplayEvent   `json:"events"`
+	Final    []EntrySnapshot `json:"final"`
+}
+
+type replayItem struct {
+	at    time.Time
+	entry *Entry
+	tomb  *Tombstone
+}
+
+// loadReplayItems lee un fichero o todos los .atom de un directorio. Las
+// versiones repetidas (mismo ID y updated) se cuentan una vez.
+func loadReplayItems(path string) ([]replayItem, error) {
+	files := []string{path}
+	if st, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if st.IsDir() {
+		if files, err = filepath.Glob(filepath.Join(path, "*.atom")); err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+	}
+
+	var items []replayItem
+	seen := make(map[string]bool)
+	for _, f := range files {
+		err := decodeAtom(f, func(e Entry) {
+			key := e.ID + "|" + e.Updated.UTC().Format(time.RFC3339Nano)
+			if seen[key] {
+				return
+			}
+			seen[key] = true
+			items = append(items, replayItem{at: e.Updated.Time, entry: &e})
+		}, func(t Tombstone) {
+			key := "tomb|" + t.Ref + "|" + t.When.UTC().Format(time.RFC3339Nano)
+			if seen[key] {
+				return
+			}
+			seen[key] = true
+			items = append(items, replayItem{at: t.When.Time, tomb: &t})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].at.Before(items[j].at) })
+	return items, nil
+}
+
+// Replay reproduce en orden de updated las versiones de path sobre un store vacío.
+func Replay(ctx context.Context, path string) (*ReplayResult, error) {
+	items, err := loadReplayItems(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%s: no entries", path)
+	}
+
+	st := NewMemoryStore()
+	res := &ReplayResult{Events: []ReplayEvent{}}
+	ids := make(map[string]bool)
+
+	for _, it := range items {
+		var events []Event
+		if it.entry != nil {
+			res.Versions++
+			ids[it.entry.ID] = true
+			events, err = Ingest(ctx, st, *it.entry)
+		} else {
+			events, err = IngestTombstone(ctx, st, *it.tomb)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, ev := range events {
+			res.Events = append(res.Events, replayEvent(it.at, ev))
+		}
+	}
+
+	for id := range ids {
+		e, err := st.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		res.Final = append(res.Final, snapshotEntry(e))
+	}
+	sort.Slice(res.Final, func(i, j int) bool { return res.Final[i].ID < res.Final[j].ID })
+	return res, nil
+}
+
+func replayEvent(version time.Time, ev Event) ReplayEvent {
+	out := ReplayEvent{
+		Version:   version,
+		Type:      ev.Type,
+		CreatedAt: ev.CreatedAt,
+		EntryID:   ev.EntryID,
+		LotID:     ev.LotID,
+		From:      ev.From,
+		To:        ev.To,
+	}
+	if ev.Document != nil {
+		out.Document = ev.Document.ID
+	}
+	if ev.Award != nil {
+		out.Winner = ev.Award.NIF
+	}
+	return out
+}
+
+func snapshotEntry(e *Entry) EntrySnapshot {
+	s := EntrySnapshot{
+		ID:       e.ID,
+		Updated:  e.Updated.Time,
+		Status:   e.Status(),
+		Budget:   e.Budget(),
+		Deadline: formatDate(e.Deadline()),
+	}
+	for _, l := range eventLots(e) {
+		s.Lots = append(s.Lots, LotSnapshot{
+			ID:         l.id,
+			ResultCode: l.award.ResultCode,
+			Winner:     l.award.NIF,
+			Amount:     l.award.Amount,
+		})
+	}
+	for _, d := range e.Documents() {
+		s.Documents = append(s.Documents, d.ID)
+	}
+	return s
+}
+
+// CheckGolden compara got con el JSON de golden. Con update reescribe el fichero.
+func CheckGolden(golden string, got *ReplayResult, update bool) error {
+	b, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	if update {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(golden, b, 0o644)
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(want, b) {
+		return nil
+	}
+	return fmt.Errorf("%s: replay differs from golden\n%s", golden, lineDiff(string(want), string(b), 10))
+}
+
+// lineDiff enseña las primeras max líneas distintas entre want y got.
+func lineDiff(want, got string, max int) string {
+	wl := strings.Split(want, "\n")
+	gl := strings.Split(got, "\n")
+	n := len(wl)
+	if len(gl) > n {
+		n = len(gl)
+	}
+
+	var b strings.Builder
+	shown := 0
+	for i := 0; i < n && shown < max; i++ {
+		var w, g string
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if w == g {
+			continue
+		}
+		fmt.Fprintf(&b, "line %d:\n  - %s\n  + %s\n", i+1, strings.TrimSpace(w), strings.TrimSpace(g))
+		shown++
+	}
+	return b.String()
+}
@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// replayFixture busca las versiones de un golden: tests/<name>.atom (fichero
+// concatenado de ExtractContractHistory) o el directorio del caso en los tests
+// del cron job.
+func replayFixture(name string) string {
+	for _, p := range []string{
+		filepath.Join("..", "tests", name+".atom"),
+		filepath.Join("..", "cron_job", "src", "tests", name),
+	} {
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// Cada golden de tests/golden se reproduce contra sus versiones archivadas.
+// Con un cambio intencionado: licitaciones replay -update <fixture>.
+func TestReplayGolden(t *testing.T) {
+	goldens, err := filepath.Glob(filepath.Join("..", "tests", "golden", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(goldens) == 0 {
+		t.Fatal("no hay ficheros golden")
+	}
+	for _, g := range goldens {
+		name := strings.TrimSuffix(filepath.Base(g), ".json")
+		t.Run(name, func(t *testing.T) {
+			fixture := replayFixture(name)
+			if fixture == "" {
+				t.Fatalf("%s no tiene versiones que reproducir", g)
+			}
+			res, err := Replay(context.Background(), fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := CheckGolden(g, res, false); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
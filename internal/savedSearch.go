@@ -0,0 +1,450 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Búsquedas guardadas por usuario (CPV, NUTS, palabras, importe, estado y un
+// filtro where) y su historial de avisos, todo en un fichero JSON. Cada versión
+// nueva de una entry se evalúa contra todas; una entry sólo se avisa otra vez a
+// la misma búsqueda si ha cambiado de estado desde el último aviso.
+
+type SavedSearch struct {
+	ID        string    `json:"id"` // usuario/nombre
+	User      string    `json:"user"`
+	Name      string    `json:"name"`
+	CPV       []string  `json:"cpv,omitempty"`  // prefijos
+	NUTS      []string  `json:"nuts,omitempty"` // prefijos
+	Keywords  string    `json:"keywords,omitempty"`
+	MinAmount float64   `json:"minAmount,omitempty"`
+	MaxAmount float64   `json:"maxAmount,omitempty"`
+	Status    []string  `json:"status,omitempty"`
+	Where     string    `json:"where,omitempty"`
+	Created   time.Time `json:"created"`
+	Seen      time.Time `json:"seen,omitzero"` // updated más reciente evaluado
+
+	filter  SearchFilter
+	where   *Filter
+	clauses []clause
+}
+
+// compile valida la búsqueda y prepara lo necesario para Match.
+func (s *SavedSearch) compile() error {
+	if strings.TrimSpace(s.User) == "" || strings.TrimSpace(s.Name) == "" {
+		return errors.New("búsqueda: faltan el usuario o el nombre")
+	}
+	if strings.Contains(s.User, "/") {
+		return fmt.Errorf("búsqueda: el usuario %q no puede llevar /", s.User)
+	}
+	s.ID = s.User + "/" + s.Name
+	for i, c := range s.CPV {
+		var err error
+		if s.CPV[i], err = ParseCPVPrefix(c); err != nil {
+			return fmt.Errorf("búsqueda %s: %w", s.ID, err)
+		}
+	}
+	for i, n := range s.NUTS {
+		s.NUTS[i] = strings.ToUpper(strings.TrimSpace(n))
+		if !reNUTSPrefix.MatchString(s.NUTS[i]) {
+			return fmt.Errorf("búsqueda %s: prefijo NUTS no válido %q (p.ej. ES, ES52, ES523)", s.ID, n)
+		}
+	}
+	if s.MaxAmount > 0 && s.MinAmount > s.MaxAmount {
+		return fmt.Errorf("búsqueda %s: el importe mínimo es mayor que el máximo", s.ID)
+	}
+	var err error
+	if s.clauses, err = parseQuery(s.Keywords); err != nil {
+		return fmt.Errorf("búsqueda %s: %w", s.ID, err)
+	}
+	if s.where, err = ParseFilterFlag(s.Where); err != nil {
+		return fmt.Errorf("búsqueda %s: %w", s.ID, err)
+	}
+	s.filter = SearchFilter{
+		CPV:       CPVFilter{Include: s.CPV},
+		NUTS:      s.NUTS,
+		Status:    s.Status,
+		MinAmount: s.MinAmount,
+		MaxAmount: s.MaxAmount,
+	}
+	return nil
+}
+
+// Match indica si e cumple la búsqueda. Las palabras siguen la sintaxis de
+// search sobre título, resumen y lotes; los CPV incluyen los de los lotes.
+func (s *SavedSearch) Match(e *Entry) bool {
+	doc := NewIndexDoc(e)
+	if !s.filter.Match(&doc) || !s.where.Match(e) {
+		return false
+	}
+	if len(s.clauses) == 0 {
+		return true
+	}
+	byTerm, _ := termPositions(indexFields(e))
+	for _, c := range s.clauses {
+		if containsPhrase(byTerm, c.tokens) == c.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// containsPhrase indica si los tokens aparecen seguidos en byTerm.
+func containsPhrase(byTerm map[string][]uint32, toks []Token) bool {
+	lists := make([]map[uint32][]uint32, len(toks))
+	for i, t := range toks {
+		pos, ok := byTerm[t.Term]
+		if !ok {
+			return false
+		}
+		lists[i] = map[uint32][]uint32{0: pos}
+	}
+	return phraseAt(lists, toks, 0, lists[0][0])
+}
+
+// SearchMatch es un aviso: una versión de una entry que casó con una búsqueda.
+type SearchMatch struct {
+	SearchID   string    `json:"searchId"`
+	User       string    `json:"user"`
+	EntryID    string    `json:"entryId"`
+	Title      string    `json:"title"`
+	URL        string    `json:"url,omitempty"`
+	OrgName    string    `json:"orgName,omitempty"`
+	CPVs       []string  `json:"cpvs,omitempty"`
+	Status     string    `json:"status"`
+	PrevStatus string    `json:"prevStatus,omitempty"` // estado del aviso anterior, si lo hubo
+	Updated    time.Time `json:"updated"`
+	MatchedAt  time.Time `json:"matchedAt"`
+}
+
+// Event pasa el aviso a evento para los notificadores.
+func (m *SearchMatch) Event() Event {
+	return Event{
+		Type:      EventSearchMatch,
+		CreatedAt: m.MatchedAt,
+		EntryID:   m.EntryID,
+		Title:     m.Title,
+		URL:       m.URL,
+		OrgName:   m.OrgName,
+		CPVs:      m.CPVs,
+		From:      m.PrevStatus,
+		To:        m.Status,
+		Search:    m.SearchID,
+	}
+}
+
+// AlertBook son las búsquedas guardadas y su historial. Es seguro usarlo desde
+// varias goroutines (los workers de la ingesta).
+type AlertBook struct {
+	Searches []*SavedSearch `json:"searches"`
+	Matches  []SearchMatch  `json:"matches"` // historial, en el orden en que se avisó
+
+	path string
+	mu   sync.Mutex
+	last map[string]int // searchID + " " + entryID -> último aviso en Matches
+}
+
+// LoadAlertBook lee el fichero de búsquedas; si no existe, empieza vacío.
+func LoadAlertBook(path string) (*AlertBook, error) {
+	b := &AlertBook{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, b); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	for _, s := range b.Searches {
+		if err := s.compile(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	b.last = make(map[string]int)
+	for i, m := range b.Matches {
+		b.last[m.SearchID+" "+m.EntryID] = i
+	}
+	return b, nil
+}
+
+// Save guarda el fichero.
+func (b *AlertBook) Save() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.Searches == nil {
+		b.Searches = []*SavedSearch{}
+	}
+	if b.Matches == nil {
+		b.Matches = []SearchMatch{}
+	}
+	return WriteJSONFile(b.path, b)
+}
+
+// Add valida s y la añade. El nombre no puede repetirse para el mismo usuario.
+func (b *AlertBook) Add(s SavedSearch) (*SavedSearch, error) {
+	if err := s.compile(); err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.find(s.ID) != nil {
+		return nil, fmt.Errorf("búsqueda %s: ya existe", s.ID)
+	}
+	b.Searches = append(b.Searches, &s)
+	return &s, nil
+}
+
+// Remove borra la búsqueda id. Su historial se conserva.
+func (b *AlertBook) Remove(id string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(b.Searches)
+	b.Searches = slices.DeleteFunc(b.Searches, func(s *SavedSearch) bool { return s.ID == id })
+	return len(b.Searches) < n
+}
+
+func (b *AlertBook) find(id string) *SavedSearch {
+	for _, s := range b.Searches {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// ForUser devuelve las búsquedas de user; con user vacío, todas.
+func (b *AlertBook) ForUser(user string) []*SavedSearch {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := []*SavedSearch{}
+	for _, s := range b.Searches {
+		if user == "" || s.User == user {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// History devuelve los avisos de user (vacío: todos) y, si search no está
+// vacío, sólo los de esa búsqueda.
+func (b *AlertBook) History(user, search string) []SearchMatch {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := []SearchMatch{}
+	for _, m := range b.Matches {
+		if (user == "" || m.User == user) && (search == "" || m.SearchID == search) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func (b *AlertBook) evaluate(s *SavedSearch, e *Entry, at time.Time) (SearchMatch, bool) {
+	if e.Updated.After(s.Seen) {
+		s.Seen = e.Updated.Time
+	}
+	if !s.Match(e) {
+		return SearchMatch{}, false
+	}
+	key := s.ID + " " + e.ID
+	m := SearchMatch{
+		SearchID:  s.ID,
+		User:      s.User,
+		EntryID:   e.ID,
+		Title:     strings.TrimSpace(e.Title),
+		URL:       e.URL(),
+		OrgName:   strings.TrimSpace(e.OrgName()),
+		CPVs:      e.CPVs(),
+		Status:    e.Status(),
+		Updated:   e.Updated.Time,
+		MatchedAt: at,
+	}
+	if i, ok := b.last[key]; ok {
+		prev := b.Matches[i]
+		if !e.Updated.After(prev.Updated) || prev.Status == m.Status {
+			return SearchMatch{}, false
+		}
+		m.PrevStatus = prev.Status
+	}
+	b.last[key] = len(b.Matches)
+	b.Matches = append(b.Matches, m)
+	return m, true
+}
+
+// ---------- Archivo ----------
+
+// alertSource es el Partial de Run: las versiones posteriores a since que casan
+// con alguna búsqueda, y el updated más reciente de cada entry (case o no) para
+// que una búsqueda nueva sólo mire la última versión.
+type alertSource struct {
+	since    time.Time
+	searches []*SavedSearch
+	latest   map[string]time.Time
+	versions map[string][]Entry
+}
+
+func newAlertSource(since time.Time, searches []*SavedSearch) *alertSource {
+	return &alertSource{
+		since:    since,
+		searches: searches,
+		latest:   make(map[string]time.Time),
+		versions: make(map[string][]Entry),
+	}
+}
+
+// alertSince es el Seen más antiguo: lo anterior ya lo han visto todas.
+func alertSince(searches []*SavedSearch) time.Time {
+	var since time.Time
+	for i, s := range searches {
+		if i == 0 || s.Seen.Before(since) {
+			since = s.Seen
+		}
+	}
+	return since
+}
+
+func (p *alertSource) Add(e *Entry, _ string) {
+	if !e.Updated.After(p.since) {
+		return
+	}
+	if t, ok := p.latest[e.ID]; !ok || e.Updated.After(t) {
+		p.latest[e.ID] = e.Updated.Time
+	}
+	for _, s := range p.searches {
+		if s.Match(e) {
+			p.versions[e.ID] = append(p.versions[e.ID], *e)
+			return
+		}
+	}
+}
+
+func (p *alertSource) Merge(other *alertSource) {
+	for id, t := range other.latest {
+		if old, ok := p.latest[id]; !ok || t.After(old) {
+			p.latest[id] = t
+		}
+	}
+	for id, vs := range other.versions {
+		p.versions[id] = append(p.versions[id], vs...)
+	}
+}
+
+// Run evalúa las búsquedas contra las versiones del archivo de dir que aún no
+// han visto, en orden cronológico. Una búsqueda que nunca se ha ejecutado sólo
+// mira la versión más reciente de cada entry, para no avisar de todo el pasado.
+func (b *AlertBook) Run(ctx context.Context, dir string, workers int, at time.Time) ([]SearchMatch, error) {
+	b.mu.Lock()
+	searches := slices.Clone(b.Searches)
+	b.mu.Unlock()
+	if len(searches) == 0 {
+		return []SearchMatch{}, nil
+	}
+	since := alertSince(searches)
+
+	files, err := listLocalAtoms(dir)
+	if err != nil {
+		return nil, err
+	}
+	// Las entries de una página son anteriores a su fecha: las páginas de antes
+	// de since no tienen nada nuevo.
+	if !since.IsZero() {
+		loc, err := time.LoadLocation("Europe/Madrid")
+		if err != nil {
+			loc = time.UTC
+		}
+		files = slices.DeleteFunc(files, func(f string) bool {
+			ts, ok := parseTimestampFromPath(f, loc)
+			return ok && ts.Before(since)
+		})
+	}
+	p, err := ScanAtoms(ctx, files, workers, func() *alertSource {
+		return newAlertSource(since, searches)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.evaluateSource(searches, p, at), nil
+}
+
+// evaluateSource evalúa las versiones de p en orden cronológico.
+func (b *AlertBook) evaluateSource(searches []*SavedSearch, p *alertSource, at time.Time) []SearchMatch {
+	var all []*Entry
+	for _, vs := range p.versions {
+		for i := range vs {
+			all = append(all, &vs[i])
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if !all[i].Updated.Equal(all[j].Updated.Time) {
+			return all[i].Updated.Before(all[j].Updated.Time)
+		}
+		return all[i].ID < all[j].ID
+	})
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := []SearchMatch{}
+	for _, s := range searches {
+		seen := s.Seen
+		for _, e := range all {
+			if seen.IsZero() && !e.Updated.Equal(p.latest[e.ID]) {
+				continue
+			}
+			if !e.Updated.After(seen) {
+				continue
+			}
+			if m, ok := b.evaluate(s, e, at); ok {
+				out = append(out, m)
+			}
+		}
+		for _, t := range p.latest {
+			if t.After(s.Seen) {
+				s.Seen = t
+			}
+		}
+	}
+	return out
+}
+
+// AlertBatch junta las versiones nuevas de una ingesta para evaluarlas al final
+// con las reglas de Run. Evaluarlas según llegan avisaría, en la primera carga,
+// de todas las versiones históricas del archivo.
+type AlertBatch struct {
+	book     *AlertBook
+	searches []*SavedSearch
+	mu       sync.Mutex
+	src      *alertSource
+}
+
+// NewBatch prepara un AlertBatch con las búsquedas actuales.
+func (b *AlertBook) NewBatch() *AlertBatch {
+	b.mu.Lock()
+	searches := slices.Clone(b.Searches)
+	b.mu.Unlock()
+	return &AlertBatch{book: b, searches: searches, src: newAlertSource(alertSince(searches), searches)}
+}
+
+// Add guarda una versión; se puede llamar desde varias goroutines.
+func (a *AlertBatch) Add(e *Entry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.src.Add(e, "")
+}
+
+// Evaluate evalúa lo guardado y devuelve los avisos nuevos, que quedan en el
+// historial del AlertBook.
+func (a *AlertBatch) Evaluate(at time.Time) []SearchMatch {
+	if len(a.searches) == 0 {
+		return []SearchMatch{}
+	}
+	return a.book.evaluateSource(a.searches, a.src, at)
+}
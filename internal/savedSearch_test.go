@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func alertEntry(id, status string, updated time.Time) *Entry {
+	e := &Entry{ID: id, Title: "Suministro de gasóleo", Updated: RFC3339Time{updated}}
+	e.CFS.StatusCode.Value = status
+	return e
+}
+
+// Una ingesta sobre un store vacío entrega todas las versiones históricas: una
+// búsqueda nueva sólo debe avisar de la última de cada entry y, en la siguiente
+// ingesta, sólo de lo posterior a su Seen.
+func TestAlertBatchFirstIngest(t *testing.T) {
+	book, err := LoadAlertBook(filepath.Join(t.TempDir(), "searches.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := book.Add(SavedSearch{User: "ana", Name: "gasoleo", Keywords: "gasóleo"}); err != nil {
+		t.Fatal(err)
+	}
+	day := func(d int) time.Time { return time.Date(2025, 6, d, 0, 0, 0, 0, time.UTC) }
+
+	batch := book.NewBatch()
+	// Llegan desordenadas, como desde varios workers.
+	batch.Add(alertEntry("a", "ADJ", day(3)))
+	batch.Add(alertEntry("a", "PUB", day(1)))
+	batch.Add(alertEntry("a", "EV", day(2)))
+	batch.Add(alertEntry("b", "PUB", day(2)))
+	ms := batch.Evaluate(day(10))
+	if len(ms) != 2 {
+		t.Fatalf("primera ingesta: %d avisos %+v, want 2 (la última versión de a y de b)", len(ms), ms)
+	}
+	for _, m := range ms {
+		if m.EntryID == "a" && m.Status != "ADJ" {
+			t.Errorf("a avisada con %s, want ADJ", m.Status)
+		}
+	}
+
+	batch = book.NewBatch()
+	batch.Add(alertEntry("a", "ADJ", day(3))) // ya vista
+	batch.Add(alertEntry("a", "RES", day(4)))
+	batch.Add(alertEntry("b", "PUB", day(5))) // mismo estado
+	ms = batch.Evaluate(day(10))
+	if len(ms) != 1 || ms[0].EntryID != "a" || ms[0].PrevStatus != "ADJ" || ms[0].Status != "RES" {
+		t.Fatalf("segunda ingesta: %+v, want a ADJ -> RES", ms)
+	}
+}
@@ -0,0 +1,596 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Índice invertido en disco sobre título, resumen y nombres de lote de la
+// última versión de cada entry. Dos ficheros en el directorio del índice:
+//
+//	meta.gob      documentos (con los datos para filtrar) y diccionario de términos
+//	postings.bin  por término, sus documentos y posiciones en varint con deltas
+//
+// Al abrir se carga meta.gob; las postings se leen del disco según la consulta.
+
+const (
+	indexVersion  = 3
+	indexMeta     = "meta.gob"
+	indexPostings = "postings.bin"
+	// Hueco de posiciones entre campos para que una frase no empiece en el
+	// título y acabe en el resumen.
+	fieldGap = 100
+)
+
+type IndexDoc struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	URL       string    `json:"url,omitempty"`
+	Org       string    `json:"org"`
+	Status    string    `json:"status"`
+	CPVs      []string  `json:"cpvs,omitempty"` // del expediente y de los lotes
+	NUTS      string    `json:"nuts,omitempty"`
+	Province  string    `json:"province,omitempty"`
+	Region    string    `json:"region,omitempty"`
+	Budget    float64   `json:"budget,omitempty"`
+	HasBudget bool      `json:"hasBudget,omitempty"` // distingue un presupuesto de 0 de uno que no se publicó
+	Updated   time.Time `json:"updated"`
+	Len       int       `json:"-"` // términos indexados, para BM25
+}
+
+type termInfo struct {
+	DF     int // documentos con el término
+	Offset int64
+	Length int64
+}
+
+type indexMetaFile struct {
+	Version int
+	Built   time.Time
+	Docs    []IndexDoc
+	Terms   map[string]termInfo
+	AvgLen  float64
+}
+
+// ---------- Construcción ----------
+
+// indexSource es el Partial de la construcción: por ID, la versión más reciente
+// con el texto a indexar.
+type indexSource struct {
+	byID map[string]indexEntry
+}
+
+type indexEntry struct {
+	doc    IndexDoc
+	fields []string
+}
+
+func newIndexSource() *indexSource {
+	return &indexSource{byID: make(map[string]indexEntry)}
+}
+
+func (s *indexSource) Add(e *Entry, _ string) {
+	s.put(indexEntry{doc: NewIndexDoc(e), fields: indexFields(e)})
+}
+
+// indexFields son los textos que se indexan: título, resumen y nombre de cada lote.
+func indexFields(e *Entry) []string {
+	fields := []string{e.Title, e.Summary}
+	for i := range e.CFS.Lots {
+		fields = append(fields, e.CFS.Lots[i].Project.Name)
+	}
+	return fields
+}
+
+// termPositions analiza fields y devuelve las posiciones de cada término, con
+// fieldGap entre campos, y el total de términos.
+func termPositions(fields []string) (map[string][]uint32, int) {
+	byTerm := make(map[string][]uint32)
+	base, n := 0, 0
+	for _, text := range fields {
+		toks := Analyze(text)
+		for _, t := range toks {
+			byTerm[t.Term] = append(byTerm[t.Term], uint32(base+t.Pos))
+		}
+		n += len(toks)
+		if len(toks) > 0 {
+			base += toks[len(toks)-1].Pos + 1
+		}
+		base += fieldGap
+	}
+	return byTerm, n
+}
+
+// NewIndexDoc resume e para el índice. Los CPV incluyen los de los lotes.
+func NewIndexDoc(e *Entry) IndexDoc {
+	d := IndexDoc{
+		ID:      e.ID,
+		Title:   strings.TrimSpace(e.Title),
+		URL:     e.URL(),
+		Org:     strings.TrimSpace(e.OrgName()),
+		Status:  e.Status(),
+		CPVs:    e.CPVs(),
+		NUTS:    e.NUTS(),
+		Budget:  e.Budget(),
+		Updated: e.Updated.Time,
+
+		HasBudget: e.CFS.Project.Budget != nil,
+	}
+	if g := e.Geo(); g != nil {
+		d.Province, d.Region = g.Province, g.Region
+	}
+	for i := range e.CFS.Lots {
+		for _, c := range commodityCPVs(e.CFS.Lots[i].Project.Commodity) {
+			if !slices.Contains(d.CPVs, c) {
+				d.CPVs = append(d.CPVs, c)
+			}
+		}
+	}
+	return d
+}
+
+func (s *indexSource) Merge(other *indexSource) {
+	for _, ie := range other.byID {
+		s.put(ie)
+	}
+}
+
+func (s *indexSource) put(ie indexEntry) {
+	if old, ok := s.byID[ie.doc.ID]; ok && !ie.doc.Updated.After(old.doc.Updated) {
+		return
+	}
+	s.byID[ie.doc.ID] = ie
+}
+
+type posting struct {
+	doc uint32
+	pos []uint32
+}
+
+// BuildIndex indexa los atom de dataDir en indexDir, sustituyendo el índice
+// anterior. Devuelve el número de documentos y de términos.
+func BuildIndex(ctx context.Context, dataDir, indexDir string, workers int) (docs, terms int, err error) {
+	files, err := listLocalAtoms(dataDir)
+	if err != nil {
+		return 0, 0, err
+	}
+	src, err := ScanAtoms(ctx, files, workers, newIndexSource)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	meta := indexMetaFile{Version: indexVersion, Built: time.Now().UTC(), Terms: make(map[string]termInfo)}
+	postings := make(map[string][]posting)
+	total := 0
+	for n, id := range sortedKeys(src.byID) {
+		ie := src.byID[id]
+		var byTerm map[string][]uint32
+		byTerm, ie.doc.Len = termPositions(ie.fields)
+		for term, pos := range byTerm {
+			postings[term] = append(postings[term], posting{doc: uint32(n), pos: pos})
+		}
+		total += ie.doc.Len
+		meta.Docs = append(meta.Docs, ie.doc)
+	}
+	if len(meta.Docs) > 0 {
+		meta.AvgLen = float64(total) / float64(len(meta.Docs))
+	}
+
+	if err := os.MkdirAll(indexDir, 0o755); err != nil {
+		return 0, 0, err
+	}
+	err = writeFileAtomic(filepath.Join(indexDir, indexPostings), func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+		var off int64
+		buf := make([]byte, binary.MaxVarintLen64)
+		put := func(v uint64) {
+			n := binary.PutUvarint(buf, v)
+			bw.Write(buf[:n])
+			off += int64(n)
+		}
+		for _, term := range sortedKeys(postings) {
+			start := off
+			prevDoc := uint32(0)
+			for _, p := range postings[term] {
+				put(uint64(p.doc - prevDoc))
+				prevDoc = p.doc
+				put(uint64(len(p.pos)))
+				prevPos := uint32(0)
+				for _, pos := range p.pos {
+					put(uint64(pos - prevPos))
+					prevPos = pos
+				}
+			}
+			meta.Terms[term] = termInfo{DF: len(postings[term]), Offset: start, Length: off - start}
+		}
+		return bw.Flush()
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	// meta.gob al final: un índice a medias no se abre con postings nuevas.
+	err = writeFileAtomic(filepath.Join(indexDir, indexMeta), func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(&meta)
+	})
+	return len(meta.Docs), len(meta.Terms), err
+}
+
+// ---------- Consulta ----------
+
+type Index struct {
+	meta     indexMetaFile
+	postings *os.File
+}
+
+// OpenIndex abre un índice creado con BuildIndex.
+func OpenIndex(dir string) (*Index, error) {
+	f, err := os.Open(filepath.Join(dir, indexMeta))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ix := &Index{}
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&ix.meta); err != nil {
+		return nil, fmt.Errorf("índice %s: %w", dir, err)
+	}
+	if ix.meta.Version != indexVersion {
+		return nil, fmt.Errorf("índice %s: versión %d, se esperaba %d; hay que reconstruirlo", dir, ix.meta.Version, indexVersion)
+	}
+	if ix.postings, err = os.Open(filepath.Join(dir, indexPostings)); err != nil {
+		return nil, err
+	}
+	return ix, nil
+}
+
+func (ix *Index) Close() error {
+	return ix.postings.Close()
+}
+
+func (ix *Index) Docs() int { return len(ix.meta.Docs) }
+
+func (ix *Index) Built() time.Time { return ix.meta.Built }
+
+func (ix *Index) readPostings(term string) ([]posting, error) {
+	ti, ok := ix.meta.Terms[term]
+	if !ok {
+		return nil, nil
+	}
+	buf := make([]byte, ti.Length)
+	if _, err := ix.postings.ReadAt(buf, ti.Offset); err != nil {
+		return nil, err
+	}
+	out := make([]posting, 0, ti.DF)
+	doc := uint32(0)
+	for len(buf) > 0 {
+		next := func() uint32 {
+			v, n := binary.Uvarint(buf)
+			buf = buf[n:]
+			return uint32(v)
+		}
+		doc += next()
+		p := posting{doc: doc, pos: make([]uint32, next())}
+		prev := uint32(0)
+		for i := range p.pos {
+			prev += next()
+			p.pos[i] = prev
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// SearchFilter acota los resultados por los datos de la entry.
+type SearchFilter struct {
+	CPV       CPVFilter
+	NUTS      []string // prefijos
+	Status    []string
+	MinAmount float64 // presupuesto sin impuestos; 0 = sin límite
+	MaxAmount float64
+}
+
+func (f *SearchFilter) Match(d *IndexDoc) bool {
+	if !f.CPV.MatchAny(d.CPVs) {
+		return false
+	}
+	if len(f.NUTS) > 0 && !hasAnyPrefix(d.NUTS, f.NUTS) {
+		return false
+	}
+	if len(f.Status) > 0 {
+		ok := false
+		for _, s := range f.Status {
+			ok = ok || strings.EqualFold(s, d.Status)
+		}
+		if !ok {
+			return false
+		}
+	}
+	if f.MinAmount > 0 && d.Budget < f.MinAmount {
+		return false
+	}
+	return f.MaxAmount <= 0 || d.Budget <= f.MaxAmount
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(strings.ToUpper(s), strings.ToUpper(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+type SearchQuery struct {
+	Text   string // palabras (todas obligatorias), "frases" y -exclusiones
+	Filter SearchFilter
+	Where  *Filter // sólo con los campos de IndexFilterFields
+	Limit  int     // 20 por defecto
+	Offset int
+}
+
+// IndexFilterFields son los campos del lenguaje de filtros que guarda el índice.
+var IndexFilterFields = []string{"id", "title", "status", "cpv", "nuts", "province", "region", "buyer", "budget", "updated"}
+
+func (d *IndexDoc) texts(field string) []string {
+	var v string
+	switch field {
+	case "id":
+		v = d.ID
+	case "title":
+		v = d.Title
+	case "status":
+		v = d.Status
+	case "cpv":
+		return d.CPVs
+	case "nuts":
+		v = d.NUTS
+	case "province":
+		v = d.Province
+	case "region":
+		v = d.Region
+	case "buyer":
+		v = d.Org
+	}
+	if v == "" {
+		return nil
+	}
+	return []string{v}
+}
+
+// number sigue a entryRecord.number: sin presupuesto publicado no hay valor.
+func (d *IndexDoc) number(field string) (float64, bool) {
+	return d.Budget, field == "budget" && d.HasBudget
+}
+
+func (d *IndexDoc) date(field string) time.Time {
+	if field == "updated" {
+		return d.Updated
+	}
+	return time.Time{}
+}
+
+type SearchHit struct {
+	IndexDoc
+	Score float64 `json:"score"`
+}
+
+type SearchResult struct {
+	Total int         `json:"total"`
+	Hits  []SearchHit `json:"hits"`
+}
+
+// clause es una palabra o una frase de la consulta, ya analizada.
+type clause struct {
+	tokens []Token
+	negate bool
+}
+
+// parseQuery separa la consulta en palabras, "frases" y -exclusiones.
+func parseQuery(q string) ([]clause, error) {
+	var out []clause
+	add := func(text string, negate bool) {
+		if toks := Analyze(text); len(toks) > 0 {
+			out = append(out, clause{tokens: toks, negate: negate})
+		}
+	}
+	for i := 0; i < len(q); {
+		switch c := q[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		default:
+			negate := false
+			if c == '-' {
+				negate = true
+				i++
+			}
+			if i < len(q) && q[i] == '"' {
+				end := strings.IndexByte(q[i+1:], '"')
+				if end < 0 {
+					return nil, fmt.Errorf("consulta: comillas sin cerrar en la posición %d", i)
+				}
+				add(q[i+1:i+1+end], negate)
+				i += end + 2
+				continue
+			}
+			end := strings.IndexAny(q[i:], " \t")
+			if end < 0 {
+				end = len(q) - i
+			}
+			add(q[i:i+end], negate)
+			i += end
+		}
+	}
+	return out, nil
+}
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Validate comprueba la consulta sin tocar el índice: sintaxis del texto y
+// campos de Where que el índice guarda.
+func (q *SearchQuery) Validate() error {
+	_, err := q.parse()
+	return err
+}
+
+func (q *SearchQuery) parse() ([]clause, error) {
+	for _, f := range q.Where.Fields() {
+		if !slices.Contains(IndexFilterFields, f) {
+			return nil, fmt.Errorf("filtro: el índice no guarda el campo %s (disponibles: %s)", f, strings.Join(IndexFilterFields, ", "))
+		}
+	}
+	return parseQuery(q.Text)
+}
+
+// Search devuelve los documentos que contienen todas las palabras y frases de
+// q.Text (y ninguna de las excluidas) y pasan el filtro, por relevancia BM25.
+// Sin texto devuelve los que pasan el filtro, los más recientes primero.
+func (ix *Index) Search(q SearchQuery) (*SearchResult, error) {
+	if q.Limit <= 0 {
+		q.Limit = 20
+	}
+	q.Offset = max(q.Offset, 0)
+	clauses, err := q.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	cache := make(map[string]map[uint32][]uint32)
+	load := func(term string) (map[uint32][]uint32, error) {
+		if m, ok := cache[term]; ok {
+			return m, nil
+		}
+		ps, err := ix.readPostings(term)
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[uint32][]uint32, len(ps))
+		for _, p := range ps {
+			m[p.doc] = p.pos
+		}
+		cache[term] = m
+		return m, nil
+	}
+
+	// Documentos de cada cláusula; una frase exige las posiciones consecutivas.
+	var candidates map[uint32]bool
+	exclude := make(map[uint32]bool)
+	for _, c := range clauses {
+		lists := make([]map[uint32][]uint32, len(c.tokens))
+		for i, t := range c.tokens {
+			if lists[i], err = load(t.Term); err != nil {
+				return nil, err
+			}
+		}
+		match := make(map[uint32]bool)
+		for doc, first := range lists[0] {
+			if phraseAt(lists, c.tokens, doc, first) {
+				match[doc] = true
+			}
+		}
+		switch {
+		case c.negate:
+			for d := range match {
+				exclude[d] = true
+			}
+		case candidates == nil:
+			candidates = match
+		default:
+			for d := range candidates {
+				if !match[d] {
+					delete(candidates, d)
+				}
+			}
+		}
+	}
+
+	var hits []SearchHit
+	consider := func(n uint32) {
+		d := &ix.meta.Docs[n]
+		if exclude[n] || !q.Filter.Match(d) || !q.Where.match(d) {
+			return
+		}
+		hits = append(hits, SearchHit{IndexDoc: *d, Score: ix.score(n, clauses, cache)})
+	}
+	if candidates == nil {
+		for n := range ix.meta.Docs {
+			consider(uint32(n))
+		}
+	} else {
+		for n := range candidates {
+			consider(n)
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if !hits[i].Updated.Equal(hits[j].Updated) {
+			return hits[i].Updated.After(hits[j].Updated)
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	res := &SearchResult{Total: len(hits)}
+	if q.Offset < len(hits) {
+		res.Hits = hits[q.Offset : q.Offset+min(len(hits)-q.Offset, q.Limit)]
+	}
+	return res, nil
+}
+
+// phraseAt indica si doc tiene los tokens en sus posiciones relativas, a partir
+// de alguna aparición del primero.
+func phraseAt(lists []map[uint32][]uint32, toks []Token, doc uint32, first []uint32) bool {
+	if len(toks) == 1 {
+		return true
+	}
+	for _, p := range first {
+		ok := true
+		for i := 1; i < len(toks) && ok; i++ {
+			want := p + uint32(toks[i].Pos-toks[0].Pos)
+			pos := lists[i][doc]
+			j := sort.Search(len(pos), func(k int) bool { return pos[k] >= want })
+			ok = j < len(pos) && pos[j] == want
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (ix *Index) score(n uint32, clauses []clause, cache map[string]map[uint32][]uint32) float64 {
+	d := &ix.meta.Docs[n]
+	N := float64(len(ix.meta.Docs))
+	norm := 1 - bm25B + bm25B*float64(d.Len)/math.Max(ix.meta.AvgLen, 1)
+	seen := make(map[string]bool)
+	s := 0.0
+	for _, c := range clauses {
+		if c.negate {
+			continue
+		}
+		for _, t := range c.tokens {
+			if seen[t.Term] {
+				continue
+			}
+			seen[t.Term] = true
+			tf := float64(len(cache[t.Term][n]))
+			df := float64(ix.meta.Terms[t.Term].DF)
+			idf := math.Log(1 + (N-df+0.5)/(df+0.5))
+			s += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+	return s
+}
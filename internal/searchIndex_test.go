@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const testIndexBase = "https://contrataciondelestado.es/sindicacion/licitacionesPerfilContratante/"
+
+func testIndexEntry(id, updated, title, summary, status, cpv, budget string) string {
+	if budget != "" {
+		budget = `<BudgetAmount><TaxExclusiveAmount currencyID="EUR">` + budget + `</TaxExclusiveAmount></BudgetAmount>`
+	}
+	return `<entry><id>` + testIndexBase + id + `</id><title>` + title + `</title><summary>` + summary + `</summary>` +
+		`<updated>` + updated + `</updated><ContractFolderStatus><ContractFolderStatusCode>` + status + `</ContractFolderStatusCode>` +
+		`<ProcurementProject>` + budget + `<RequiredCommodityClassification><ItemClassificationCode>` + cpv +
+		`</ItemClassificationCode></RequiredCommodityClassification></ProcurementProject></ContractFolderStatus></entry>`
+}
+
+// openTestIndex construye un índice en un directorio temporal con dos ficheros:
+// el antiguo trae una versión anterior de la entry 3 que no debe indexarse.
+func openTestIndex(t *testing.T) *Index {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string][]string{
+		"licitacionesPerfilesContratanteCompleto3_20250101_100000.atom": {
+			testIndexEntry("3", "2025-01-01T10:00:00+01:00", "Suministro de gasóleo", "", "PUB", "09134000", "10000"),
+		},
+		"licitacionesPerfilesContratanteCompleto3_20250201_100000.atom": {
+			testIndexEntry("1", "2025-02-01T10:00:00+01:00", "Suministro de gasóleo para vehículos municipales", "Gasóleo A", "PUB", "09134000", "50000"),
+			testIndexEntry("2", "2025-02-01T09:00:00+01:00", "Suministro de gasóleo de calefacción", "Gasóleo para calefacción de colegios; gasóleo C", "ADJ", "09135000", "200000"),
+			testIndexEntry("3", "2025-02-01T08:00:00+01:00", "Servicio de limpieza de vehículos", "", "PUB", "90910000", "10000"),
+			testIndexEntry("4", "2025-01-15T10:00:00+01:00", "Mantenimiento de calefacción", "", "PUB", "50720000", ""),
+		},
+	}
+	for name, entries := range files {
+		feed := "<feed>"
+		for _, e := range entries {
+			feed += "\n" + e
+		}
+		feed += "\n</feed>"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(feed), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	indexDir := filepath.Join(dir, "index")
+	docs, _, err := BuildIndex(context.Background(), dir, indexDir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if docs != 4 {
+		t.Fatalf("BuildIndex: %d documentos, want 4", docs)
+	}
+	ix, err := OpenIndex(indexDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ix.Close() })
+	return ix
+}
+
+func TestIndexPostings(t *testing.T) {
+	ix := openTestIndex(t)
+	ps, err := ix.readPostings("gasole")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Documentos en orden de ID: la entry 3 ya no habla de gasóleo.
+	got := make(map[string][]uint32)
+	for _, p := range ps {
+		got[ix.meta.Docs[p.doc].ID] = p.pos
+	}
+	want := map[string][]uint32{
+		testIndexBase + "1": {2, 6 + fieldGap},
+		testIndexBase + "2": {2, 5 + fieldGap, 10 + fieldGap},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("postings de gasole: %v", got)
+	}
+	for id, pos := range want {
+		if !slices.Equal(got[id], pos) {
+			t.Errorf("%s: posiciones %v, want %v", id, got[id], pos)
+		}
+	}
+	if ps, err := ix.readPostings("inexistente"); err != nil || len(ps) != 0 {
+		t.Errorf("término que no está: %v, %v", ps, err)
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	ix := openTestIndex(t)
+	include, err := ParseCPVPrefixes("0913")
+	if err != nil {
+		t.Fatal(err)
+	}
+	where, err := CompileFilter(`budget >= 50000`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		q    SearchQuery
+		want []string // IDs, en orden
+	}{
+		// La entry 2 tiene el término tres veces: va primero.
+		{"bm25", SearchQuery{Text: "gasóleo"}, []string{"2", "1"}},
+		{"todas las palabras", SearchQuery{Text: "gasoleo vehiculos"}, []string{"1"}},
+		{"frase con palabra vacía", SearchQuery{Text: `"gasóleo de calefacción"`}, []string{"2"}},
+		{"frase sin el hueco", SearchQuery{Text: `"gasóleo calefacción"`}, nil},
+		{"frase entre campos", SearchQuery{Text: `"municipales gasóleo"`}, nil},
+		{"exclusión", SearchQuery{Text: "gasóleo -calefacción"}, []string{"1"}},
+		{"exclusión de frase", SearchQuery{Text: `calefacción -"de colegios"`}, []string{"4"}},
+		{"estado", SearchQuery{Text: "gasóleo", Filter: SearchFilter{Status: []string{"adj"}}}, []string{"2"}},
+		{"importe", SearchQuery{Text: "suministro", Filter: SearchFilter{MinAmount: 20000, MaxAmount: 100000}}, []string{"1"}},
+		{"cpv", SearchQuery{Filter: SearchFilter{CPV: CPVFilter{Include: include}}}, []string{"1", "2"}},
+		// Sin texto, los más recientes primero.
+		{"where", SearchQuery{Where: where}, []string{"1", "2"}},
+		{"offset negativo", SearchQuery{Text: "gasóleo", Offset: -3}, []string{"2", "1"}},
+		{"offset y límite", SearchQuery{Text: "gasóleo", Offset: 1, Limit: 5}, []string{"1"}},
+		{"offset de más", SearchQuery{Text: "gasóleo", Offset: 9}, nil},
+	}
+	for _, c := range cases {
+		res, err := ix.Search(c.q)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		var got []string
+		for _, h := range res.Hits {
+			got = append(got, h.ID[len(testIndexBase):])
+		}
+		if !slices.Equal(got, c.want) {
+			t.Errorf("%s: %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	if _, err := ix.Search(SearchQuery{Text: `"sin cerrar`}); err == nil {
+		t.Error("comillas sin cerrar: want error")
+	}
+	bad, _ := CompileFilter(`winner_nif = "B1"`)
+	if _, err := ix.Search(SearchQuery{Where: bad}); err == nil {
+		t.Error("campo que el índice no guarda: want error")
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want []Token
+	}{
+		{"Suministro de GASÓLEO", []Token{{"suministr", 0}, {"gasole", 2}}},
+		{"luces y meses", []Token{{"luz", 0}, {"mes", 2}}},
+		{"Camión-grúa, 2025", []Token{{"camion", 0}, {"grua", 1}, {"2025", 2}}},
+		{"de la y", nil},
+	} {
+		if got := Analyze(c.in); !slices.Equal(got, c.want) {
+			t.Errorf("Analyze(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+	for in, want := range map[string]string{
+		"vehiculos": "vehicul",
+		"coches":    "coch",
+		"luces":     "luz",
+		"meses":     "mes",
+		"limpieza":  "limpiez",
+		"casa":      "casa", // menos de 5 letras
+		"camion":    "camion",
+	} {
+		if got := StemSpanish(in); got != want {
+			t.Errorf("StemSpanish(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := FoldAccents("Camión ÑANDÚ pingüino Façade"); got != "camion nandu pinguino facade" {
+		t.Errorf("FoldAccents = %q", got)
+	}
+}
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPNotifier manda los eventos por correo. Habla SMTP directamente, así que
+// sirve igual contra un servidor real que contra uno local de pruebas
+// (MailHog, smtp4dev…). STARTTLS se usa si el servidor lo anuncia.
+type SMTPNotifier struct {
+	Addr     string // host:puerto
+	Username string // vacío = sin AUTH
+	Password string
+	From     string
+	To       []string
+	Subject  string // asunto; %d se sustituye por el número de eventos
+
+	InsecureSkipVerify bool
+}
+
+func NewSMTPNotifier(addr, from string, to ...string) *SMTPNotifier {
+	return &SMTPNotifier{
+		Addr:    addr,
+		From:    from,
+		To:      to,
+		Subject: "Licitaciones: %d novedades",
+	}
+}
+
+func (s *SMTPNotifier) Notify(ctx context.Context, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	subject := s.Subject
+	if strings.Contains(subject, "%d") {
+		subject = fmt.Sprintf(subject, len(events))
+	}
+	return s.Send(ctx, s.To, subject, eventsText(events), "")
+}
+
+// Send envía un correo con cuerpo de texto y, si html no está vacío, también la
+// alternativa HTML.
+func (s *SMTPNotifier) Send(ctx context.Context, to []string, subject, text, html string) error {
+	if len(to) == 0 {
+		return fmt.Errorf("smtp: no recipients")
+	}
+	msg, err := buildMail(s.From, to, subject, text, html)
+	if err != nil {
+		return err
+	}
+
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	d := net.Dialer{Timeout: 30 * time.Second}
+	conn, err := d.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host, InsecureSkipVerify: s.InsecureSkipVerify}); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return fmt.Errorf("smtp from: %w", err)
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp rcpt %s: %w", rcpt, err)
+		}
+	}
+	wc, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := wc.Write(msg); err != nil {
+		wc.Close()
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	return c.Quit()
+}
+
+// buildMail arma el mensaje RFC 5322: text/plain o multipart/alternative.
+func buildMail(from string, to []string, subject, text, html string) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	if html == "" {
+		if err := writeMailPart(&b, "text/plain", text); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+
+	rnd := make([]byte, 12)
+	if _, err := rand.Read(rnd); err != nil {
+		return nil, err
+	}
+	boundary := "lic-" + hex.EncodeToString(rnd)
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	for _, p := range []struct{ ct, body string }{{"text/plain", text}, {"text/html", html}} {
+		fmt.Fprintf(&b, "--%s\r\n", boundary)
+		if err := writeMailPart(&b, p.ct, p.body); err != nil {
+			return nil, err
+		}
+		b.WriteString("\r\n")
+	}
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return b.Bytes(), nil
+}
+
+func writeMailPart(b *bytes.Buffer, contentType, body string) error {
+	fmt.Fprintf(b, "Content-Type: %s; charset=utf-8\r\n", contentType)
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(b)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP es un servidor SMTP mínimo, sin STARTTLS ni AUTH, que guarda el
+// remitente, los destinatarios y el mensaje de la primera sesión.
+type fakeSMTP struct {
+	addr string
+	done chan struct{}
+	from string
+	rcpt []string
+	data string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &fakeSMTP{addr: ln.Addr().String(), done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			cmd := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 fake")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				s.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+				reply("250 ok")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				s.rcpt = append(s.rcpt, strings.Trim(line[len("RCPT TO:"):], "<>"))
+				reply("250 ok")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var b strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					b.WriteString(l)
+				}
+				s.data = b.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return s
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	srv := newFakeSMTP(t)
+	n := NewSMTPNotifier(srv.addr, "avisos@example.com", "ana@example.com", "luis@example.com")
+	if err := n.Notify(context.Background(), testEvents); err != nil {
+		t.Fatal(err)
+	}
+	<-srv.done
+
+	if srv.from != "avisos@example.com" {
+		t.Errorf("MAIL FROM %q", srv.from)
+	}
+	if len(srv.rcpt) != 2 || srv.rcpt[0] != "ana@example.com" || srv.rcpt[1] != "luis@example.com" {
+		t.Errorf("RCPT TO %v", srv.rcpt)
+	}
+	for _, want := range []string{
+		"To: ana@example.com, luis@example.com\r\n",
+		"Subject: Licitaciones: 1 novedades\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"Suministro de gas=C3=B3leo",
+	} {
+		if !strings.Contains(srv.data, want) {
+			t.Errorf("el mensaje no contiene %q:\n%s", want, srv.data)
+		}
+	}
+}
+
+func TestSMTPNotifierNoRecipients(t *testing.T) {
+	n := NewSMTPNotifier("127.0.0.1:1", "avisos@example.com")
+	if err := n.Notify(context.Background(), testEvents); err == nil {
+		t.Error("sin destinatarios: want error")
+	}
+}
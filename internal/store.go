@@ -0,0 +1,17 @@
+package internal
+
+import "context"
+
+// Store guarda la última versión conocida de cada entry junto a sus lotes.
+// Upsert ignora versiones con Updated anterior a la ya almacenada.
+type Store interface {
+	Get(ctx context.Context, id string) (*Entry, error)
+	Upsert(ctx context.Context, e Entry) error
+	Close() error
+}
+
+// HistoryStore conserva además cada versión publicada de una entry.
+type HistoryStore interface {
+	Store
+	History(ctx context.Context, id string) (*History, error)
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Análisis de texto para el buscador: minúsculas, sin tildes, sin palabras
+// vacías y con un stemmer ligero de español (plurales y género), de forma que
+// "Contrataciones" y "contratación" den el mismo término.
+
+type Token struct {
+	Term string
+	Pos  int // posición en el texto, contando también las palabras vacías
+}
+
+// FoldAccents pasa a minúsculas y quita tildes y diéresis (ñ -> n, ç -> c).
+func FoldAccents(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		b.WriteRune(foldRune(unicode.ToLower(r)))
+	}
+	return b.String()
+}
+
+func foldRune(r rune) rune {
+	switch r {
+	case 'á', 'à', 'â', 'ä', 'ã':
+		return 'a'
+	case 'é', 'è', 'ê', 'ë':
+		return 'e'
+	case 'í', 'ì', 'î', 'ï':
+		return 'i'
+	case 'ó', 'ò', 'ô', 'ö', 'õ':
+		return 'o'
+	case 'ú', 'ù', 'û', 'ü':
+		return 'u'
+	case 'ñ':
+		return 'n'
+	case 'ç':
+		return 'c'
+	}
+	return r
+}
+
+var spanishStopwords = map[string]bool{
+	"a": true, "al": true, "con": true, "de": true, "del": true, "e": true, "el": true, "en": true,
+	"la": true, "las": true, "lo": true, "los": true, "o": true, "para": true, "por": true, "que": true,
+	"se": true, "sin": true, "su": true, "sus": true, "u": true, "un": true, "una": true, "y": true,
+}
+
+// Analyze parte text en términos normalizados. Las palabras vacías no generan
+// término pero sí consumen posición, para que las frases se comparen igual en
+// el índice y en la consulta.
+func Analyze(text string) []Token {
+	var out []Token
+	pos := 0
+	for _, w := range strings.FieldsFunc(FoldAccents(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !spanishStopwords[w] {
+			out = append(out, Token{Term: StemSpanish(w), Pos: pos})
+		}
+		pos++
+	}
+	return out
+}
+
+// StemSpanish es el stemmer ligero de Savoy (el SpanishLightStemmer de Lucene)
+// sobre una palabra ya sin tildes: quita el género y el plural.
+func StemSpanish(w string) string {
+	n := len(w)
+	if n < 5 {
+		return w
+	}
+	switch w[n-1] {
+	case 'o', 'a', 'e':
+		return w[:n-1]
+	case 's':
+		switch {
+		case strings.HasSuffix(w, "eses"):
+			return w[:n-2] // meses -> mes
+		case strings.HasSuffix(w, "ces"):
+			return w[:n-3] + "z" // luces -> luz
+		case w[n-2] == 'o' || w[n-2] == 'a' || w[n-2] == 'e':
+			return w[:n-2]
+		}
+	}
+	return w
+}
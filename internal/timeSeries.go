@@ -0,0 +1,321 @@
+package internal
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Serie temporal de publicaciones: entries por fecha de primera publicación
+// (ValidNoticeInfo DOC_CN IssueDate), agrupadas por mes o semana ISO.
+
+type publication struct {
+	id        string
+	updated   time.Time
+	published time.Time
+	cpvs      []string
+	nuts      string
+	province  string
+	region    string
+	procedure string
+	budget    float64
+	match     bool // pasa el filtro Where
+}
+
+// publicationSet es el Partial de la serie: una publicación por ID, con los
+// datos de la versión más reciente.
+type publicationSet struct {
+	byID  map[string]publication
+	where *Filter
+}
+
+func newPublicationSet(where *Filter) *publicationSet {
+	return &publicationSet{byID: make(map[string]publication), where: where}
+}
+
+func (s *publicationSet) Add(e *Entry, _ string) {
+	published := e.PublishedDate()
+	if published.IsZero() {
+		return
+	}
+	var province, region string
+	if g := e.Geo(); g != nil {
+		province, region = g.Province, g.Region
+	}
+	s.put(publication{
+		id:        e.ID,
+		updated:   e.Updated.Time,
+		published: published,
+		cpvs:      e.CPVs(),
+		nuts:      e.NUTS(),
+		province:  province,
+		region:    region,
+		procedure: strings.TrimSpace(e.CFS.Process.ProcedureCode.Value),
+		budget:    e.Budget(),
+		match:     s.where.Match(e),
+	})
+}
+
+func (s *publicationSet) Merge(other *publicationSet) {
+	for _, p := range other.byID {
+		s.put(p)
+	}
+}
+
+func (s *publicationSet) put(p publication) {
+	if old, ok := s.byID[p.id]; ok && !p.updated.After(old.updated) {
+		return
+	}
+	s.byID[p.id] = p
+}
+
+const (
+	PeriodMonth = "month"
+	PeriodWeek  = "week"
+)
+
+// PeriodKey devuelve "2025-03" o "2025-W13".
+func PeriodKey(t time.Time, period string) string {
+	if period == PeriodWeek {
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", y, w)
+	}
+	return t.Format("2006-01")
+}
+
+// periodStart lleva t al inicio de su periodo.
+func periodStart(t time.Time, period string) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if period == PeriodWeek {
+		wd := (int(t.Weekday()) + 6) % 7 // lunes = 0
+		return t.AddDate(0, 0, -wd)
+	}
+	return t.AddDate(0, 0, 1-t.Day())
+}
+
+func nextPeriod(t time.Time, period string) time.Time {
+	if period == PeriodWeek {
+		return t.AddDate(0, 0, 7)
+	}
+	return t.AddDate(0, 1, 0)
+}
+
+// Dimensiones de la serie ("all" = sin desglose).
+const (
+	DimNUTS     = "nuts"
+	DimProvince = "province"
+	DimRegion   = "region"
+)
+
+var SeriesDimensions = []string{"all", DimCPV, DimNUTS, DimProvince, DimRegion, DimProcedure}
+
+type SeriesOptions struct {
+	Period     string // month o week
+	Dimension  string // all, cpv, nuts, province, region, procedure
+	CPVDigits  int    // prefijo de CPV con que se agrupa
+	NUTSLength int    // prefijo de NUTS (ES5 = 3, ES52 = 4, ES523 = 5); 0 = completo
+	CPV        CPVFilter
+	From, To   time.Time // sobre la fecha de publicación; To exclusiva
+	Where      *Filter   // sobre la versión más reciente de la entry
+}
+
+type SeriesPoint struct {
+	Period string  `json:"period"`
+	Key    string  `json:"key"`
+	Count  int     `json:"count"`
+	Budget float64 `json:"budget"`
+}
+
+type Series struct {
+	Dimension string        `json:"dimension"`
+	Periods   []string      `json:"periods"`
+	Keys      []string      `json:"keys"`
+	Points    []SeriesPoint `json:"points"` // periodo × clave, con ceros
+	Totals    []SeriesPoint `json:"totals"` // por periodo; cada entry cuenta una vez
+}
+
+func (o *SeriesOptions) keys(p *publication) []string {
+	switch o.Dimension {
+	case DimCPV:
+		seen := make(map[string]bool)
+		var keys []string
+		for _, c := range p.cpvs {
+			if !o.CPV.Match(c) {
+				continue
+			}
+			if o.CPVDigits > 0 && len(c) > o.CPVDigits {
+				c = c[:o.CPVDigits]
+			}
+			if !seen[c] {
+				seen[c] = true
+				keys = append(keys, c)
+			}
+		}
+		return keys
+	case DimNUTS:
+		n := p.nuts
+		if o.NUTSLength > 0 && len(n) > o.NUTSLength {
+			n = n[:o.NUTSLength]
+		}
+		return []string{n}
+	case DimProvince:
+		return []string{p.province}
+	case DimRegion:
+		return []string{p.region}
+	case DimProcedure:
+		return []string{p.procedure}
+	}
+	return []string{"all"}
+}
+
+// BuildSeries cuenta publicaciones y suma presupuestos por periodo y clave.
+// Los periodos sin publicaciones aparecen con cero para que la serie sea continua.
+func BuildSeries(pubs []publication, opts SeriesOptions) *Series {
+	type cell struct {
+		count  int
+		budget float64
+	}
+	cells := make(map[[2]string]*cell)
+	totals := make(map[string]*cell)
+	keySet := make(map[string]bool)
+	var first, last time.Time
+
+	for i := range pubs {
+		p := &pubs[i]
+		if !p.match || !opts.CPV.MatchAny(p.cpvs) {
+			continue
+		}
+		if !opts.From.IsZero() && p.published.Before(opts.From) {
+			continue
+		}
+		if !opts.To.IsZero() && !p.published.Before(opts.To) {
+			continue
+		}
+		if first.IsZero() || p.published.Before(first) {
+			first = p.published
+		}
+		if p.published.After(last) {
+			last = p.published
+		}
+		period := PeriodKey(p.published, opts.Period)
+		t, ok := totals[period]
+		if !ok {
+			t = &cell{}
+			totals[period] = t
+		}
+		t.count++
+		t.budget += p.budget
+		for _, k := range opts.keys(p) {
+			keySet[k] = true
+			c, ok := cells[[2]string{period, k}]
+			if !ok {
+				c = &cell{}
+				cells[[2]string{period, k}] = c
+			}
+			c.count++
+			c.budget += p.budget
+		}
+	}
+
+	s := &Series{Dimension: opts.Dimension}
+	if first.IsZero() {
+		return s
+	}
+	for t := periodStart(first, opts.Period); !t.After(last); t = nextPeriod(t, opts.Period) {
+		s.Periods = append(s.Periods, PeriodKey(t, opts.Period))
+	}
+	s.Keys = sortedKeys(keySet)
+	for _, period := range s.Periods {
+		tot := SeriesPoint{Period: period, Key: "all"}
+		if c, ok := totals[period]; ok {
+			tot.Count, tot.Budget = c.count, c.budget
+		}
+		s.Totals = append(s.Totals, tot)
+		for _, k := range s.Keys {
+			pt := SeriesPoint{Period: period, Key: k}
+			if c, ok := cells[[2]string{period, k}]; ok {
+				pt.Count, pt.Budget = c.count, c.budget
+			}
+			s.Points = append(s.Points, pt)
+		}
+	}
+	return s
+}
+
+// ScanSeries lee los atom de dir y construye la serie.
+func ScanSeries(ctx context.Context, dir string, workers int, opts SeriesOptions) (*Series, error) {
+	files, err := listLocalAtoms(dir)
+	if err != nil {
+		return nil, err
+	}
+	set, err := ScanAtoms(ctx, files, workers, func() *publicationSet { return newPublicationSet(opts.Where) })
+	if err != nil {
+		return nil, err
+	}
+	pubs := make([]publication, 0, len(set.byID))
+	for _, p := range set.byID {
+		pubs = append(pubs, p)
+	}
+	return BuildSeries(pubs, opts), nil
+}
+
+// WriteSeriesCSV escribe la serie en formato largo: periodo, clave, número y presupuesto.
+func WriteSeriesCSV(path string, s *Series) error {
+	return writeFileAtomic(path, func(f io.Writer) error {
+		w := csv.NewWriter(f)
+		_ = w.Write([]string{"period", s.Dimension, "count", "budget"})
+		for _, p := range s.Points {
+			_ = w.Write([]string{p.Period, p.Key, strconv.Itoa(p.Count), fixed2(p.Budget)})
+		}
+		w.Flush()
+		return w.Error()
+	})
+}
+
+// WriteSeriesChart dibuja una barra por periodo con el total (metric = count
+// o budget).
+func WriteSeriesChart(w io.Writer, s *Series, metric string, width int) error {
+	maxV := 0.0
+	vals := make([]float64, len(s.Totals))
+	for i, p := range s.Totals {
+		vals[i] = float64(p.Count)
+		if metric == "budget" {
+			vals[i] = p.Budget
+		}
+		maxV = math.Max(maxV, vals[i])
+	}
+
+	var b strings.Builder
+	for i, p := range s.Totals {
+		v := vals[i]
+		n := 0
+		if maxV > 0 {
+			n = int(math.Round(v / maxV * float64(width)))
+		}
+		label := strconv.FormatFloat(v, 'f', 0, 64)
+		if metric == "budget" {
+			label = humanAmount(v)
+		}
+		fmt.Fprintf(&b, "%-8s │%s %s\n", p.Period, strings.Repeat("█", n), label)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// humanAmount abrevia importes: 1234567 -> "1.2M".
+func humanAmount(v float64) string {
+	switch {
+	case math.Abs(v) >= 1e9:
+		return strconv.FormatFloat(v/1e9, 'f', 1, 64) + "G"
+	case math.Abs(v) >= 1e6:
+		return strconv.FormatFloat(v/1e6, 'f', 1, 64) + "M"
+	case math.Abs(v) >= 1e3:
+		return strconv.FormatFloat(v/1e3, 'f', 1, 64) + "k"
+	}
+	return strconv.FormatFloat(v, 'f', 0, 64)
+}
@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Cabeceras que acompañan a cada POST del webhook.
+const (
+	WebhookSignatureHeader = "X-Licitaciones-Signature" // "sha256=<hex>" sobre timestamp + "." + body
+	WebhookTimestampHeader = "X-Licitaciones-Timestamp" // segundos unix
+)
+
+// WebhookPayload es el JSON que recibe el endpoint.
+type WebhookPayload struct {
+	SentAt time.Time `json:"sentAt"`
+	Events []Event   `json:"events"`
+}
+
+// WebhookNotifier hace POST de los eventos como JSON a una URL cualquiera
+// (Make, n8n, un servicio propio…). Con Secret firma cada petición con
+// HMAC-SHA256; los errores de red, 429 y 5xx se reintentan con backoff.
+type WebhookNotifier struct {
+	URL     string
+	Secret  string
+	Headers map[string]string // p.ej. x-make-apikey
+	Client  *http.Client
+
+	MaxRetries int           // reintentos tras el primer intento
+	Backoff    time.Duration // espera inicial, se dobla en cada reintento
+}
+
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:        url,
+		Secret:     secret,
+		Client:     &http.Client{Timeout: 30 * time.Second},
+		MaxRetries: 3,
+		Backoff:    time.Second,
+	}
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(WebhookPayload{SentAt: time.Now().UTC(), Events: events})
+	if err != nil {
+		return err
+	}
+
+	wait := w.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(ctx, body, time.Now())
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.MaxRetries {
+			return fmt.Errorf("webhook %s: %w", w.URL, err)
+		}
+		log.Printf("[WEBHOOK] intento %d: %v, reintento en %s", attempt+1, err, wait)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// post hace un intento. retry indica si merece la pena repetirlo.
+func (w *WebhookNotifier) post(ctx context.Context, body []byte, at time.Time) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	if w.Secret != "" {
+		ts := strconv.FormatInt(at.Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, ts)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(w.Secret, ts, body))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer res.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return false, nil
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return true, fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(msg))
+	default:
+		return false, fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+}
+
+// SignWebhook calcula la firma hex de un envío. El receptor debe recalcularla
+// con el mismo secreto y comparar con hmac.Equal.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookTolerance es la diferencia máxima entre el timestamp firmado y el reloj
+// del receptor. Sin ella una petición capturada se podría reenviar siempre.
+const WebhookTolerance = 5 * time.Minute
+
+// VerifyWebhook comprueba la cabecera de firma de una petición recibida y que
+// su timestamp no se aleje de now más de WebhookTolerance.
+func VerifyWebhook(secret string, h http.Header, body []byte, now time.Time) bool {
+	sig := h.Get(WebhookSignatureHeader)
+	if len(sig) < len("sha256=") || sig[:len("sha256=")] != "sha256=" {
+		return false
+	}
+	ts := h.Get(WebhookTimestampHeader)
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > WebhookTolerance || d < -WebhookTolerance {
+		return false
+	}
+	want := SignWebhook(secret, ts, body)
+	return hmac.Equal([]byte(sig[len("sha256="):]), []byte(want))
+}
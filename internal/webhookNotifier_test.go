@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testEvents = []Event{{Type: EventCreated, EntryID: "e1", Title: "Suministro de gasóleo"}}
+
+func TestWebhookNotifierSigned(t *testing.T) {
+	const secret = "s3cr3t"
+	var got WebhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !VerifyWebhook(secret, r.Header, body, time.Now()) {
+			http.Error(w, "firma no válida", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("x-make-apikey") != "k" {
+			http.Error(w, "falta la cabecera", http.StatusBadRequest)
+			return
+		}
+		json.Unmarshal(body, &got)
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(srv.URL, secret)
+	n.Headers = map[string]string{"x-make-apikey": "k"}
+	if err := n.Notify(context.Background(), testEvents); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Events) != 1 || got.Events[0].EntryID != "e1" {
+		t.Errorf("payload recibido %+v", got)
+	}
+}
+
+func TestWebhookNotifierRetries(t *testing.T) {
+	var calls atomic.Int32
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(status)
+		}
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(srv.URL, "")
+	n.Backoff = time.Millisecond
+	if err := n.Notify(context.Background(), testEvents); err != nil {
+		t.Fatalf("503 dos veces y luego 200: %v", err)
+	}
+	if c := calls.Load(); c != 3 {
+		t.Errorf("%d intentos, want 3", c)
+	}
+
+	// Un 4xx distinto de 429 no se reintenta.
+	calls.Store(0)
+	status = http.StatusBadRequest
+	if err := n.Notify(context.Background(), testEvents); err == nil {
+		t.Error("400: want error")
+	}
+	if c := calls.Load(); c != 1 {
+		t.Errorf("400: %d intentos, want 1", c)
+	}
+}
+
+func TestVerifyWebhookTolerance(t *testing.T) {
+	const secret = "s3cr3t"
+	body := []byte(`{"events":[]}`)
+	now := time.Unix(1_750_000_000, 0)
+	header := func(at time.Time, sig string) http.Header {
+		ts := strconv.FormatInt(at.Unix(), 10)
+		if sig == "" {
+			sig = "sha256=" + SignWebhook(secret, ts, body)
+		}
+		h := http.Header{}
+		h.Set(WebhookTimestampHeader, ts)
+		h.Set(WebhookSignatureHeader, sig)
+		return h
+	}
+	cases := []struct {
+		name string
+		h    http.Header
+		want bool
+	}{
+		{"al momento", header(now, ""), true},
+		{"dentro de la tolerancia", header(now.Add(-WebhookTolerance+time.Second), ""), true},
+		{"reloj del emisor adelantado", header(now.Add(time.Minute), ""), true},
+		{"antigua", header(now.Add(-WebhookTolerance-time.Second), ""), false},
+		{"futura", header(now.Add(WebhookTolerance+time.Second), ""), false},
+		{"firma de otro secreto", header(now, "sha256="+SignWebhook("otro", strconv.FormatInt(now.Unix(), 10), body)), false},
+		{"sin prefijo", header(now, SignWebhook(secret, strconv.FormatInt(now.Unix(), 10), body)), false},
+	}
+	for _, c := range cases {
+		if got := VerifyWebhook(secret, c.h, body, now); got != c.want {
+			t.Errorf("%s: VerifyWebhook = %v, want %v", c.name, got, c.want)
+		}
+	}
+	h := header(now, "")
+	h.Set(WebhookTimestampHeader, "ayer")
+	if VerifyWebhook(secret, h, body, now) {
+		t.Error("timestamp no numérico aceptado")
+	}
+}
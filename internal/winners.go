@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Directorio de adjudicatarios: la imagen especular de OrgAgg, un registro por
+// NIF de WinningParty.
+
+// KeyCount es un valor con el número de adjudicaciones en que aparece.
+type KeyCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+type Supplier struct {
+	NIF           string     `json:"nif"`
+	Name          string     `json:"name"`
+	Awards        int        `json:"awards"`
+	TaxExclusive  float64    `json:"taxExclusive"` // suma de TaxExclusiveAmount
+	Payable       float64    `json:"payable"`      // suma de PayableAmount
+	CPVs          []KeyCount `json:"cpvs"`
+	Buyers        []KeyCount `json:"buyers"`
+	NUTS          []KeyCount `json:"nuts"` // PhysicalLocation del adjudicatario
+	LastAwardDate time.Time  `json:"lastAwardDate,omitzero"`
+	LastAwardURL  string     `json:"lastAwardUrl,omitempty"`
+}
+
+type supplierAcc struct {
+	s      Supplier
+	nameAt time.Time
+	cpvs   map[string]int
+	buyers map[string]int
+	nuts   map[string]int
+}
+
+// BuildSuppliers agrupa las adjudicaciones por NIF. El nombre es el de la
+// adjudicación más reciente. A cada empresa de una UTE se le cuenta la
+// adjudicación entera. Ordenados por número de adjudicaciones.
+func BuildSuppliers(records []AwardRecord) []Supplier {
+	byNIF := make(map[string]*supplierAcc)
+	for i := range records {
+		r := &records[i]
+		at := r.Date
+		if at.IsZero() {
+			at = r.Updated
+		}
+		for _, p := range r.Winners {
+			acc, ok := byNIF[p.NIF]
+			if !ok {
+				acc = &supplierAcc{
+					s:      Supplier{NIF: p.NIF},
+					cpvs:   make(map[string]int),
+					buyers: make(map[string]int),
+					nuts:   make(map[string]int),
+				}
+				byNIF[p.NIF] = acc
+			}
+
+			acc.s.Awards++
+			acc.s.TaxExclusive += r.TaxExclusive
+			acc.s.Payable += r.Payable
+			if p.Name != "" && (acc.s.Name == "" || at.After(acc.nameAt)) {
+				acc.s.Name, acc.nameAt = p.Name, at
+			}
+			if !r.Date.IsZero() && r.Date.After(acc.s.LastAwardDate) {
+				acc.s.LastAwardDate = r.Date
+				acc.s.LastAwardURL = r.URL
+			}
+			for _, c := range r.CPVs {
+				acc.cpvs[c]++
+			}
+			if r.BuyerName != "" {
+				acc.buyers[r.BuyerName]++
+			}
+			if p.NUTS != "" {
+				acc.nuts[p.NUTS]++
+			}
+		}
+	}
+
+	out := make([]Supplier, 0, len(byNIF))
+	for _, acc := range byNIF {
+		acc.s.CPVs = keyCounts(acc.cpvs)
+		acc.s.Buyers = keyCounts(acc.buyers)
+		acc.s.NUTS = keyCounts(acc.nuts)
+		out = append(out, acc.s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Awards != out[j].Awards {
+			return out[i].Awards > out[j].Awards
+		}
+		return out[i].NIF < out[j].NIF
+	})
+	return out
+}
+
+// keyCounts ordena por número descendente y, a igualdad, por clave.
+func keyCounts(m map[string]int) []KeyCount {
+	out := make([]KeyCount, 0, len(m))
+	for k, n := range m {
+		out = append(out, KeyCount{k, n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
+
+func joinKeyCounts(kc []KeyCount) string {
+	parts := make([]string, len(kc))
+	for i, k := range kc {
+		parts[i] = k.Key + " (" + strconv.Itoa(k.Count) + ")"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// WriteSuppliersCSV escribe el directorio en path ("-" = stdout).
+func WriteSuppliersCSV(path string, suppliers []Supplier) error {
+	return writeFileAtomic(path, func(f io.Writer) error {
+		w := csv.NewWriter(f)
+		_ = w.Write([]string{
+			"NIF", "Adjudicatario", "nº adjudicaciones",
+			"Importe sin impuestos", "Importe con impuestos",
+			"CPVs", "Organismos", "NUTS", "Fecha última adjudicación", "Url última adjudicación",
+		})
+		for _, s := range suppliers {
+			_ = w.Write([]string{
+				s.NIF,
+				s.Name,
+				strconv.Itoa(s.Awards),
+				fixed2(s.TaxExclusive),
+				fixed2(s.Payable),
+				joinKeyCounts(s.CPVs),
+				joinKeyCounts(s.Buyers),
+				joinKeyCounts(s.NUTS),
+				formatDate(s.LastAwardDate),
+				s.LastAwardURL,
+			})
+		}
+		w.Flush()
+		return w.Error()
+	})
+}